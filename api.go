@@ -2,10 +2,18 @@
 // Go.
 package gogui
 
+import "image"
+
 // The AppInfo object represents information about the application which the
 // implementation may choose to display to the user in some form.
 type AppInfo struct {
 	Name string
+
+	// Backend names the driver which Main should use, such as "x11" or
+	// "browser". It is ignored if the GOGUI_BACKEND environment variable is
+	// set. If neither says which driver to use, the first available driver is
+	// used.
+	Backend string
 }
 
 // A Canvas is a widget that can be drawn into.
@@ -15,6 +23,18 @@ type Canvas interface {
 	DrawHandler() DrawHandler
 	NeedsUpdate()
 	SetDrawHandler(d DrawHandler)
+
+	// Snapshot runs the draw handler into an offscreen image the size of the
+	// canvas's frame and returns it as an *image.RGBA. Pixels which the draw
+	// handler does not touch are transparent.
+	//
+	// Drivers which draw in a web browser render snapshots with the software
+	// renderer, so text may not look exactly like it does on the page.
+	Snapshot() (image.Image, error)
+
+	// SnapshotScale is like Snapshot, except that the image has scale pixels
+	// per unit of the frame, like a screen with a pixel ratio of scale.
+	SnapshotScale(scale float64) (image.Image, error)
 }
 
 // A Color stores an RGBA color.
@@ -27,27 +47,98 @@ type Color struct {
 
 // A DrawContext receives draw commands.
 type DrawContext interface {
+	// Arc adds an arc of a circle to the path. The angles are in radians and,
+	// like Rotate, increase clockwise on the screen. If clockwise is false, the
+	// arc goes from startAngle to endAngle in the other direction.
+	//
+	// If the path has a current point, a line joins it to the start of the arc.
+	// An arc which sweeps 2*Pi or more is a full circle. A negative radius is
+	// treated as zero.
+	Arc(x, y, radius, startAngle, endAngle float64, clockwise bool)
+
+	// ArcTo adds an arc which is tangent to the line from the current point to
+	// (x1, y1) and to the line from (x1, y1) to (x2, y2), joined to the current
+	// point by a straight line. This is handy for rounded corners.
+	// If the lines are parallel or the radius is not positive, ArcTo adds a
+	// line to (x1, y1) instead.
+	ArcTo(x1, y1, x2, y2, radius float64)
+
+	// AttributedTextSize computes the width and height of an AttributedString
+	// as it would be drawn by FillAttributedText.
+	AttributedTextSize(s *AttributedString) (width, height float64)
+
 	// BeginPath starts a path which can be filled or stroked.
 	BeginPath()
 
+	// ClearShadow stops drawing shadows, like SetShadow with a transparent
+	// color.
+	ClearShadow()
+
+	// ClipPath intersects the clipping region with the inside of the current
+	// path, and then clears the path as FillPath does.
+	//
+	// Nothing is drawn outside of the clipping region, which starts out as the
+	// whole canvas. Since clipping can only shrink the region, use Save and
+	// Restore to undo it.
+	ClipPath()
+
+	// ClipRect intersects the clipping region with a rectangle.
+	ClipRect(r Rect)
+
 	// ClosePath closes the current path by connecting the first and last points
 	// in it.
 	ClosePath()
 
+	// CubicTo adds a cubic Bezier curve from the current point in the path to
+	// (x, y), using two control points.
+	CubicTo(c1x, c1y, c2x, c2y, x, y float64)
+
+	// DrawImage draws an image scaled to fill a rectangle.
+	//
+	// Images passed to DrawImage may be converted every time they are drawn.
+	// Use NewImage to avoid that for images which are drawn over and over.
+	DrawImage(img image.Image, dst Rect)
+
+	// DrawSubImage draws part of an image, scaled to fill a rectangle. The
+	// source rectangle is in the image's own coordinates, like img.Bounds().
+	// Pixels outside of the source rectangle are never used.
+	DrawSubImage(img image.Image, src Rect, dst Rect)
+
+	// DrawTextLayout fills the lines of a TextLayout with the fill paint.
+	// The point is the top-left corner of the layout. The text alignment and
+	// baseline of the context are not used.
+	DrawTextLayout(t *TextLayout, x, y float64)
+
+	// FillAttributedText draws an AttributedString at a point like FillText,
+	// using the alignment and baseline of the context. Runs without a color
+	// are filled with the fill paint.
+	FillAttributedText(s *AttributedString, x, y float64)
+
 	// FillEllipse fills an ellipse inside a rectangle.
 	FillEllipse(r Rect)
 
 	// FillPath fills the current path.
 	// If the path was not closed, the behaviour of FillPath may vary on
 	// different platforms.
+	// Paths which cross themselves or have several subpaths are filled with
+	// the rule passed to SetFillRule.
 	FillPath()
 
+	// FillPathObject fills a Path. It replaces the current path, which is
+	// empty afterwards.
+	FillPathObject(p *Path)
+
 	// FillRect fills a rectangle.
 	FillRect(r Rect)
 	
-	// FillText draws text at a given point.
+	// FillText draws text at a given point. By default, the point is the
+	// top-left corner of the box which TextSize measures. SetTextAlign and
+	// SetTextBaseline change which part of the text goes at the point.
 	FillText(text string, x, y float64)
 
+	// FontMetrics returns the metrics of the current font.
+	FontMetrics() FontMetrics
+
 	// LineTo adds a line from the current point in the path to another point.
 	LineTo(x, y float64)
 
@@ -55,30 +146,151 @@ type DrawContext interface {
 	// the rest of the path.
 	MoveTo(x, y float64)
 
+	// QuadraticTo adds a quadratic Bezier curve from the current point in the
+	// path to (x, y), using one control point.
+	QuadraticTo(cx, cy, x, y float64)
+
+	// Restore pops the graphics state which was pushed by the most recent
+	// call to Save. It does nothing if the stack is empty.
+	Restore()
+
+	// Rotate rotates the coordinate system by an angle in radians. Since the
+	// Y axis points down, positive angles rotate clockwise on the screen.
+	Rotate(angle float64)
+
+	// Save pushes the graphics state onto a stack so that Restore can bring it
+	// back. The graphics state includes the transform, the clipping region,
+	// the fill and stroke colors, the fill rule, the thickness and the rest of
+	// the line style, the font, the text alignment and baseline, the
+	// interpolation, the global alpha, the composite mode, and the shadow, but
+	// not the current path.
+	Save()
+
+	// Scale scales the coordinate system.
+	Scale(x, y float64)
+
+	// SetCompositeMode sets how everything drawn afterwards is combined with
+	// what is already on the canvas. The default is CompositeSourceOver.
+	SetCompositeMode(m CompositeMode)
+
 	// SetFill sets the color for every Fill method.
 	SetFill(c Color)
+
+	// SetFillPaint sets the paint for every Fill method, including FillText.
+	// SetFill(c) is the same as SetFillPaint(c).
+	SetFillPaint(p Paint)
 	
-	// SetFont sets the font and font size used by FillText
+	// SetFillRule sets the rule which FillPath, FillPathObject and ClipPath
+	// use to decide which points are inside of the path. The default is
+	// NonZero. Use EvenOdd to cut holes with subpaths that go in the same
+	// direction as the outside.
+	SetFillRule(rule FillRule)
+
+	// SetFont sets the font and font size used by FillText and StrokeText.
+	// If the font is not installed, the backend falls back as described on
+	// Font. The default font is 18 point Helvetica.
 	SetFont(size float64, name string)
 
+	// SetFontDescriptor sets the font used by FillText and StrokeText, with
+	// its weight, style and fallbacks. SetFont(size, name) is the same as
+	// SetFontDescriptor(Font{Family: name, Size: size}).
+	SetFontDescriptor(f Font)
+
+	// SetGlobalAlpha sets an opacity from 0 to 1 which multiplies the alpha of
+	// everything drawn afterwards, including images. The default is 1. Values
+	// outside of that range are ignored.
+	SetGlobalAlpha(alpha float64)
+
+	// SetInterpolation sets how DrawImage and DrawSubImage sample images. The
+	// default is InterpolationBilinear.
+	SetInterpolation(q Interpolation)
+
+	// SetLineCap sets the shape at the ends of lines drawn by every Stroke
+	// method. The default is LineCapRound.
+	SetLineCap(c LineCap)
+
+	// SetLineDash makes every Stroke method draw dashed lines. The pattern
+	// alternates between the lengths of dashes and the gaps between them,
+	// and phase is how far into the pattern each subpath starts. A pattern
+	// with an odd number of entries is repeated to make it even.
+	//
+	// An empty pattern, or one which is all zero, makes lines solid again.
+	// Patterns with negative or infinite entries are ignored.
+	SetLineDash(pattern []float64, phase float64)
+
+	// SetLineJoin sets the shape at the corners of lines drawn by every Stroke
+	// method. The default is LineJoinRound.
+	SetLineJoin(j LineJoin)
+
+	// SetMiterLimit sets how far a LineJoinMiter corner may stick out, as a
+	// multiple of the thickness, before it is beveled instead. The default is
+	// 10. Limits which are not positive are ignored.
+	SetMiterLimit(limit float64)
+
+	// SetShadow makes everything drawn afterwards cast a shadow, including
+	// strokes, images and text. The shadow is moved by an offset and blurred
+	// by a Gaussian blur with a standard deviation of blur/2, as on an HTML5
+	// canvas. The offset and blur are not affected by the transform. Calls
+	// with a negative blur or values which are not finite are ignored.
+	SetShadow(offsetX, offsetY, blur float64, c Color)
+
 	// SetStroke sets the color for every Stroke method.
 	SetStroke(c Color)
 
+	// SetStrokePaint sets the paint for every Stroke method.
+	// SetStroke(c) is the same as SetStrokePaint(c).
+	SetStrokePaint(p Paint)
+
+	// SetTextAlign sets which part of the text FillText and StrokeText put at
+	// the x coordinate they are given. The default is TextAlignLeft.
+	SetTextAlign(a TextAlign)
+
+	// SetTextBaseline sets which part of the text FillText and StrokeText put
+	// at the y coordinate they are given. The default is TextBaselineTop.
+	SetTextBaseline(b TextBaseline)
+
 	// SetThickness sets the thickness for every Stroke method.
 	SetThickness(thickness float64)
 
+	// SetTransform replaces the current transform. The identity matrix is the
+	// coordinate system which the canvas starts with.
+	SetTransform(m Matrix)
+
 	// StrokeEllipse strokes an ellipse inside a rectangle.
 	StrokeEllipse(r Rect)
 
 	// StrokePath outlines the current path.
 	StrokePath()
 
+	// StrokePathObject outlines a Path. It replaces the current path, which is
+	// empty afterwards.
+	StrokePathObject(p *Path)
+
 	// StrokeRect outlines a rectangle.
 	StrokeRect(r Rect)
+
+	// StrokeText outlines the glyphs of text, using the stroke color and the
+	// line style. It puts the text in the same place as FillText.
+	StrokeText(text string, x, y float64)
 	
+	// TextPositions finds where each grapheme of a string is drawn by
+	// FillText in the current font, for placing carets and hit testing.
+	TextPositions(text string) *TextPositions
+
 	// TextSize computes the width and height for a given string as it would be
 	// drawn by FillText.
 	TextSize(text string) (width, height float64)
+
+	// Transform applies a matrix to points before the current transform.
+	//
+	// Every coordinate passed to a DrawContext goes through the current
+	// transform, and so do the thickness of strokes and the glyphs of text.
+	// Points which were added to the current path keep their position when
+	// the transform changes.
+	Transform(m Matrix)
+
+	// Translate moves the origin of the coordinate system.
+	Translate(x, y float64)
 }
 
 // A DrawHandler is called to draw into a canvas's drawing context.
@@ -208,4 +420,13 @@ type Window interface {
 
 	// Showing returns whether the window is showing or not.
 	Showing() bool
+
+	// Snapshot draws the window's background and the snapshot of every child
+	// into an image the size of the window's content rectangle. It works
+	// whether or not the window is showing.
+	Snapshot() (image.Image, error)
+
+	// SnapshotScale is like Snapshot, except that the image has scale pixels
+	// per unit of the frame.
+	SnapshotScale(scale float64) (image.Image, error)
 }
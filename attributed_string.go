@@ -0,0 +1,264 @@
+package gogui
+
+import (
+	"math"
+)
+
+// A TextStyle holds the attributes of a run of an AttributedString.
+type TextStyle struct {
+	// Font is the font of the text.
+	Font Font
+
+	// Color is the paint for the text and its lines. If it is nil, the fill
+	// paint of the context is used.
+	Color Paint
+
+	// Background fills the box behind the text, unless it is nil.
+	Background Paint
+
+	// Underline draws a line under the text.
+	Underline bool
+
+	// Strikethrough draws a line through the middle of the lowercase letters.
+	Strikethrough bool
+
+	// BaselineOffset raises the text above the baseline, or lowers it if it
+	// is negative, as for superscripts and subscripts.
+	BaselineOffset float64
+}
+
+// A TextRun is a range of an AttributedString which has one style.
+type TextRun struct {
+	// Start and End are byte offsets into the text of the string.
+	Start int
+	End   int
+
+	Style TextStyle
+}
+
+// An AttributedString is a string whose ranges may have different fonts,
+// colors and decorations. Draw one with FillAttributedText.
+//
+// Ranges are byte offsets into the text, like the indices of a Go string.
+// Offsets inside of a grapheme, such as a letter and its accents, are moved
+// out to the edges of the grapheme, so a range never splits a character.
+// The zero value is an empty string.
+type AttributedString struct {
+	text string
+	runs []TextRun
+
+	// font is used to measure the string if it is empty.
+	font Font
+}
+
+// NewAttributedString creates an AttributedString whose text all has one
+// font and the default style.
+func NewAttributedString(text string, f Font) *AttributedString {
+	res := &AttributedString{text: text, font: f}
+	if text != "" {
+		res.runs = []TextRun{{0, len(text), TextStyle{Font: f}}}
+	}
+	return res
+}
+
+// Append adds text with a style to the end of the string.
+func (a *AttributedString) Append(text string, style TextStyle) {
+	if text == "" {
+		return
+	}
+	start := len(a.text)
+	a.text += text
+	a.runs = append(a.runs, TextRun{start, len(a.text), style})
+}
+
+// Runs returns the runs of the string in order. Together, they cover the
+// whole text.
+func (a *AttributedString) Runs() []TextRun {
+	return append([]TextRun{}, a.runs...)
+}
+
+// SetBackground sets the background of a range of the text. A nil paint
+// removes the background.
+func (a *AttributedString) SetBackground(start, end int, p Paint) {
+	a.update(start, end, func(s *TextStyle) {
+		s.Background = p
+	})
+}
+
+// SetBaselineOffset sets how far a range of the text is raised above the
+// baseline.
+func (a *AttributedString) SetBaselineOffset(start, end int, offset float64) {
+	a.update(start, end, func(s *TextStyle) {
+		s.BaselineOffset = offset
+	})
+}
+
+// SetColor sets the paint of a range of the text. A nil paint uses the fill
+// paint of the context.
+func (a *AttributedString) SetColor(start, end int, p Paint) {
+	a.update(start, end, func(s *TextStyle) {
+		s.Color = p
+	})
+}
+
+// SetFont sets the font of a range of the text.
+func (a *AttributedString) SetFont(start, end int, f Font) {
+	a.update(start, end, func(s *TextStyle) {
+		s.Font = f
+	})
+}
+
+// SetFontSize changes the size of the font of a range of the text.
+func (a *AttributedString) SetFontSize(start, end int, size float64) {
+	a.update(start, end, func(s *TextStyle) {
+		s.Font.Size = size
+	})
+}
+
+// SetFontWeight changes the weight of the font of a range of the text.
+func (a *AttributedString) SetFontWeight(start, end int, w FontWeight) {
+	a.update(start, end, func(s *TextStyle) {
+		s.Font.Weight = w
+	})
+}
+
+// SetStrikethrough sets whether a range of the text is struck through.
+func (a *AttributedString) SetStrikethrough(start, end int, on bool) {
+	a.update(start, end, func(s *TextStyle) {
+		s.Strikethrough = on
+	})
+}
+
+// SetUnderline sets whether a range of the text is underlined.
+func (a *AttributedString) SetUnderline(start, end int, on bool) {
+	a.update(start, end, func(s *TextStyle) {
+		s.Underline = on
+	})
+}
+
+// Text returns the text of the string without its attributes.
+func (a *AttributedString) Text() string {
+	return a.text
+}
+
+// split makes sure that a run starts at an offset, and returns the index of
+// that run.
+func (a *AttributedString) split(offset int) int {
+	for i, r := range a.runs {
+		if r.Start == offset {
+			return i
+		} else if r.End > offset {
+			second := r
+			second.Start = offset
+			a.runs[i].End = offset
+			a.runs = append(a.runs, TextRun{})
+			copy(a.runs[i+2:], a.runs[i+1:])
+			a.runs[i+1] = second
+			return i + 1
+		}
+	}
+	return len(a.runs)
+}
+
+// update changes the style of every run in a range, splitting runs which
+// are partly in it. The range is clipped to the text and widened to whole
+// graphemes.
+func (a *AttributedString) update(start, end int, f func(s *TextStyle)) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(a.text) {
+		end = len(a.text)
+	}
+	if start >= end {
+		return
+	}
+	for i := 0; i < end; {
+		next := nextCluster(a.text, i, len(a.text))
+		if i < start && start < next {
+			start = i
+		}
+		if end < next {
+			end = next
+		}
+		i = next
+	}
+	first := a.split(start)
+	last := a.split(end)
+	for i := first; i < last; i++ {
+		f(&a.runs[i].Style)
+	}
+}
+
+// attributedTextSize measures an AttributedString. The ascent is the distance
+// from the top of the text to its alphabetic baseline.
+func attributedTextSize(m textMeasurer, a *AttributedString) (width, height,
+	ascent float64) {
+	if len(a.runs) == 0 {
+		metrics := m.fontMetrics(a.font)
+		return 0, metrics.Ascent + metrics.Descent, metrics.Ascent
+	}
+	descent := math.Inf(-1)
+	ascent = math.Inf(-1)
+	for _, r := range a.runs {
+		w, _ := m.textSize(a.text[r.Start:r.End], r.Style.Font)
+		metrics := m.fontMetrics(r.Style.Font)
+		width += w
+		ascent = math.Max(ascent, metrics.Ascent+r.Style.BaselineOffset)
+		descent = math.Max(descent, metrics.Descent-r.Style.BaselineOffset)
+	}
+	return width, ascent + descent, ascent
+}
+
+// drawAttributedText draws an AttributedString one run at a time, with the
+// given text alignment and baseline. Every DrawContext which cannot draw an
+// AttributedString in one go uses this.
+func drawAttributedText(ctx DrawContext, a *AttributedString, x, y float64,
+	align TextAlign, baseline TextBaseline) {
+	m := layoutMeasurer(ctx)
+	width, height, ascent := attributedTextSize(m, a)
+	x, y = textOrigin(x, y, width, height, ascent, align, baseline)
+
+	// Backgrounds go under all of the text, so that they do not cover parts
+	// of glyphs which stick out of their runs.
+	widths := make([]float64, len(a.runs))
+	left := x
+	for i, r := range a.runs {
+		widths[i], _ = m.textSize(a.text[r.Start:r.End], r.Style.Font)
+		if r.Style.Background != nil {
+			ctx.Save()
+			ctx.SetFillPaint(r.Style.Background)
+			ctx.FillRect(Rect{left, y, widths[i], height})
+			ctx.Restore()
+		}
+		left += widths[i]
+	}
+
+	ctx.Save()
+	ctx.SetTextAlign(TextAlignLeft)
+	ctx.SetTextBaseline(TextBaselineAlphabetic)
+	left = x
+	for i, r := range a.runs {
+		s := r.Style
+		ctx.Save()
+		if s.Color != nil {
+			ctx.SetFillPaint(s.Color)
+		}
+		ctx.SetFontDescriptor(s.Font)
+		base := y + ascent - s.BaselineOffset
+		ctx.FillText(a.text[r.Start:r.End], left, base)
+		metrics := m.fontMetrics(s.Font)
+		thickness := metrics.UnderlineThickness
+		if s.Underline {
+			ctx.FillRect(Rect{left, base + metrics.UnderlinePosition -
+				thickness/2, widths[i], thickness})
+		}
+		if s.Strikethrough {
+			ctx.FillRect(Rect{left, base - metrics.XHeight/2 - thickness/2,
+				widths[i], thickness})
+		}
+		ctx.Restore()
+		left += widths[i]
+	}
+	ctx.Restore()
+}
@@ -0,0 +1,45 @@
+package gogui
+
+import "testing"
+
+func TestAttributedStringRuns(t *testing.T) {
+	a := NewAttributedString("hello world", Font{Size: 10})
+	a.SetColor(2, 7, Color{1, 0, 0, 1})
+	a.SetFontWeight(4, 20, FontWeightBold)
+	a.Append("!", TextStyle{Font: Font{Size: 20}, Underline: true})
+	checkRuns(t, a, [][2]int{{0, 2}, {2, 4}, {4, 7}, {7, 11}, {11, 12}})
+	runs := a.Runs()
+	if runs[2].Style.Color == nil ||
+		runs[2].Style.Font.Weight != FontWeightBold ||
+		runs[1].Style.Font.Weight != 0 || runs[3].Style.Color != nil {
+		t.Error(runs)
+	}
+	if a.Text() != "hello world!" {
+		t.Error(a.Text())
+	}
+}
+
+func TestAttributedStringGraphemes(t *testing.T) {
+	// Ranges which start or end inside of a grapheme take all of it.
+	a := NewAttributedString("a\u00e9e\u0301b", Font{Size: 10})
+	a.SetUnderline(2, 4, true)
+	checkRuns(t, a, [][2]int{{0, 1}, {1, 6}, {6, 7}})
+	a.SetColor(5, 6, Color{1, 0, 0, 1})
+	checkRuns(t, a, [][2]int{{0, 1}, {1, 3}, {3, 6}, {6, 7}})
+	runs := a.Runs()
+	if runs[1].Style.Color != nil || !runs[2].Style.Underline {
+		t.Error(runs)
+	}
+}
+
+func checkRuns(t *testing.T, a *AttributedString, want [][2]int) {
+	runs := a.Runs()
+	if len(runs) != len(want) {
+		t.Fatal(runs)
+	}
+	for i, r := range runs {
+		if r.Start != want[i][0] || r.End != want[i][1] {
+			t.Error(i, r)
+		}
+	}
+}
@@ -0,0 +1,556 @@
+// +build browser
+// +build !js
+
+package gogui
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// browserDefaultAddr is the address the HTTP server listens on when the
+// GOGUI_ADDR environment variable is not set.
+const browserDefaultAddr = "127.0.0.1:8765"
+
+// The browser driver starts an HTTP server which shows the app's windows in a
+// web browser.
+//
+// The server listens on the address in the GOGUI_ADDR environment variable,
+// or on 127.0.0.1:8765 if it is not set.
+func init() {
+	RegisterDriver("browser", newSoftApp(newBrowserHost()))
+}
+
+// A browserMessage is a JSON message sent between the server and the client.
+// Each type of message only uses some of the fields.
+type browserMessage struct {
+	Type     string           `json:"type"`
+	ID       int              `json:"id,omitempty"`
+	Window   int              `json:"window,omitempty"`
+	Title    string           `json:"title,omitempty"`
+	Frame    []float64        `json:"frame,omitempty"`
+	Showing  bool             `json:"showing,omitempty"`
+	Order    int              `json:"order,omitempty"`
+	Canvases []browserCanvas  `json:"canvases,omitempty"`
+	Event    string           `json:"event,omitempty"`
+	X        float64          `json:"x,omitempty"`
+	Y        float64          `json:"y,omitempty"`
+	KeyCode  int              `json:"keyCode,omitempty"`
+	CharCode int              `json:"charCode,omitempty"`
+	Alt      bool             `json:"alt,omitempty"`
+	Ctrl     bool             `json:"ctrl,omitempty"`
+	Meta     bool             `json:"meta,omitempty"`
+	Shift    bool             `json:"shift,omitempty"`
+	Font     string           `json:"font,omitempty"`
+	Size     float64          `json:"size,omitempty"`
+	Text     string           `json:"text,omitempty"`
+	Texts    []string         `json:"texts,omitempty"`
+	Width    float64          `json:"width,omitempty"`
+	Widths   []float64        `json:"widths,omitempty"`
+	Height   float64          `json:"height,omitempty"`
+	Metrics  []float64        `json:"metrics,omitempty"`
+	Commands []browserCommand `json:"commands,omitempty"`
+}
+
+// A browserCanvas is the content of one canvas in a draw message.
+type browserCanvas struct {
+	ID       int              `json:"id"`
+	Frame    []float64        `json:"frame"`
+	Commands []browserCommand `json:"commands"`
+}
+
+// A browserCommand encodes a drawCommand as a JSON array. The first element is
+// the name of the DrawContext method, followed by the numeric arguments and,
+// if the method takes one, the string argument. The pixels of DrawImage are
+// encoded as base64.
+type browserCommand drawCommand
+
+func (b browserCommand) MarshalJSON() ([]byte, error) {
+	list := make([]interface{}, 0, len(b.args)+2)
+	list = append(list, b.op.String())
+	for _, x := range b.args {
+		// JSON cannot represent infinities or NaN.
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			x = 0
+		}
+		list = append(list, x)
+	}
+	if b.op == drawOpFillText || b.op == drawOpStrokeText ||
+		b.op == drawOpSetFont {
+		list = append(list, b.text)
+	} else if b.op == drawOpDrawImage {
+		list = append(list, base64.StdEncoding.EncodeToString([]byte(b.text)))
+	} else if b.op == drawOpSetFontDescriptor && len(b.args) == 4 {
+		list = append(list, cssFont(decodeFont(b.text, b.args)))
+	}
+	return json.Marshal(list)
+}
+
+// A browserClient is a connected web page.
+type browserClient struct {
+	ws       *wsConn
+	outgoing chan []byte
+}
+
+// A browserHost presents windows as panels in connected web pages. Draw
+// handlers are recorded and replayed on HTML5 canvases by the client.
+type browserHost struct {
+	app     *softApp
+	info    *AppInfo
+	host    string // the host in the listen address
+	clients []*browserClient
+
+	windowIDs map[*softWindow]int
+	canvasIDs map[*softCanvas]int
+	nextID    int
+
+	// measureLock protects the fields used to measure text. They are
+	// accessed by the main goroutine and by the client reader goroutines.
+	measureLock    sync.Mutex
+	measureClient  *browserClient
+	measureCache   map[browserMeasureKey]browserMeasurement
+	measureWaiting map[int]browserMeasureRequest
+	measurePending map[browserMeasureKey]bool
+	nextMeasureID  int
+}
+
+type browserMeasureKey struct {
+	text string
+	font string
+}
+
+// A browserMeasurement is the size of a text and the metrics of its font, as
+// measured by a browser.
+type browserMeasurement struct {
+	width   float64
+	height  float64
+	metrics FontMetrics
+}
+
+// A browserMeasureRequest is a measure message which has not been answered.
+type browserMeasureRequest struct {
+	texts []string
+	font  string
+	size  float64
+}
+
+func newBrowserHost() *browserHost {
+	return &browserHost{
+		windowIDs:      map[*softWindow]int{},
+		canvasIDs:      map[*softCanvas]int{},
+		measureCache:   map[browserMeasureKey]browserMeasurement{},
+		measureWaiting: map[int]browserMeasureRequest{},
+		measurePending: map[browserMeasureKey]bool{},
+	}
+}
+
+func (b *browserHost) available() bool {
+	return true
+}
+
+func (b *browserHost) start(a *softApp, info *AppInfo) error {
+	b.app = a
+	b.info = info
+	addr := os.Getenv("GOGUI_ADDR")
+	if addr == "" {
+		addr = browserDefaultAddr
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	b.host, _, _ = net.SplitHostPort(addr)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", b.serveIndex)
+	mux.HandleFunc("/socket", b.serveSocket)
+	go http.Serve(listener, mux)
+	fmt.Fprintf(os.Stderr, "gogui: open http://%s/ in a web browser\n",
+		listener.Addr())
+	return nil
+}
+
+func (b *browserHost) showWindow(w *softWindow) {
+	b.broadcast(b.windowMessage(w))
+}
+
+func (b *browserHost) hideWindow(w *softWindow) {
+	b.broadcast(b.windowMessage(w))
+}
+
+func (b *browserHost) updateWindow(w *softWindow) {
+	for _, x := range b.app.windows {
+		b.broadcast(b.windowMessage(x))
+	}
+}
+
+func (b *browserHost) drawWindow(w *softWindow) {
+}
+
+func (b *browserHost) paintWindow(w *softWindow) {
+	b.broadcast(b.drawMessage(w))
+}
+
+func (b *browserHost) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	name := "gogui"
+	if b.info != nil && b.info.Name != "" {
+		name = b.info.Name
+	}
+	browserPage.Execute(w, name)
+}
+
+func (b *browserHost) serveSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := acceptWebSocket(w, r, b.host)
+	if err != nil {
+		return
+	}
+	client := &browserClient{ws: ws, outgoing: make(chan []byte, 256)}
+	go func() {
+		var failed bool
+		for msg := range client.outgoing {
+			if failed {
+				// Keep draining until the reader notices that the
+				// connection is closed.
+				continue
+			}
+			if ws.WriteMessage(msg) != nil {
+				failed = true
+				ws.Close()
+			}
+		}
+	}()
+	b.app.RunOnMain(func() {
+		b.addClient(client)
+	})
+	for {
+		data, err := ws.ReadMessage()
+		if err != nil {
+			break
+		}
+		var msg browserMessage
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		if msg.Type == "measure" {
+			b.measured(&msg)
+			continue
+		}
+		b.app.RunOnMain(func() {
+			b.handleMessage(&msg)
+		})
+	}
+	ws.Close()
+	b.app.RunOnMain(func() {
+		b.removeClient(client)
+	})
+}
+
+func (b *browserHost) addClient(c *browserClient) {
+	b.clients = append(b.clients, c)
+	b.measureLock.Lock()
+	if b.measureClient == nil {
+		b.measureClient = c
+	}
+	b.measureLock.Unlock()
+	for _, w := range b.app.windows {
+		b.send(c, b.windowMessage(w))
+	}
+	b.redraw()
+}
+
+func (b *browserHost) removeClient(c *browserClient) {
+	for i, x := range b.clients {
+		if x == c {
+			copy(b.clients[i:], b.clients[i+1:])
+			b.clients[len(b.clients)-1] = nil
+			b.clients = b.clients[:len(b.clients)-1]
+			close(c.outgoing)
+			break
+		}
+	}
+	b.measureLock.Lock()
+	replaced := b.measureClient == c
+	if replaced {
+		// The client will not answer the questions it was sent, so they
+		// are asked again when the windows are redrawn.
+		b.measureClient = nil
+		if len(b.clients) > 0 {
+			b.measureClient = b.clients[0]
+		}
+		b.measureWaiting = map[int]browserMeasureRequest{}
+		b.measurePending = map[browserMeasureKey]bool{}
+	}
+	b.measureLock.Unlock()
+	if replaced {
+		b.redraw()
+	}
+}
+
+func (b *browserHost) handleMessage(msg *browserMessage) {
+	var w *softWindow
+	for x, id := range b.windowIDs {
+		if id == msg.Window {
+			w = x
+		}
+	}
+	if w == nil || !w.showing {
+		return
+	}
+	switch msg.Type {
+	case "mouse":
+		eventTypes := map[string]int{"down": mouseEventDown,
+			"drag": mouseEventDrag, "move": mouseEventMove, "up": mouseEventUp}
+		if t, ok := eventTypes[msg.Event]; ok {
+			w.mouseEvent(t, msg.X, msg.Y)
+		}
+	case "key":
+		flags := 0
+		if msg.Alt {
+			flags |= keyFlagAlt
+		}
+		if msg.Ctrl {
+			flags |= keyFlagCtrl
+		}
+		if msg.Meta {
+			flags |= keyFlagMeta
+		}
+		if msg.Shift {
+			flags |= keyFlagShift
+		}
+		switch msg.Event {
+		case "down":
+			w.keyEvent(keyEventDown, msg.KeyCode, msg.KeyCode, -1, flags)
+		case "press":
+			evt := makeKeyEvent(msg.KeyCode, msg.CharCode, flags)
+			if h := w.KeyPressHandler(); h != nil {
+				h(evt)
+			}
+		case "up":
+			w.keyEvent(keyEventUp, msg.KeyCode, msg.KeyCode, -1, flags)
+		}
+	case "focus":
+		w.Focus()
+	case "move":
+		if len(msg.Frame) == 4 {
+			w.frame.X, w.frame.Y = msg.Frame[0], msg.Frame[1]
+		}
+	case "close":
+		w.userClosed()
+	}
+}
+
+func (b *browserHost) windowMessage(w *softWindow) *browserMessage {
+	order := 0
+	for i, x := range b.app.windows {
+		if x == w {
+			order = i + 1
+		}
+	}
+	f := w.frame
+	return &browserMessage{
+		Type:    "window",
+		ID:      b.windowID(w),
+		Title:   w.title,
+		Frame:   []float64{f.X, f.Y, f.Width, f.Height},
+		Showing: w.showing,
+		Order:   order,
+	}
+}
+
+func (b *browserHost) drawMessage(w *softWindow) *browserMessage {
+	msg := &browserMessage{Type: "draw", Window: b.windowID(w),
+		Canvases: []browserCanvas{}}
+	for _, widget := range w.widgets {
+		c := widget.(*softCanvas)
+		if _, ok := b.canvasIDs[c]; !ok {
+			b.nextID++
+			b.canvasIDs[c] = b.nextID
+		}
+		rec := newDrawRecorder(b)
+		if c.handler != nil {
+			c.handler(rec)
+		}
+		commands := make([]browserCommand, len(rec.commands))
+		for i, x := range rec.commands {
+			commands[i] = browserCommand(x)
+		}
+		f := c.frame
+		msg.Canvases = append(msg.Canvases, browserCanvas{
+			ID:       b.canvasIDs[c],
+			Frame:    []float64{f.X, f.Y, f.Width, f.Height},
+			Commands: commands,
+		})
+	}
+	return msg
+}
+
+func (b *browserHost) windowID(w *softWindow) int {
+	if id, ok := b.windowIDs[w]; ok {
+		return id
+	}
+	b.nextID++
+	b.windowIDs[w] = b.nextID
+	return b.nextID
+}
+
+func (b *browserHost) broadcast(msg *browserMessage) {
+	for _, c := range b.clients {
+		b.send(c, msg)
+	}
+}
+
+func (b *browserHost) send(c *browserClient, msg *browserMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	select {
+	case c.outgoing <- data:
+	default:
+		// The client is not keeping up; drop it and let it reconnect.
+		c.ws.Close()
+	}
+}
+
+// fontMetrics asks a connected browser for the metrics of a font. Browsers do
+// not report the leading or the underline, so those are estimated.
+func (b *browserHost) fontMetrics(f Font) FontMetrics {
+	return b.measure("", f).metrics
+}
+
+// textSize asks a connected browser for the size of text.
+func (b *browserHost) textSize(text string, f Font) (float64, float64) {
+	res := b.measure(text, f)
+	return res.width, res.height
+}
+
+// textWidths measures many strings with one message to the browser.
+func (b *browserHost) textWidths(texts []string, f Font) []float64 {
+	font := cssFont(f)
+	res := make([]float64, len(texts))
+	var missing []string
+	b.measureLock.Lock()
+	for i, text := range texts {
+		if m, ok := b.measureCache[browserMeasureKey{text, font}]; ok {
+			res[i] = m.width
+		} else {
+			res[i], _ = softTextSize(text, f)
+			missing = append(missing, text)
+		}
+	}
+	b.measureLock.Unlock()
+	if len(missing) > 0 {
+		b.ask(missing, f)
+	}
+	return res
+}
+
+// ask sends a measure message to the browser which measures text, leaving out
+// texts which it was already asked about. It does not wait for the answer,
+// since nothing could be drawn in the meantime.
+func (b *browserHost) ask(texts []string, f Font) {
+	font := cssFont(f)
+	b.measureLock.Lock()
+	client := b.measureClient
+	if client == nil {
+		b.measureLock.Unlock()
+		return
+	}
+	var missing []string
+	for _, text := range texts {
+		key := browserMeasureKey{text, font}
+		if !b.measurePending[key] {
+			b.measurePending[key] = true
+			missing = append(missing, text)
+		}
+	}
+	if len(missing) == 0 {
+		b.measureLock.Unlock()
+		return
+	}
+	b.nextMeasureID++
+	id := b.nextMeasureID
+	b.measureWaiting[id] = browserMeasureRequest{texts: missing, font: font,
+		size: f.Size}
+	b.measureLock.Unlock()
+
+	b.send(client, &browserMessage{Type: "measure", ID: id, Texts: missing,
+		Size: f.Size, Font: font})
+}
+
+// measure looks up the size of text in a font. Until a connected browser has
+// measured it, the built-in font metrics are used instead.
+func (b *browserHost) measure(text string, f Font) browserMeasurement {
+	key := browserMeasureKey{text, cssFont(f)}
+	b.measureLock.Lock()
+	res, ok := b.measureCache[key]
+	b.measureLock.Unlock()
+	if ok {
+		return res
+	}
+	b.ask([]string{text}, f)
+	return softMeasurement(text, f)
+}
+
+// measured is called from a client's reader goroutine when the client answers
+// a measure message. The windows are redrawn, since they were drawn with the
+// built-in font metrics.
+func (b *browserHost) measured(msg *browserMessage) {
+	b.measureLock.Lock()
+	req, ok := b.measureWaiting[msg.ID]
+	delete(b.measureWaiting, msg.ID)
+	for _, text := range req.texts {
+		delete(b.measurePending, browserMeasureKey{text, req.font})
+	}
+	if !ok || len(msg.Widths) != len(req.texts) {
+		b.measureLock.Unlock()
+		return
+	}
+	var metrics FontMetrics
+	if len(msg.Metrics) == 4 {
+		metrics = FontMetrics{
+			Ascent:    msg.Metrics[0],
+			Descent:   msg.Metrics[1],
+			XHeight:   msg.Metrics[2],
+			CapHeight: msg.Metrics[3],
+		}
+	}
+	estimateUnderline(&metrics, req.size)
+	if len(b.measureCache) > 4096 {
+		b.measureCache = map[browserMeasureKey]browserMeasurement{}
+	}
+	for i, text := range req.texts {
+		b.measureCache[browserMeasureKey{text, req.font}] = browserMeasurement{
+			width: msg.Widths[i], height: msg.Height, metrics: metrics}
+	}
+	b.measureLock.Unlock()
+	b.app.RunOnMain(b.redraw)
+}
+
+// redraw draws every window again.
+func (b *browserHost) redraw() {
+	for _, w := range b.app.windows {
+		w.invalidate()
+	}
+}
+
+// softMeasurement measures text with the built-in font, for when no browser
+// can do it.
+func softMeasurement(text string, f Font) browserMeasurement {
+	width, height := softTextSize(text, f)
+	return browserMeasurement{width: width, height: height,
+		metrics: softFontMetrics(f)}
+}
+
+var browserPage = template.Must(template.New("page").Parse(browserPageSource))
@@ -0,0 +1,61 @@
+// +build browser
+// +build !js
+
+package gogui
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBrowserTextWidths(t *testing.T) {
+	b := newBrowserHost()
+	b.app = newSoftApp(nil)
+	client := &browserClient{outgoing: make(chan []byte, 1)}
+	b.measureClient = client
+	answered := make(chan int)
+	go func() {
+		defer close(answered)
+		for data := range client.outgoing {
+			var msg browserMessage
+			json.Unmarshal(data, &msg)
+			answer := &browserMessage{Type: "measure", ID: msg.ID, Height: 12,
+				Metrics: []float64{10, 2, 5, 7}}
+			for _, text := range msg.Texts {
+				answer.Widths = append(answer.Widths, float64(len(text)))
+			}
+			b.measured(answer)
+			answered <- len(msg.Texts)
+		}
+	}()
+
+	f := Font{Size: 10}
+	texts := []string{"a", "bcd", "", "a"}
+	widths := b.textWidths(texts, f)
+	for i, text := range texts {
+		if w, _ := softTextSize(text, f); widths[i] != w {
+			t.Error("expected the built-in width of", text)
+		}
+	}
+	if n := <-answered; n != 3 {
+		t.Error("expected 3 texts to be measured but got", n)
+	}
+	widths = b.textWidths(texts, f)
+	if widths[0] != 1 || widths[1] != 3 || widths[2] != 0 || widths[3] != 1 {
+		t.Error(widths)
+	}
+
+	textPositions(b, f, "hello")
+	<-answered
+	p := textPositions(b, f, "hello")
+	if p.Width() != 5 || len(p.Graphemes) != 5 {
+		t.Error(p.Graphemes)
+	}
+	if w, h := b.textSize("bcd", f); w != 3 || h != 12 {
+		t.Error("measurement was not cached:", w, h)
+	}
+	close(client.outgoing)
+	if _, ok := <-answered; ok {
+		t.Error("unexpected measure message")
+	}
+}
@@ -0,0 +1,699 @@
+// +build browser
+// +build !js
+
+package gogui
+
+// browserPageSource is the template for the page which shows the app. The
+// script connects back to the server over a WebSocket, replays recorded draw
+// commands on HTML5 canvases, and reports input events.
+const browserPageSource = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<style>
+body {
+	margin: 0;
+	background: #5c6a7a;
+	font-family: Helvetica, Arial, sans-serif;
+	overflow: hidden;
+}
+.gogui-window {
+	position: absolute;
+	background: #ececec;
+	box-shadow: 0 4px 16px rgba(0, 0, 0, 0.4);
+	border-radius: 4px 4px 0 0;
+}
+.gogui-title {
+	height: 22px;
+	line-height: 22px;
+	font-size: 13px;
+	text-align: center;
+	background: #dcdcdc;
+	border-radius: 4px 4px 0 0;
+	cursor: default;
+	user-select: none;
+	position: relative;
+}
+.gogui-close {
+	position: absolute;
+	left: 6px;
+	top: 5px;
+	width: 12px;
+	height: 12px;
+	border-radius: 6px;
+	background: #fc605c;
+}
+.gogui-content {
+	position: relative;
+	overflow: hidden;
+	outline: none;
+}
+.gogui-content canvas {
+	position: absolute;
+}
+</style>
+</head>
+<body>
+<script>
+(function() {
+	var PRESS_CODES = {Backspace: 8, Tab: 9, Enter: 13, Escape: 27,
+		Delete: 46, ArrowLeft: 37, ArrowUp: 38, ArrowRight: 39,
+		ArrowDown: 40};
+	var MODIFIERS = {Shift: true, Control: true, Alt: true, Meta: true,
+		CapsLock: true};
+	// COMPOSITE_MODES is in the order of the CompositeMode constants.
+	var COMPOSITE_MODES = ['source-over', 'source-in', 'source-out',
+		'source-atop', 'destination-over', 'destination-in', 'destination-out',
+		'destination-atop', 'copy', 'xor', 'lighter', 'multiply', 'screen',
+		'overlay', 'darken', 'lighten'];
+
+	var protocol = (location.protocol === 'https:' ? 'wss://' : 'ws://');
+	var socket = new WebSocket(protocol + location.host + '/socket');
+	var windows = {};
+	var activeWindow = null;
+	var mouseWindow = null;
+	var measureContext = document.createElement('canvas').getContext('2d');
+
+	function send(msg) {
+		if (socket.readyState === 1) {
+			socket.send(JSON.stringify(msg));
+		}
+	}
+
+	function cssFont(size, name) {
+		return size + 'px "' + name + '", Helvetica, sans-serif';
+	}
+
+	function cssColor(c, offset) {
+		var r = Math.round(c[offset] * 255);
+		var g = Math.round(c[offset + 1] * 255);
+		var b = Math.round(c[offset + 2] * 255);
+		return 'rgba(' + r + ',' + g + ',' + b + ',' + c[offset + 3] + ')';
+	}
+
+	// gradientStyle creates a CanvasGradient from the arguments of a
+	// SetFillGradient or SetStrokeGradient command. A canvas can only pad a
+	// gradient, so other extend modes repeat the stops across the canvas, like
+	// gradient.padded does in Go.
+	function gradientStyle(ctx, c) {
+		var g = {radial: c[1] !== 0, extend: c[2], x1: c[3], y1: c[4],
+			r1: c[5], x2: c[6], y2: c[7], r2: c[8], stops: []};
+		for (var i = 9; i + 4 < c.length; i += 5) {
+			g.stops.push({offset: c[i], color: c.slice(i + 1, i + 5)});
+		}
+		if (g.extend !== 0 && g.stops.length > 0) {
+			g = paddedGradient(g, canvasCorners(ctx));
+		}
+		var res;
+		if (g.radial) {
+			res = ctx.createRadialGradient(g.x1, g.y1, g.r1, g.x2, g.y2, g.r2);
+		} else {
+			res = ctx.createLinearGradient(g.x1, g.y1, g.x2, g.y2);
+		}
+		g.stops.forEach(function(s) {
+			res.addColorStop(Math.max(0, Math.min(1, s.offset)),
+				cssColor(s.color, 0));
+		});
+		return res;
+	}
+
+	function canvasCorners(ctx) {
+		var inv = ctx.getTransform().inverse();
+		var w = ctx.canvas.width, h = ctx.canvas.height;
+		return [[0, 0], [w, 0], [0, h], [w, h]].map(function(p) {
+			return inv.transformPoint(new DOMPoint(p[0], p[1]));
+		});
+	}
+
+	function gradientParam(g, p) {
+		var dx = g.x2 - g.x1, dy = g.y2 - g.y1;
+		var px = p.x - g.x1, py = p.y - g.y1;
+		if (!g.radial) {
+			var lenSq = dx * dx + dy * dy;
+			return lenSq === 0 ? null : (px * dx + py * dy) / lenSq;
+		}
+		var dr = g.r2 - g.r1;
+		var a = dx * dx + dy * dy - dr * dr;
+		var b = px * dx + py * dy + g.r1 * dr;
+		var c = px * px + py * py - g.r1 * g.r1;
+		var roots;
+		if (a === 0) {
+			if (b === 0) {
+				return null;
+			}
+			roots = [c / (2 * b)];
+		} else {
+			var disc = b * b - a * c;
+			if (disc < 0) {
+				return null;
+			}
+			var s = Math.sqrt(disc);
+			roots = [(b + s) / a, (b - s) / a].sort(function(x, y) {
+				return y - x;
+			});
+		}
+		for (var i = 0; i < roots.length; i++) {
+			if (g.r1 + roots[i] * dr >= 0) {
+				return roots[i];
+			}
+		}
+		return null;
+	}
+
+	function gradientColor(g, t) {
+		if (g.extend === 1) {
+			t -= Math.floor(t);
+		} else if (g.extend === 2) {
+			t = Math.abs(t) % 2;
+			if (t > 1) {
+				t = 2 - t;
+			}
+		}
+		var stops = g.stops;
+		if (!(t > stops[0].offset)) {
+			return stops[0].color;
+		} else if (t >= stops[stops.length - 1].offset) {
+			return stops[stops.length - 1].color;
+		}
+		var i = 1;
+		while (stops[i].offset <= t) {
+			i++;
+		}
+		var s1 = stops[i - 1], s2 = stops[i];
+		var frac = (t - s1.offset) / (s2.offset - s1.offset);
+		return s1.color.map(function(x, j) {
+			return x + (s2.color[j] - x) * frac;
+		});
+	}
+
+	function paddedGradient(g, area) {
+		var t0 = 0, t1 = 1;
+		area.forEach(function(p) {
+			var t = gradientParam(g, p);
+			if (t !== null) {
+				t0 = Math.min(t0, t);
+				t1 = Math.max(t1, t);
+			}
+		});
+		var dr = g.r2 - g.r1;
+		if (g.radial && dr > 0) {
+			t0 = -g.r1 / dr;
+		} else if (g.radial && dr < 0) {
+			t1 = Math.min(t1, -g.r1 / dr);
+		}
+		var lo = Math.max(t0, t1 - 1000);
+		t1 = Math.min(t1, t0 + 1000);
+		t0 = lo;
+		var res = {radial: g.radial, extend: 0,
+			x1: g.x1 + (g.x2 - g.x1) * t0, y1: g.y1 + (g.y2 - g.y1) * t0,
+			r1: g.r1 + dr * t0, x2: g.x1 + (g.x2 - g.x1) * t1,
+			y2: g.y1 + (g.y2 - g.y1) * t1, r2: g.r1 + dr * t1, stops: []};
+		function addStop(t, color) {
+			var offset = t1 > t0 ? (t - t0) / (t1 - t0) : 0;
+			res.stops.push({offset: offset, color: color});
+		}
+		addStop(t0, gradientColor(g, t0));
+		var stops = g.stops.slice();
+		if (stops[0].offset > 0) {
+			stops.unshift({offset: 0, color: stops[0].color});
+		}
+		if (stops[stops.length - 1].offset < 1) {
+			stops.push({offset: 1, color: stops[stops.length - 1].color});
+		}
+		for (var period = Math.floor(t0); period < t1; period++) {
+			for (var i = 0; i < stops.length; i++) {
+				var stop = stops[i];
+				if (g.extend === 2 && period % 2 !== 0) {
+					stop = stops[stops.length - 1 - i];
+					stop = {offset: 1 - stop.offset, color: stop.color};
+				}
+				var t = period + stop.offset;
+				if (t > t0 && t < t1) {
+					addStop(t, stop.color);
+				}
+			}
+		}
+		addStop(t1, gradientColor(g, t1));
+		return res;
+	}
+
+	function contentPoint(w, e) {
+		var rect = w.content.getBoundingClientRect();
+		return {x: e.clientX - rect.left, y: e.clientY - rect.top};
+	}
+
+	function createWindow(id) {
+		var w = {id: id, canvases: {}, frame: [0, 0, 0, 0]};
+		w.panel = document.createElement('div');
+		w.panel.className = 'gogui-window';
+		w.titleBar = document.createElement('div');
+		w.titleBar.className = 'gogui-title';
+		w.titleText = document.createElement('span');
+		w.closeButton = document.createElement('div');
+		w.closeButton.className = 'gogui-close';
+		w.content = document.createElement('div');
+		w.content.className = 'gogui-content';
+		w.content.tabIndex = 0;
+		w.titleBar.appendChild(w.closeButton);
+		w.titleBar.appendChild(w.titleText);
+		w.panel.appendChild(w.titleBar);
+		w.panel.appendChild(w.content);
+		document.body.appendChild(w.panel);
+
+		w.closeButton.addEventListener('mousedown', function(e) {
+			e.stopPropagation();
+			send({type: 'close', window: id});
+		});
+		w.titleBar.addEventListener('mousedown', function(e) {
+			activate(w);
+			var startX = e.clientX, startY = e.clientY;
+			var frameX = w.frame[0], frameY = w.frame[1];
+			function move(e) {
+				w.frame[0] = frameX + e.clientX - startX;
+				w.frame[1] = frameY + e.clientY - startY;
+				layout(w);
+			}
+			function up() {
+				document.removeEventListener('mousemove', move);
+				document.removeEventListener('mouseup', up);
+				send({type: 'move', window: id, frame: w.frame});
+			}
+			document.addEventListener('mousemove', move);
+			document.addEventListener('mouseup', up);
+			e.preventDefault();
+		});
+		w.content.addEventListener('mousedown', function(e) {
+			if (e.button !== 0) {
+				return;
+			}
+			activate(w);
+			mouseWindow = w;
+			var p = contentPoint(w, e);
+			send({type: 'mouse', window: id, event: 'down', x: p.x, y: p.y});
+			e.preventDefault();
+		});
+		w.content.addEventListener('mousemove', function(e) {
+			if (mouseWindow === null) {
+				var p = contentPoint(w, e);
+				send({type: 'mouse', window: id, event: 'move', x: p.x,
+					y: p.y});
+			}
+		});
+		return w;
+	}
+
+	function activate(w) {
+		if (activeWindow !== w) {
+			activeWindow = w;
+			send({type: 'focus', window: w.id});
+		}
+		w.content.focus();
+	}
+
+	function layout(w) {
+		w.panel.style.left = w.frame[0] + 'px';
+		w.panel.style.top = w.frame[1] + 'px';
+		w.content.style.width = w.frame[2] + 'px';
+		w.content.style.height = w.frame[3] + 'px';
+	}
+
+	function updateWindow(msg) {
+		var w = windows[msg.id];
+		if (!w) {
+			w = windows[msg.id] = createWindow(msg.id);
+		}
+		w.frame = msg.frame;
+		w.titleText.textContent = msg.title || '';
+		w.panel.style.display = (msg.showing ? 'block' : 'none');
+		w.panel.style.zIndex = msg.order || 0;
+		layout(w);
+		if (!msg.showing && activeWindow === w) {
+			activeWindow = null;
+		}
+	}
+
+	function drawWindow(msg) {
+		var w = windows[msg.window];
+		if (!w) {
+			return;
+		}
+		var seen = {};
+		msg.canvases.forEach(function(c) {
+			seen[c.id] = true;
+			var canvas = w.canvases[c.id];
+			if (!canvas) {
+				canvas = w.canvases[c.id] = document.createElement('canvas');
+				w.content.appendChild(canvas);
+			}
+			var scale = window.devicePixelRatio || 1;
+			canvas.style.left = c.frame[0] + 'px';
+			canvas.style.top = c.frame[1] + 'px';
+			canvas.style.width = c.frame[2] + 'px';
+			canvas.style.height = c.frame[3] + 'px';
+			canvas.width = Math.ceil(c.frame[2] * scale);
+			canvas.height = Math.ceil(c.frame[3] * scale);
+			var ctx = canvas.getContext('2d');
+			ctx.scale(scale, scale);
+			replay(ctx, c.commands);
+		});
+		Object.keys(w.canvases).forEach(function(id) {
+			if (!seen[id]) {
+				w.content.removeChild(w.canvases[id]);
+				delete w.canvases[id];
+			}
+		});
+	}
+
+	function replay(ctx, commands) {
+		// These defaults match the drawing context on OS X.
+		ctx.lineCap = 'round';
+		ctx.lineJoin = 'round';
+		ctx.textBaseline = 'alphabetic';
+		ctx.font = cssFont(18, 'Helvetica');
+		ctx.imageSmoothingEnabled = true;
+		ctx.imageSmoothingQuality = 'low';
+		// SetTransform is relative to the transform which the canvas starts
+		// with, which accounts for the pixel ratio.
+		var base = ctx.getTransform();
+		// Shadows are not affected by the transform, so they are scaled by
+		// the pixel ratio here.
+		var ratio = Math.sqrt(Math.abs(base.a * base.d - base.b * base.c));
+		// Gradients are created right before they are used, since their
+		// coordinates are relative to the transform at that time.
+		var fill = null, stroke = null, saved = [];
+		// The fill rule is not part of the canvas state, so it is passed to
+		// fill and clip.
+		var rule = 'nonzero';
+		// Baselines are relative to the box which TextSize measures, so text
+		// is drawn on the alphabetic baseline at an offset from the point.
+		var baseline = 0, size = 18;
+		commands.forEach(function(c) {
+			var p;
+			if (fill && /^Fill/.test(c[0])) {
+				ctx.fillStyle = gradientStyle(ctx, fill);
+			} else if (stroke && /^Stroke/.test(c[0])) {
+				ctx.strokeStyle = gradientStyle(ctx, stroke);
+			}
+			switch (c[0]) {
+			case 'Arc':
+				ctx.arc(c[1], c[2], Math.max(0, c[3]), c[4], c[5], !c[6]);
+				break;
+			case 'ArcTo':
+				ctx.arcTo(c[1], c[2], c[3], c[4], Math.max(0, c[5]));
+				break;
+			case 'BeginPath':
+				ctx.beginPath();
+				break;
+			case 'ClearShadow':
+				ctx.shadowColor = 'rgba(0,0,0,0)';
+				break;
+			case 'ClipPath':
+				ctx.clip(rule);
+				ctx.beginPath();
+				break;
+			case 'ClipRect':
+				p = new Path2D();
+				p.rect(c[1], c[2], c[3], c[4]);
+				ctx.clip(p);
+				break;
+			case 'ClosePath':
+				ctx.closePath();
+				break;
+			case 'CubicTo':
+				ctx.bezierCurveTo(c[1], c[2], c[3], c[4], c[5], c[6]);
+				break;
+			case 'DrawImage':
+				if (c[5] > 0 && c[6] > 0 && c[9] && c[10]) {
+					// drawImage does not flip images for negative sizes.
+					ctx.save();
+					ctx.translate(c[7], c[8]);
+					ctx.scale(Math.sign(c[9]), Math.sign(c[10]));
+					ctx.drawImage(imageCanvas(c), c[3], c[4], c[5], c[6], 0, 0,
+						Math.abs(c[9]), Math.abs(c[10]));
+					ctx.restore();
+				}
+				break;
+			case 'FillEllipse':
+			case 'StrokeEllipse':
+				p = new Path2D();
+				p.ellipse(c[1] + c[3] / 2, c[2] + c[4] / 2, Math.abs(c[3] / 2),
+					Math.abs(c[4] / 2), 0, 0, 2 * Math.PI);
+				if (c[0] === 'FillEllipse') {
+					ctx.fill(p);
+				} else {
+					ctx.stroke(p);
+				}
+				break;
+			case 'FillPath':
+				ctx.fill(rule);
+				ctx.beginPath();
+				break;
+			case 'FillRect':
+				ctx.fillRect(c[1], c[2], c[3], c[4]);
+				break;
+			case 'FillText':
+				ctx.fillText(c[3], c[1], c[2] + baselineOffset(ctx, c[3],
+					baseline, size));
+				break;
+			case 'LineTo':
+				ctx.lineTo(c[1], c[2]);
+				break;
+			case 'MoveTo':
+				ctx.moveTo(c[1], c[2]);
+				break;
+			case 'QuadraticTo':
+				ctx.quadraticCurveTo(c[1], c[2], c[3], c[4]);
+				break;
+			case 'Restore':
+				ctx.restore();
+				if (saved.length > 0) {
+					p = saved.pop();
+					fill = p[0];
+					stroke = p[1];
+					rule = p[2];
+					baseline = p[3];
+					size = p[4];
+				}
+				break;
+			case 'Rotate':
+				ctx.rotate(c[1]);
+				break;
+			case 'Save':
+				ctx.save();
+				saved.push([fill, stroke, rule, baseline, size]);
+				break;
+			case 'Scale':
+				ctx.scale(c[1], c[2]);
+				break;
+			case 'SetCompositeMode':
+				ctx.globalCompositeOperation = COMPOSITE_MODES[c[1]];
+				break;
+			case 'SetFill':
+				ctx.fillStyle = cssColor(c, 1);
+				fill = null;
+				break;
+			case 'SetFillGradient':
+				fill = c;
+				break;
+			case 'SetFillRule':
+				rule = c[1] === 1 ? 'evenodd' : 'nonzero';
+				break;
+			case 'SetFont':
+				ctx.font = cssFont(c[1], c[2]);
+				size = c[1];
+				break;
+			case 'SetFontDescriptor':
+				ctx.font = c[5];
+				size = c[1];
+				break;
+			case 'SetGlobalAlpha':
+				ctx.globalAlpha = c[1];
+				break;
+			case 'SetInterpolation':
+				ctx.imageSmoothingEnabled = c[1] !== 0;
+				ctx.imageSmoothingQuality = c[1] === 2 ? 'high' : 'low';
+				break;
+			case 'SetLineCap':
+				ctx.lineCap = ['butt', 'round', 'square'][c[1]];
+				break;
+			case 'SetLineDash':
+				p = c.slice(2);
+				if (p.every(function(x) { return isFinite(x) && x >= 0; })) {
+					ctx.setLineDash(p);
+					ctx.lineDashOffset = c[1];
+				}
+				break;
+			case 'SetLineJoin':
+				ctx.lineJoin = ['miter', 'round', 'bevel'][c[1]];
+				break;
+			case 'SetMiterLimit':
+				ctx.miterLimit = c[1];
+				break;
+			case 'SetShadow':
+				if (c.slice(1, 4).every(isFinite) && c[3] >= 0) {
+					ctx.shadowOffsetX = c[1] * ratio;
+					ctx.shadowOffsetY = c[2] * ratio;
+					ctx.shadowBlur = c[3] * ratio;
+					ctx.shadowColor = cssColor(c, 4);
+				}
+				break;
+			case 'SetStroke':
+				ctx.strokeStyle = cssColor(c, 1);
+				stroke = null;
+				break;
+			case 'SetStrokeGradient':
+				stroke = c;
+				break;
+			case 'SetTextAlign':
+				ctx.textAlign = ['left', 'center', 'right', 'start',
+					'end'][c[1]];
+				break;
+			case 'SetTextBaseline':
+				baseline = c[1];
+				break;
+			case 'SetThickness':
+				ctx.lineWidth = c[1];
+				break;
+			case 'SetTransform':
+				ctx.setTransform(base);
+				ctx.transform(c[1], c[2], c[3], c[4], c[5], c[6]);
+				break;
+			case 'StrokePath':
+				ctx.stroke();
+				ctx.beginPath();
+				break;
+			case 'StrokeRect':
+				ctx.strokeRect(c[1], c[2], c[3], c[4]);
+				break;
+			case 'StrokeText':
+				ctx.strokeText(c[3], c[1], c[2] + baselineOffset(ctx, c[3],
+					baseline, size));
+				break;
+			case 'Transform':
+				ctx.transform(c[1], c[2], c[3], c[4], c[5], c[6]);
+				break;
+			case 'Translate':
+				ctx.translate(c[1], c[2]);
+				break;
+			}
+		});
+	}
+
+	// baselineOffset returns how far below the point given to FillText or
+	// StrokeText the alphabetic baseline of the text is.
+	function baselineOffset(ctx, text, baseline, size) {
+		var m = ctx.measureText(text);
+		var ascent = size, descent = size * 0.2;
+		if (m.fontBoundingBoxAscent !== undefined) {
+			ascent = m.fontBoundingBoxAscent;
+			descent = m.fontBoundingBoxDescent;
+		}
+		var height = ascent + descent;
+		return ascent - ([0, height / 2, ascent, height][baseline] || 0);
+	}
+
+	// imageCanvas puts the pixels of a DrawImage command on a canvas.
+	function imageCanvas(c) {
+		var pixels = atob(c[11]);
+		var data = new ImageData(c[1], c[2]);
+		for (var i = 0; i < pixels.length; i++) {
+			data.data[i] = pixels.charCodeAt(i);
+		}
+		var canvas = document.createElement('canvas');
+		canvas.width = c[1];
+		canvas.height = c[2];
+		canvas.getContext('2d').putImageData(data, 0, 0);
+		return canvas;
+	}
+
+	// measure answers a measure message with the size of the text and the
+	// ascent, descent, x-height and cap height of the font. If the message
+	// has a list of texts, the answer also has the width of each one.
+	function measure(msg) {
+		measureContext.font = msg.font;
+		var m = measureContext.measureText(msg.text || '');
+		var ascent = msg.size, descent = msg.size * 0.2;
+		if (m.fontBoundingBoxAscent !== undefined) {
+			ascent = m.fontBoundingBoxAscent;
+			descent = m.fontBoundingBoxDescent;
+		}
+		var xHeight = msg.size * 0.5, capHeight = msg.size * 0.7;
+		if (m.actualBoundingBoxAscent !== undefined) {
+			xHeight = measureContext.measureText('x').actualBoundingBoxAscent;
+			capHeight = measureContext.measureText('H').actualBoundingBoxAscent;
+		}
+		var widths = (msg.texts || []).map(function(text) {
+			return measureContext.measureText(text).width;
+		});
+		send({type: 'measure', id: msg.id, width: m.width,
+			height: ascent + descent, widths: widths,
+			metrics: [ascent, descent, xHeight, capHeight]});
+	}
+
+	function keyMessage(e, type) {
+		return {type: 'key', window: activeWindow.id, event: type,
+			keyCode: e.keyCode, alt: e.altKey, ctrl: e.ctrlKey,
+			meta: e.metaKey, shift: e.shiftKey};
+	}
+
+	document.addEventListener('mousemove', function(e) {
+		if (mouseWindow !== null) {
+			var p = contentPoint(mouseWindow, e);
+			send({type: 'mouse', window: mouseWindow.id, event: 'drag',
+				x: p.x, y: p.y});
+		}
+	});
+	document.addEventListener('mouseup', function(e) {
+		if (mouseWindow !== null) {
+			var p = contentPoint(mouseWindow, e);
+			send({type: 'mouse', window: mouseWindow.id, event: 'up', x: p.x,
+				y: p.y});
+			mouseWindow = null;
+		}
+	});
+	document.addEventListener('keydown', function(e) {
+		if (activeWindow === null) {
+			return;
+		}
+		send(keyMessage(e, 'down'));
+		if (!MODIFIERS[e.key]) {
+			var msg = keyMessage(e, 'press');
+			if (Array.from(e.key).length === 1) {
+				msg.charCode = e.key.codePointAt(0);
+			} else {
+				msg.charCode = PRESS_CODES[e.key] || e.keyCode;
+			}
+			send(msg);
+		}
+		e.preventDefault();
+	});
+	document.addEventListener('keyup', function(e) {
+		if (activeWindow !== null) {
+			send(keyMessage(e, 'up'));
+			e.preventDefault();
+		}
+	});
+
+	socket.onmessage = function(e) {
+		var msg = JSON.parse(e.data);
+		switch (msg.type) {
+		case 'window':
+			updateWindow(msg);
+			break;
+		case 'draw':
+			drawWindow(msg);
+			break;
+		case 'measure':
+			measure(msg);
+			break;
+		}
+	};
+	socket.onclose = function() {
+		document.title += ' (disconnected)';
+	};
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,35 @@
+// +build browser
+// +build !js
+
+package gogui
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWSSameOrigin(t *testing.T) {
+	for origin, ok := range map[string]bool{"": true,
+		"http://127.0.0.1:8765": true, "http://evil.com": false,
+		"http://127.0.0.1:9999": false} {
+		r := httptest.NewRequest("GET", "http://127.0.0.1:8765/socket", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if wsSameOrigin(r, "127.0.0.1") != ok {
+			t.Error(origin)
+		}
+	}
+}
+
+func TestWSSameOriginRebinding(t *testing.T) {
+	for host, ok := range map[string]bool{"localhost:8765": true,
+		"[::1]:8765": true, "evil.com:8765": false, "myhost:8765": true,
+		"MyHost:8765": true} {
+		r := httptest.NewRequest("GET", "http://"+host+"/socket", nil)
+		r.Header.Set("Origin", "http://"+host)
+		if wsSameOrigin(r, "myhost") != ok {
+			t.Error(host)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Command gogui-display shows the windows of apps which use the remote driver.
+//
+// Start it on the machine where the windows should appear:
+//
+//	gogui-display -addr 127.0.0.1:7300
+//
+// and run apps with GOGUI_DISPLAY set to the same address. The address may
+// also be a Unix socket, like unix:/tmp/gogui.sock. The display shows windows
+// with whichever local backend gogui picks, so GOGUI_BACKEND works here too.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unixpickle/gogui"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:7300",
+		"address to listen on (host:port or unix:path)")
+	flag.Parse()
+
+	// The display must show windows itself rather than sending them to
+	// another display.
+	os.Unsetenv("GOGUI_DISPLAY")
+
+	if err := gogui.ServeDisplay(*addr); err != nil {
+		fmt.Fprintln(os.Stderr, "gogui-display:", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "gogui-display: listening on", *addr)
+	gogui.Main(&gogui.AppInfo{Name: "gogui-display"})
+}
@@ -0,0 +1,155 @@
+package gogui
+
+import (
+	"math"
+)
+
+// A CompositeMode decides how new drawing is combined with what is already
+// on the canvas.
+//
+// On Mac OS X, and on the backends which draw in Go (headless, X11, VNC, fbdev
+// and term), every mode only changes the pixels inside of the shape being
+// drawn.
+//
+// The browser and WebAssembly backends draw on HTML5 canvases instead. There,
+// CompositeSourceIn, CompositeSourceOut, CompositeDestinationIn,
+// CompositeDestinationAtop and CompositeCopy also clear the rest of the
+// clipping region, while the other modes only change the pixels inside of the
+// shape. The remote backend behaves like the backend which gogui-display uses.
+type CompositeMode int
+
+const (
+	// CompositeSourceOver draws new shapes over the canvas. This is the
+	// default.
+	CompositeSourceOver CompositeMode = iota
+
+	// CompositeSourceIn only keeps new shapes where the canvas was opaque.
+	CompositeSourceIn
+
+	// CompositeSourceOut only keeps new shapes where the canvas was
+	// transparent.
+	CompositeSourceOut
+
+	// CompositeSourceAtop draws new shapes over the canvas, but only where
+	// the canvas was opaque.
+	CompositeSourceAtop
+
+	// CompositeDestinationOver draws new shapes behind the canvas.
+	CompositeDestinationOver
+
+	// CompositeDestinationIn only keeps the canvas where new shapes are
+	// opaque.
+	CompositeDestinationIn
+
+	// CompositeDestinationOut erases the canvas where new shapes are opaque.
+	CompositeDestinationOut
+
+	// CompositeDestinationAtop only keeps the canvas where new shapes are
+	// opaque, and draws new shapes behind it.
+	CompositeDestinationAtop
+
+	// CompositeCopy replaces the canvas with new shapes.
+	CompositeCopy
+
+	// CompositeXor keeps new shapes and the canvas where they do not overlap.
+	CompositeXor
+
+	// CompositeLighter adds the colors of new shapes to the canvas.
+	CompositeLighter
+
+	// CompositeMultiply multiplies the colors of new shapes and the canvas,
+	// which darkens the canvas.
+	CompositeMultiply
+
+	// CompositeScreen inverts, multiplies and inverts again, which lightens
+	// the canvas.
+	CompositeScreen
+
+	// CompositeOverlay multiplies dark parts of the canvas and screens light
+	// parts of it.
+	CompositeOverlay
+
+	// CompositeDarken keeps the darker of each color component.
+	CompositeDarken
+
+	// CompositeLighten keeps the lighter of each color component.
+	CompositeLighten
+)
+
+// composite combines a premultiplied source color with a premultiplied
+// destination color. Blend modes follow the W3C compositing specification,
+// which CoreGraphics and HTML5 canvases also use.
+func (m CompositeMode) composite(src, dst Color) Color {
+	// Porter-Duff operators are sums of the source and the destination.
+	var fa, fb float64
+	switch m {
+	case CompositeSourceIn:
+		fa, fb = dst.A, 0
+	case CompositeSourceOut:
+		fa, fb = 1-dst.A, 0
+	case CompositeSourceAtop:
+		fa, fb = dst.A, 1-src.A
+	case CompositeDestinationOver:
+		fa, fb = 1-dst.A, 1
+	case CompositeDestinationIn:
+		fa, fb = 0, src.A
+	case CompositeDestinationOut:
+		fa, fb = 0, 1-src.A
+	case CompositeDestinationAtop:
+		fa, fb = 1-dst.A, src.A
+	case CompositeCopy:
+		fa, fb = 1, 0
+	case CompositeXor:
+		fa, fb = 1-dst.A, 1-src.A
+	case CompositeLighter:
+		fa, fb = 1, 1
+	case CompositeMultiply, CompositeScreen, CompositeOverlay,
+		CompositeDarken, CompositeLighten:
+		return m.blend(src, dst)
+	default:
+		fa, fb = 1, 1-src.A
+	}
+	mix := func(s, d float64) float64 {
+		return math.Min(1, s*fa+d*fb)
+	}
+	return Color{mix(src.R, dst.R), mix(src.G, dst.G), mix(src.B, dst.B),
+		mix(src.A, dst.A)}
+}
+
+// blend applies a separable blend mode and then draws the result over the
+// destination.
+func (m CompositeMode) blend(src, dst Color) Color {
+	// b returns the blended color times both alphas, from premultiplied
+	// components.
+	var b func(s, d float64) float64
+	switch m {
+	case CompositeMultiply:
+		b = func(s, d float64) float64 {
+			return s * d
+		}
+	case CompositeScreen:
+		b = func(s, d float64) float64 {
+			return s*dst.A + d*src.A - s*d
+		}
+	case CompositeOverlay:
+		b = func(s, d float64) float64 {
+			if 2*d <= dst.A {
+				return 2 * s * d
+			}
+			return src.A*dst.A - 2*(dst.A-d)*(src.A-s)
+		}
+	case CompositeDarken:
+		b = func(s, d float64) float64 {
+			return math.Min(s*dst.A, d*src.A)
+		}
+	case CompositeLighten:
+		b = func(s, d float64) float64 {
+			return math.Max(s*dst.A, d*src.A)
+		}
+	}
+	mix := func(s, d float64) float64 {
+		return clampUnit((1-dst.A)*s + (1-src.A)*d + b(s, d))
+	}
+	return Color{mix(src.R, dst.R), mix(src.G, dst.G), mix(src.B, dst.B),
+		src.A + dst.A - src.A*dst.A}
+}
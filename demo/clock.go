@@ -16,6 +16,8 @@ func drawClock(c gogui.DrawContext) {
 	
 	// Draw the 12 numbers around the clock
 	c.SetFill(gogui.Color{1, 1, 1, 1})
+	c.SetTextAlign(gogui.TextAlignCenter)
+	c.SetTextBaseline(gogui.TextBaselineMiddle)
 	for i := 0; i < 12; i++ {
 		numberStr := strconv.Itoa(((i + 2) % 12) + 1)
 		angle := math.Pi * 2.0 * float64(i) / float64(12)
@@ -23,7 +25,7 @@ func drawClock(c gogui.DrawContext) {
 		y := math.Sin(angle) * (ClockSize - 20) / 2
 		x += ClockSize / 2
 		y += ClockSize / 2
-		c.FillText(numberStr, x-5, y-11)
+		c.FillText(numberStr, x, y)
 	}
 	
 	// Get the times for the hands
@@ -48,13 +50,14 @@ func drawClock(c gogui.DrawContext) {
 }
 
 func drawHand(c gogui.DrawContext, fraction float64, length float64) {
-	angle := math.Pi*2.0*fraction - math.Pi/2.0
-	x := ClockSize/2 + math.Cos(angle)*length
-	y := ClockSize/2 + math.Sin(angle)*length
+	c.Save()
+	c.Translate(ClockSize/2, ClockSize/2)
+	c.Rotate(math.Pi * 2.0 * fraction)
 	c.BeginPath()
-	c.MoveTo(ClockSize/2, ClockSize/2)
-	c.LineTo(x, y)
+	c.MoveTo(0, 0)
+	c.LineTo(0, -length)
 	c.StrokePath()
+	c.Restore()
 }
 
 func main() {
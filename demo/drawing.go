@@ -4,6 +4,7 @@ package main
 
 import (
 	"github.com/unixpickle/gogui"
+	"math"
 	"os"
 )
 
@@ -48,7 +49,9 @@ func openWindow() {
 
 func drawCircle(c gogui.DrawContext, evt gogui.MouseEvent) {
 	c.SetFill(gogui.Color{0, 0, 0, 1})
-	c.FillEllipse(gogui.Rect{evt.X - 5, evt.Y - 5, 10, 10})
+	c.BeginPath()
+	c.Arc(evt.X, evt.Y, 5, 0, 2*math.Pi, true)
+	c.FillPath()
 }
 
 func drawLines(c gogui.DrawContext, evts []gogui.MouseEvent) {
@@ -63,8 +66,14 @@ func drawLines(c gogui.DrawContext, evts []gogui.MouseEvent) {
 	c.SetThickness(8)
 	c.BeginPath()
 	c.MoveTo(evts[0].X, evts[0].Y)
-	for i := 1; i < len(evts); i++ {
-		c.LineTo(evts[i].X, evts[i].Y)
+	
+	// Curve through the midpoints between events to smooth out the line.
+	for i := 1; i < len(evts)-1; i++ {
+		midX := (evts[i].X + evts[i+1].X) / 2
+		midY := (evts[i].Y + evts[i+1].Y) / 2
+		c.QuadraticTo(evts[i].X, evts[i].Y, midX, midY)
 	}
+	last := evts[len(evts)-1]
+	c.LineTo(last.X, last.Y)
 	c.StrokePath()
 }
@@ -0,0 +1,567 @@
+package gogui
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+// A drawOp identifies a DrawContext method.
+type drawOp int
+
+const (
+	drawOpBeginPath drawOp = iota
+	drawOpClosePath
+	drawOpFillEllipse
+	drawOpFillPath
+	drawOpFillRect
+	drawOpFillText
+	drawOpLineTo
+	drawOpMoveTo
+	drawOpSetFill
+	drawOpSetFont
+	drawOpSetStroke
+	drawOpSetThickness
+	drawOpStrokeEllipse
+	drawOpStrokePath
+	drawOpStrokeRect
+	drawOpRestore
+	drawOpRotate
+	drawOpSave
+	drawOpScale
+	drawOpSetTransform
+	drawOpTransform
+	drawOpTranslate
+	drawOpClipPath
+	drawOpClipRect
+	drawOpArc
+	drawOpArcTo
+	drawOpCubicTo
+	drawOpQuadraticTo
+	drawOpSetFillGradient
+	drawOpSetStrokeGradient
+	drawOpDrawImage
+	drawOpSetInterpolation
+	drawOpSetLineCap
+	drawOpSetLineJoin
+	drawOpSetMiterLimit
+	drawOpSetLineDash
+	drawOpSetFillRule
+	drawOpSetGlobalAlpha
+	drawOpSetCompositeMode
+	drawOpSetShadow
+	drawOpClearShadow
+	drawOpSetTextAlign
+	drawOpSetTextBaseline
+	drawOpStrokeText
+	drawOpSetFontDescriptor
+)
+
+// drawOpNames maps each drawOp to the name of its DrawContext method.
+var drawOpNames = []string{"BeginPath", "ClosePath", "FillEllipse", "FillPath",
+	"FillRect", "FillText", "LineTo", "MoveTo", "SetFill", "SetFont",
+	"SetStroke", "SetThickness", "StrokeEllipse", "StrokePath", "StrokeRect",
+	"Restore", "Rotate", "Save", "Scale", "SetTransform", "Transform",
+	"Translate", "ClipPath", "ClipRect", "Arc", "ArcTo", "CubicTo",
+	"QuadraticTo", "SetFillGradient", "SetStrokeGradient", "DrawImage",
+	"SetInterpolation", "SetLineCap", "SetLineJoin", "SetMiterLimit",
+	"SetLineDash", "SetFillRule", "SetGlobalAlpha", "SetCompositeMode",
+	"SetShadow", "ClearShadow", "SetTextAlign", "SetTextBaseline", "StrokeText",
+	"SetFontDescriptor"}
+
+func (d drawOp) String() string {
+	if int(d) < len(drawOpNames) {
+		return drawOpNames[int(d)]
+	}
+	return "Unknown"
+}
+
+// A drawCommand is a recorded call to a DrawContext method. The numeric
+// arguments are stored in the order that the method takes them, and text holds
+// the method's string argument if it has one.
+type drawCommand struct {
+	op   drawOp
+	args []float64
+	text string
+}
+
+// A textMeasurer measures text and fonts as TextSize and FontMetrics would.
+type textMeasurer interface {
+	textSize(text string, f Font) (width, height float64)
+	fontMetrics(f Font) FontMetrics
+}
+
+// A batchMeasurer is a textMeasurer which measures many strings at once
+// faster than one at a time, such as one which has to ask another process.
+type batchMeasurer interface {
+	textMeasurer
+	textWidths(texts []string, f Font) []float64
+}
+
+// A prefixMeasurer is a textMeasurer which can find the widths of the
+// prefixes text[:end] of a string without measuring each one from scratch.
+type prefixMeasurer interface {
+	textMeasurer
+	prefixWidths(text string, ends []int, f Font) []float64
+}
+
+// A drawRecorder is a DrawContext which records every call so that it can be
+// replayed elsewhere. It answers TextSize and FontMetrics with a textMeasurer.
+type drawRecorder struct {
+	commands []drawCommand
+	measure  textMeasurer
+
+	// The text settings are needed to measure text, so saved holds them at
+	// each call to Save.
+	drawRecorderState
+	saved []drawRecorderState
+}
+
+type drawRecorderState struct {
+	font         Font
+	textAlign    TextAlign
+	textBaseline TextBaseline
+}
+
+func newDrawRecorder(m textMeasurer) *drawRecorder {
+	return &drawRecorder{
+		measure: m,
+		drawRecorderState: drawRecorderState{
+			font: Font{Family: "Helvetica", Size: 18},
+		},
+	}
+}
+
+func (d *drawRecorder) Arc(x, y, radius, startAngle, endAngle float64,
+	clockwise bool) {
+	var cw float64
+	if clockwise {
+		cw = 1
+	}
+	d.record(drawOpArc, "", x, y, radius, startAngle, endAngle, cw)
+}
+
+func (d *drawRecorder) ArcTo(x1, y1, x2, y2, radius float64) {
+	d.record(drawOpArcTo, "", x1, y1, x2, y2, radius)
+}
+
+func (d *drawRecorder) AttributedTextSize(s *AttributedString) (float64, float64) {
+	width, height, _ := attributedTextSize(layoutMeasurer(d), s)
+	return width, height
+}
+
+func (d *drawRecorder) BeginPath() {
+	d.record(drawOpBeginPath, "")
+}
+
+func (d *drawRecorder) ClearShadow() {
+	d.record(drawOpClearShadow, "")
+}
+
+func (d *drawRecorder) ClipPath() {
+	d.record(drawOpClipPath, "")
+}
+
+func (d *drawRecorder) ClipRect(r Rect) {
+	d.record(drawOpClipRect, "", r.X, r.Y, r.Width, r.Height)
+}
+
+func (d *drawRecorder) ClosePath() {
+	d.record(drawOpClosePath, "")
+}
+
+func (d *drawRecorder) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	d.record(drawOpCubicTo, "", c1x, c1y, c2x, c2y, x, y)
+}
+
+func (d *drawRecorder) DrawImage(img image.Image, dst Rect) {
+	d.DrawSubImage(img, imageBoundsRect(img), dst)
+}
+
+// DrawSubImage records the pixels which src touches, without premultiplied
+// alpha, so that browsers can use them as ImageData. The pixels are sent along
+// with every frame, so big images slow down remote drivers.
+func (d *drawRecorder) DrawSubImage(img image.Image, src Rect, dst Rect) {
+	pixels := imageSourceRect(img, src)
+	if pixels.Empty() {
+		return
+	}
+	data := imageNRGBA(imageRGBA(img), pixels)
+	d.record(drawOpDrawImage, string(data), float64(pixels.Dx()),
+		float64(pixels.Dy()), src.X-float64(pixels.Min.X),
+		src.Y-float64(pixels.Min.Y), src.Width, src.Height, dst.X, dst.Y,
+		dst.Width, dst.Height)
+}
+
+func (d *drawRecorder) DrawTextLayout(t *TextLayout, x, y float64) {
+	// A TextLayout is recorded as the calls which draw it.
+	drawTextLayout(d, t, x, y)
+}
+
+func (d *drawRecorder) FillAttributedText(s *AttributedString, x, y float64) {
+	drawAttributedText(d, s, x, y, d.textAlign, d.textBaseline)
+}
+
+func (d *drawRecorder) FillEllipse(r Rect) {
+	d.record(drawOpFillEllipse, "", r.X, r.Y, r.Width, r.Height)
+}
+
+func (d *drawRecorder) FillPath() {
+	d.record(drawOpFillPath, "")
+}
+
+func (d *drawRecorder) FillPathObject(p *Path) {
+	// A Path is recorded as the calls which build it.
+	d.BeginPath()
+	p.addTo(d)
+	d.FillPath()
+}
+
+func (d *drawRecorder) FillRect(r Rect) {
+	d.record(drawOpFillRect, "", r.X, r.Y, r.Width, r.Height)
+}
+
+func (d *drawRecorder) FillText(text string, x, y float64) {
+	d.record(drawOpFillText, text, x, y)
+}
+
+func (d *drawRecorder) FontMetrics() FontMetrics {
+	return d.fontMetrics(d.font)
+}
+
+func (d *drawRecorder) LineTo(x, y float64) {
+	d.record(drawOpLineTo, "", x, y)
+}
+
+func (d *drawRecorder) MoveTo(x, y float64) {
+	d.record(drawOpMoveTo, "", x, y)
+}
+
+func (d *drawRecorder) QuadraticTo(cx, cy, x, y float64) {
+	d.record(drawOpQuadraticTo, "", cx, cy, x, y)
+}
+
+func (d *drawRecorder) Restore() {
+	if len(d.saved) > 0 {
+		d.drawRecorderState = d.saved[len(d.saved)-1]
+		d.saved = d.saved[:len(d.saved)-1]
+	}
+	d.record(drawOpRestore, "")
+}
+
+func (d *drawRecorder) Rotate(angle float64) {
+	d.record(drawOpRotate, "", angle)
+}
+
+func (d *drawRecorder) Save() {
+	d.saved = append(d.saved, d.drawRecorderState)
+	d.record(drawOpSave, "")
+}
+
+func (d *drawRecorder) Scale(x, y float64) {
+	d.record(drawOpScale, "", x, y)
+}
+
+func (d *drawRecorder) SetCompositeMode(m CompositeMode) {
+	d.record(drawOpSetCompositeMode, "", float64(m))
+}
+
+func (d *drawRecorder) SetFill(c Color) {
+	d.record(drawOpSetFill, "", c.R, c.G, c.B, c.A)
+}
+
+func (d *drawRecorder) SetFillPaint(p Paint) {
+	if c, ok := paintColor(p); ok {
+		d.SetFill(c)
+	} else if g, ok := paintGradient(p); ok {
+		d.record(drawOpSetFillGradient, "", g.args()...)
+	}
+}
+
+func (d *drawRecorder) SetFillRule(rule FillRule) {
+	d.record(drawOpSetFillRule, "", float64(rule))
+}
+
+func (d *drawRecorder) SetFont(size float64, name string) {
+	d.font = Font{Family: name, Size: size}
+	d.record(drawOpSetFont, name, size)
+}
+
+func (d *drawRecorder) SetFontDescriptor(f Font) {
+	d.font = f
+	var italic float64
+	if f.Italic {
+		italic = 1
+	}
+	text := strings.Join(append([]string{f.Family}, f.Fallbacks...), "\n")
+	d.record(drawOpSetFontDescriptor, text, f.Size, float64(f.Weight), italic,
+		float64(f.Stretch))
+}
+
+func (d *drawRecorder) SetGlobalAlpha(alpha float64) {
+	d.record(drawOpSetGlobalAlpha, "", alpha)
+}
+
+func (d *drawRecorder) SetInterpolation(q Interpolation) {
+	d.record(drawOpSetInterpolation, "", float64(q))
+}
+
+func (d *drawRecorder) SetLineCap(c LineCap) {
+	d.record(drawOpSetLineCap, "", float64(c))
+}
+
+// SetLineDash records the phase followed by the pattern.
+func (d *drawRecorder) SetLineDash(pattern []float64, phase float64) {
+	d.record(drawOpSetLineDash, "", append([]float64{phase}, pattern...)...)
+}
+
+func (d *drawRecorder) SetLineJoin(j LineJoin) {
+	d.record(drawOpSetLineJoin, "", float64(j))
+}
+
+func (d *drawRecorder) SetMiterLimit(limit float64) {
+	d.record(drawOpSetMiterLimit, "", limit)
+}
+
+func (d *drawRecorder) SetShadow(offsetX, offsetY, blur float64, c Color) {
+	d.record(drawOpSetShadow, "", offsetX, offsetY, blur, c.R, c.G, c.B, c.A)
+}
+
+func (d *drawRecorder) SetStroke(c Color) {
+	d.record(drawOpSetStroke, "", c.R, c.G, c.B, c.A)
+}
+
+func (d *drawRecorder) SetStrokePaint(p Paint) {
+	if c, ok := paintColor(p); ok {
+		d.SetStroke(c)
+	} else if g, ok := paintGradient(p); ok {
+		d.record(drawOpSetStrokeGradient, "", g.args()...)
+	}
+}
+
+func (d *drawRecorder) SetTextAlign(a TextAlign) {
+	d.textAlign = a
+	d.record(drawOpSetTextAlign, "", float64(a))
+}
+
+func (d *drawRecorder) SetTextBaseline(b TextBaseline) {
+	d.textBaseline = b
+	d.record(drawOpSetTextBaseline, "", float64(b))
+}
+
+func (d *drawRecorder) SetThickness(thickness float64) {
+	d.record(drawOpSetThickness, "", thickness)
+}
+
+func (d *drawRecorder) SetTransform(m Matrix) {
+	d.record(drawOpSetTransform, "", m.A, m.B, m.C, m.D, m.E, m.F)
+}
+
+func (d *drawRecorder) StrokeEllipse(r Rect) {
+	d.record(drawOpStrokeEllipse, "", r.X, r.Y, r.Width, r.Height)
+}
+
+func (d *drawRecorder) StrokePath() {
+	d.record(drawOpStrokePath, "")
+}
+
+func (d *drawRecorder) StrokePathObject(p *Path) {
+	d.BeginPath()
+	p.addTo(d)
+	d.StrokePath()
+}
+
+func (d *drawRecorder) StrokeRect(r Rect) {
+	d.record(drawOpStrokeRect, "", r.X, r.Y, r.Width, r.Height)
+}
+
+func (d *drawRecorder) StrokeText(text string, x, y float64) {
+	d.record(drawOpStrokeText, text, x, y)
+}
+
+func (d *drawRecorder) TextPositions(text string) *TextPositions {
+	return textPositions(layoutMeasurer(d), d.font, text)
+}
+
+func (d *drawRecorder) TextSize(text string) (float64, float64) {
+	return d.textSize(text, d.font)
+}
+
+func (d *drawRecorder) Transform(m Matrix) {
+	d.record(drawOpTransform, "", m.A, m.B, m.C, m.D, m.E, m.F)
+}
+
+func (d *drawRecorder) Translate(x, y float64) {
+	d.record(drawOpTranslate, "", x, y)
+}
+
+func (d *drawRecorder) fontMetrics(f Font) FontMetrics {
+	return d.measure.fontMetrics(f)
+}
+
+func (d *drawRecorder) prefixWidths(text string, ends []int,
+	f Font) []float64 {
+	return measurePrefixes(d.measure, text, ends, f)
+}
+
+func (d *drawRecorder) record(op drawOp, text string, args ...float64) {
+	d.commands = append(d.commands, drawCommand{op, args, text})
+}
+
+func (d *drawRecorder) textSize(text string, f Font) (float64, float64) {
+	return d.measure.textSize(text, f)
+}
+
+func (d *drawRecorder) textWidths(texts []string, f Font) []float64 {
+	return measureWidths(d.measure, texts, f)
+}
+
+// drawOpArgCounts is the number of numeric arguments that each drawOp takes,
+// or -1 if the number varies.
+var drawOpArgCounts = []int{0, 0, 4, 0, 4, 2, 2, 2, 4, 1, 4, 1, 4, 0, 4,
+	0, 1, 0, 2, 6, 6, 2, 0, 4, 6, 5, 6, 4, -1, -1, 10, 1, 1, 1, 1, -1, 1,
+	1, 1, 7, 0, 1, 1, 2, 4}
+
+// replayDrawCommands calls the DrawContext methods which a drawRecorder
+// recorded. Commands with an unknown op or the wrong number of arguments are
+// skipped, since they may come from another process.
+func replayDrawCommands(ctx DrawContext, commands []drawCommand) {
+	for _, c := range commands {
+		if int(c.op) < 0 || int(c.op) >= len(drawOpArgCounts) {
+			continue
+		} else if n := drawOpArgCounts[c.op]; n >= 0 && len(c.args) != n {
+			continue
+		}
+		a := c.args
+		switch c.op {
+		case drawOpBeginPath:
+			ctx.BeginPath()
+		case drawOpClosePath:
+			ctx.ClosePath()
+		case drawOpFillEllipse:
+			ctx.FillEllipse(Rect{a[0], a[1], a[2], a[3]})
+		case drawOpFillPath:
+			ctx.FillPath()
+		case drawOpFillRect:
+			ctx.FillRect(Rect{a[0], a[1], a[2], a[3]})
+		case drawOpFillText:
+			ctx.FillText(c.text, a[0], a[1])
+		case drawOpLineTo:
+			ctx.LineTo(a[0], a[1])
+		case drawOpMoveTo:
+			ctx.MoveTo(a[0], a[1])
+		case drawOpSetFill:
+			ctx.SetFill(Color{a[0], a[1], a[2], a[3]})
+		case drawOpSetFont:
+			ctx.SetFont(a[0], c.text)
+		case drawOpSetStroke:
+			ctx.SetStroke(Color{a[0], a[1], a[2], a[3]})
+		case drawOpSetThickness:
+			ctx.SetThickness(a[0])
+		case drawOpStrokeEllipse:
+			ctx.StrokeEllipse(Rect{a[0], a[1], a[2], a[3]})
+		case drawOpStrokePath:
+			ctx.StrokePath()
+		case drawOpStrokeRect:
+			ctx.StrokeRect(Rect{a[0], a[1], a[2], a[3]})
+		case drawOpRestore:
+			ctx.Restore()
+		case drawOpRotate:
+			ctx.Rotate(a[0])
+		case drawOpSave:
+			ctx.Save()
+		case drawOpScale:
+			ctx.Scale(a[0], a[1])
+		case drawOpSetTransform:
+			ctx.SetTransform(Matrix{a[0], a[1], a[2], a[3], a[4], a[5]})
+		case drawOpTransform:
+			ctx.Transform(Matrix{a[0], a[1], a[2], a[3], a[4], a[5]})
+		case drawOpTranslate:
+			ctx.Translate(a[0], a[1])
+		case drawOpClipPath:
+			ctx.ClipPath()
+		case drawOpClipRect:
+			ctx.ClipRect(Rect{a[0], a[1], a[2], a[3]})
+		case drawOpArc:
+			ctx.Arc(a[0], a[1], a[2], a[3], a[4], a[5] != 0)
+		case drawOpArcTo:
+			ctx.ArcTo(a[0], a[1], a[2], a[3], a[4])
+		case drawOpCubicTo:
+			ctx.CubicTo(a[0], a[1], a[2], a[3], a[4], a[5])
+		case drawOpQuadraticTo:
+			ctx.QuadraticTo(a[0], a[1], a[2], a[3])
+		case drawOpSetFillGradient:
+			if p, ok := decodeGradient(a); ok {
+				ctx.SetFillPaint(p)
+			}
+		case drawOpSetStrokeGradient:
+			if p, ok := decodeGradient(a); ok {
+				ctx.SetStrokePaint(p)
+			}
+		case drawOpDrawImage:
+			if img, ok := decodeImage(a[0], a[1], c.text); ok {
+				ctx.DrawSubImage(img, Rect{a[2], a[3], a[4], a[5]},
+					Rect{a[6], a[7], a[8], a[9]})
+			}
+		case drawOpSetInterpolation:
+			ctx.SetInterpolation(Interpolation(a[0]))
+		case drawOpSetLineCap:
+			ctx.SetLineCap(LineCap(a[0]))
+		case drawOpSetLineJoin:
+			ctx.SetLineJoin(LineJoin(a[0]))
+		case drawOpSetMiterLimit:
+			ctx.SetMiterLimit(a[0])
+		case drawOpSetFillRule:
+			ctx.SetFillRule(FillRule(a[0]))
+		case drawOpSetLineDash:
+			if len(a) > 0 {
+				ctx.SetLineDash(a[1:], a[0])
+			}
+		case drawOpSetGlobalAlpha:
+			ctx.SetGlobalAlpha(a[0])
+		case drawOpSetCompositeMode:
+			ctx.SetCompositeMode(CompositeMode(a[0]))
+		case drawOpSetShadow:
+			ctx.SetShadow(a[0], a[1], a[2], Color{a[3], a[4], a[5], a[6]})
+		case drawOpClearShadow:
+			ctx.ClearShadow()
+		case drawOpSetTextAlign:
+			ctx.SetTextAlign(TextAlign(a[0]))
+		case drawOpSetTextBaseline:
+			ctx.SetTextBaseline(TextBaseline(a[0]))
+		case drawOpStrokeText:
+			ctx.StrokeText(c.text, a[0], a[1])
+		case drawOpSetFontDescriptor:
+			ctx.SetFontDescriptor(decodeFont(c.text, a))
+		}
+	}
+}
+
+// decodeFont turns the arguments of a drawOpSetFontDescriptor back into a
+// Font.
+func decodeFont(names string, args []float64) Font {
+	families := strings.Split(names, "\n")
+	return Font{
+		Family:    families[0],
+		Size:      args[0],
+		Weight:    FontWeight(args[1]),
+		Italic:    args[2] != 0,
+		Stretch:   FontStretch(args[3]),
+		Fallbacks: families[1:],
+	}
+}
+
+// decodeImage turns the pixels of a drawOpDrawImage back into an image. It
+// returns false if the size does not match the pixels.
+func decodeImage(width, height float64, pixels string) (image.Image, bool) {
+	if !(width >= 1 && height >= 1) || width*height*4 != float64(len(pixels)) ||
+		width != math.Floor(width) || height != math.Floor(height) {
+		return nil, false
+	}
+	w, h := int(width), int(height)
+	return &image.NRGBA{
+		Pix:    []byte(pixels),
+		Stride: w * 4,
+		Rect:   image.Rect(0, 0, w, h),
+	}, true
+}
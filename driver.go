@@ -0,0 +1,226 @@
+package gogui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Driver implements gogui on top of a windowing system.
+//
+// Each backend registers a Driver with RegisterDriver, and the package-level
+// functions forward to whichever Driver is chosen when the app starts.
+// Drivers from other packages are registered the same way, usually from an
+// init function, so importing such a package makes its backend available.
+type Driver interface {
+	// Available reports whether the driver can run in the current
+	// environment. For example, a driver for a display server might check
+	// that the server can be found.
+	Available() bool
+
+	// Main runs the main loop on the calling goroutine.
+	Main(info *AppInfo)
+
+	// NewCanvas creates a canvas which is not in any window.
+	NewCanvas(r Rect) (Canvas, error)
+
+	// NewWindow creates a window which is not showing.
+	NewWindow(r Rect) (Window, error)
+
+	// RunOnMain runs a function on the main goroutine asynchronously.
+	// It must be safe to call before Main.
+	RunOnMain(f func())
+
+	// ShowingWindows returns the windows which are showing.
+	ShowingWindows() []Window
+}
+
+// A FontDriver is a Driver which can list the fonts that it draws with.
+type FontDriver interface {
+	Driver
+
+	// Fonts returns the PostScript names of the installed fonts.
+	Fonts() ([]string, error)
+
+	// FontFamilies returns the names of the installed font families.
+	FontFamilies() ([]string, error)
+}
+
+// driverOrder lists the built-in drivers in the order in which they are
+// tried when the app does not name one. Drivers from other packages are tried
+// after these, except that the headless driver always comes last.
+var driverOrder = []string{"remote", "cocoa", "wasm", "x11", "fbdev", "term",
+	"vnc", "browser"}
+
+var driverLock sync.Mutex
+var drivers = map[string]Driver{}
+var driverNames []string
+var currentDriver Driver
+var currentName string
+var pendingOnMain []func()
+
+// RegisterDriver makes a driver available under a name.
+// It panics if a driver with the same name has been registered already.
+func RegisterDriver(name string, d Driver) {
+	driverLock.Lock()
+	defer driverLock.Unlock()
+	if _, ok := drivers[name]; ok {
+		panic("gogui: driver registered twice: " + name)
+	}
+	drivers[name] = d
+	driverNames = append(driverNames, name)
+}
+
+// Drivers returns the names of the registered drivers in the order in which
+// they are tried by default.
+func Drivers() []string {
+	driverLock.Lock()
+	defer driverLock.Unlock()
+	return sortedDriverNames()
+}
+
+// Main chooses a driver and runs its main loop. This should be called from the
+// main function, since it may require execution on the main OS thread.
+//
+// The driver is the one named by the GOGUI_BACKEND environment variable or,
+// if that is not set, by info.Backend. Otherwise, it is the first available
+// driver in the order returned by Drivers.
+//
+// Functions such as NewWindow and Fonts choose a driver the same way if they
+// are called before Main. Main panics if info.Backend names a different driver
+// than the one they chose.
+func Main(info *AppInfo) {
+	if info == nil {
+		info = &AppInfo{}
+	}
+	d, err := chooseDriver(info.Backend)
+	if err != nil {
+		panic(err)
+	}
+	d.Main(info)
+}
+
+// NewCanvas creates a new canvas or fails with an error.
+// The returned canvas will not be added to any window and will have a nil draw
+// function by default.
+func NewCanvas(r Rect) (Canvas, error) {
+	d, err := chooseDriver("")
+	if err != nil {
+		return nil, err
+	}
+	return d.NewCanvas(r)
+}
+
+// NewWindow creates a new window with a given content rectangle.
+// The created window will not be showing by default.
+// You must call this from the main goroutine.
+func NewWindow(r Rect) (Window, error) {
+	d, err := chooseDriver("")
+	if err != nil {
+		return nil, err
+	}
+	return d.NewWindow(r)
+}
+
+// RunOnMain runs a function on the main goroutine asynchronously.
+//
+// Functions which are pushed before Main chooses a driver are held until it
+// does.
+func RunOnMain(f func()) {
+	driverLock.Lock()
+	if currentDriver == nil {
+		pendingOnMain = append(pendingOnMain, f)
+		driverLock.Unlock()
+		return
+	}
+	d := currentDriver
+	driverLock.Unlock()
+	d.RunOnMain(f)
+}
+
+// ShowingWindows returns a slice containing all the currently active windows.
+// You must call this from the main goroutine.
+func ShowingWindows() []Window {
+	driverLock.Lock()
+	d := currentDriver
+	driverLock.Unlock()
+	if d == nil {
+		return []Window{}
+	}
+	return d.ShowingWindows()
+}
+
+// chooseDriver returns the current driver, choosing one if necessary.
+// The preferred name is used if the GOGUI_BACKEND environment variable is not
+// set. It fails if the driver which that names is not the current one.
+func chooseDriver(preferred string) (Driver, error) {
+	driverLock.Lock()
+	defer driverLock.Unlock()
+
+	name := os.Getenv("GOGUI_BACKEND")
+	if name == "" {
+		name = preferred
+	}
+	if currentDriver != nil {
+		if name != "" && name != currentName {
+			return nil, errors.New("gogui: cannot use driver " + name +
+				" because driver " + currentName + " was already chosen")
+		}
+		return currentDriver, nil
+	}
+
+	if name != "" {
+		d, ok := drivers[name]
+		if !ok {
+			return nil, errors.New("gogui: unknown driver " + name +
+				" (registered: " + strings.Join(sortedDriverNames(), ", ") + ")")
+		}
+		currentDriver, currentName = d, name
+	} else {
+		for _, name := range sortedDriverNames() {
+			if d := drivers[name]; d.Available() {
+				currentDriver, currentName = d, name
+				break
+			}
+		}
+		if currentDriver == nil {
+			return nil, errors.New("gogui: no driver is available")
+		}
+		if currentDriver == drivers["headless"] {
+			fmt.Fprintln(os.Stderr, "gogui: no display is available, so "+
+				"windows will not be shown (set GOGUI_BACKEND to choose a "+
+				"driver)")
+		}
+	}
+
+	for _, f := range pendingOnMain {
+		currentDriver.RunOnMain(f)
+	}
+	pendingOnMain = nil
+	return currentDriver, nil
+}
+
+// sortedDriverNames returns the names of the registered drivers in the order
+// in which they are tried. The caller must hold driverLock.
+func sortedDriverNames() []string {
+	rank := func(name string) int {
+		if name == "headless" {
+			return len(driverOrder) + 1
+		}
+		for i, x := range driverOrder {
+			if x == name {
+				return i
+			}
+		}
+		return len(driverOrder)
+	}
+	names := make([]string, len(driverNames))
+	copy(names, driverNames)
+	sort.SliceStable(names, func(i, j int) bool {
+		return rank(names[i]) < rank(names[j])
+	})
+	return names
+}
@@ -0,0 +1,49 @@
+package gogui
+
+import (
+	"os"
+	"testing"
+)
+
+type testDriver struct {
+	available bool
+}
+
+func (t *testDriver) Available() bool                  { return t.available }
+func (t *testDriver) Main(info *AppInfo)               {}
+func (t *testDriver) NewCanvas(r Rect) (Canvas, error) { return nil, nil }
+func (t *testDriver) NewWindow(r Rect) (Window, error) { return nil, nil }
+func (t *testDriver) RunOnMain(f func())               {}
+func (t *testDriver) ShowingWindows() []Window         { return nil }
+
+func TestChooseDriver(t *testing.T) {
+	if os.Getenv("GOGUI_BACKEND") != "" {
+		t.Skip("GOGUI_BACKEND is set")
+	}
+	driverLock.Lock()
+	oldDrivers, oldNames := drivers, driverNames
+	oldDriver, oldName := currentDriver, currentName
+	drivers, driverNames = map[string]Driver{}, nil
+	currentDriver, currentName = nil, ""
+	driverLock.Unlock()
+	defer func() {
+		driverLock.Lock()
+		drivers, driverNames = oldDrivers, oldNames
+		currentDriver, currentName = oldDriver, oldName
+		driverLock.Unlock()
+	}()
+
+	first := &testDriver{}
+	second := &testDriver{available: true}
+	RegisterDriver("first", first)
+	RegisterDriver("second", second)
+	if d, err := chooseDriver(""); err != nil || d != second {
+		t.Fatal("expected the available driver:", d, err)
+	}
+	if d, err := chooseDriver("second"); err != nil || d != second {
+		t.Error("expected the current driver:", d, err)
+	}
+	if _, err := chooseDriver("first"); err == nil {
+		t.Error("expected an error for a driver which was not chosen")
+	}
+}
@@ -0,0 +1,120 @@
+package gogui
+
+const (
+	keyEventDown = iota
+	keyEventUp   = iota
+)
+
+const (
+	keyFlagAlt   = 1
+	keyFlagCtrl  = 2
+	keyFlagMeta  = 4
+	keyFlagShift = 8
+)
+
+const (
+	mouseEventDown = iota
+	mouseEventDrag = iota
+	mouseEventMove = iota
+	mouseEventUp   = iota
+)
+
+type keyEvents struct {
+	down  KeyHandler
+	press KeyHandler
+	up    KeyHandler
+}
+
+func (k *keyEvents) KeyDownHandler() KeyHandler {
+	return k.down
+}
+
+func (k *keyEvents) KeyPressHandler() KeyHandler {
+	return k.press
+}
+
+func (k *keyEvents) KeyUpHandler() KeyHandler {
+	return k.up
+}
+
+func (k *keyEvents) SetKeyDownHandler(h KeyHandler) {
+	k.down = h
+}
+
+func (k *keyEvents) SetKeyPressHandler(h KeyHandler) {
+	k.press = h
+}
+
+func (k *keyEvents) SetKeyUpHandler(h KeyHandler) {
+	k.up = h
+}
+
+type mouseEvents struct {
+	down MouseHandler
+	drag MouseHandler
+	move MouseHandler
+	up   MouseHandler
+}
+
+func (m *mouseEvents) MouseDownHandler() MouseHandler {
+	return m.down
+}
+
+func (m *mouseEvents) MouseDragHandler() MouseHandler {
+	return m.drag
+}
+
+func (m *mouseEvents) MouseMoveHandler() MouseHandler {
+	return m.move
+}
+
+func (m *mouseEvents) MouseUpHandler() MouseHandler {
+	return m.up
+}
+
+func (m *mouseEvents) SetMouseDownHandler(h MouseHandler) {
+	m.down = h
+}
+
+func (m *mouseEvents) SetMouseDragHandler(h MouseHandler) {
+	m.drag = h
+}
+
+func (m *mouseEvents) SetMouseMoveHandler(h MouseHandler) {
+	m.move = h
+}
+
+func (m *mouseEvents) SetMouseUpHandler(h MouseHandler) {
+	m.up = h
+}
+
+type windowEvents struct {
+	keyEvents
+	mouseEvents
+	onClose func()
+}
+
+func (w *windowEvents) CloseHandler() func() {
+	return w.onClose
+}
+
+func (w *windowEvents) SetCloseHandler(h func()) {
+	w.onClose = h
+}
+
+func makeKeyEvent(keyCode, charCode int, flags int) KeyEvent {
+	res := KeyEvent{KeyCode: keyCode, CharCode: charCode}
+	if (flags & keyFlagAlt) != 0 {
+		res.AltKey = true
+	}
+	if (flags & keyFlagCtrl) != 0 {
+		res.CtrlKey = true
+	}
+	if (flags & keyFlagMeta) != 0 {
+		res.MetaKey = true
+	}
+	if (flags & keyFlagShift) != 0 {
+		res.ShiftKey = true
+	}
+	return res
+}
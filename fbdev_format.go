@@ -0,0 +1,80 @@
+// +build fbdev
+// +build linux
+
+package gogui
+
+import (
+	"errors"
+	"image"
+	"strings"
+)
+
+// An fbBitfield is the position of a color channel within a pixel, like the
+// fb_bitfield structure of the kernel.
+type fbBitfield struct {
+	offset uint32
+	length uint32
+}
+
+// An fbFormat describes how pixels are stored in a framebuffer. Pixels are
+// little endian, so channel offsets are counted from the first byte's lowest
+// bit.
+type fbFormat struct {
+	bitsPerPixel int
+	red          fbBitfield
+	green        fbBitfield
+	blue         fbBitfield
+	alpha        fbBitfield
+}
+
+// fbFormats contains the formats which can be named in the GOGUI_FB_FORMAT
+// environment variable. Each name lists the channels from the most
+// significant bits to the least significant bits.
+var fbFormats = map[string]fbFormat{
+	"rgb565":   {16, fbBitfield{11, 5}, fbBitfield{5, 6}, fbBitfield{0, 5}, fbBitfield{}},
+	"bgr565":   {16, fbBitfield{0, 5}, fbBitfield{5, 6}, fbBitfield{11, 5}, fbBitfield{}},
+	"rgb888":   {24, fbBitfield{16, 8}, fbBitfield{8, 8}, fbBitfield{0, 8}, fbBitfield{}},
+	"bgr888":   {24, fbBitfield{0, 8}, fbBitfield{8, 8}, fbBitfield{16, 8}, fbBitfield{}},
+	"xrgb8888": {32, fbBitfield{16, 8}, fbBitfield{8, 8}, fbBitfield{0, 8}, fbBitfield{}},
+	"xbgr8888": {32, fbBitfield{0, 8}, fbBitfield{8, 8}, fbBitfield{16, 8}, fbBitfield{}},
+	"argb8888": {32, fbBitfield{16, 8}, fbBitfield{8, 8}, fbBitfield{0, 8}, fbBitfield{24, 8}},
+	"abgr8888": {32, fbBitfield{0, 8}, fbBitfield{8, 8}, fbBitfield{16, 8}, fbBitfield{24, 8}},
+}
+
+func parseFBFormat(name string) (fbFormat, error) {
+	if f, ok := fbFormats[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+	return fbFormat{}, errors.New("unknown framebuffer format: " + name)
+}
+
+// bytesPerPixel returns the number of bytes used to store each pixel.
+func (f fbFormat) bytesPerPixel() int {
+	return (f.bitsPerPixel + 7) / 8
+}
+
+// encodeRow converts a row of an image to the framebuffer's format. The row
+// is written to the start of dest.
+func (f fbFormat) encodeRow(dest []byte, img *image.RGBA, y int) {
+	size := f.bytesPerPixel()
+	idx := img.PixOffset(img.Rect.Min.X, y)
+	for x := 0; x < img.Rect.Dx(); x++ {
+		pixel := f.red.encode(img.Pix[idx]) | f.green.encode(img.Pix[idx+1]) |
+			f.blue.encode(img.Pix[idx+2]) | f.alpha.encode(0xff)
+		for i := 0; i < size; i++ {
+			dest[x*size+i] = byte(pixel >> uint(8*i))
+		}
+		idx += 4
+	}
+}
+
+// encode scales an 8-bit channel value to the bitfield.
+func (b fbBitfield) encode(value uint8) uint32 {
+	if b.length == 0 {
+		return 0
+	}
+	if b.length >= 8 {
+		return uint32(value) << (b.offset + b.length - 8)
+	}
+	return uint32(value>>(8-b.length)) << b.offset
+}
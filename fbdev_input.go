@@ -0,0 +1,227 @@
+// +build fbdev
+// +build linux
+
+package gogui
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Event types and codes from linux/input-event-codes.h.
+const (
+	evdevSyn = 0
+	evdevKey = 1
+	evdevRel = 2
+	evdevAbs = 3
+
+	evdevSynReport = 0
+	evdevRelX      = 0
+	evdevRelY      = 1
+	evdevAbsX      = 0
+	evdevAbsY      = 1
+	evdevBtnLeft   = 0x110
+	evdevBtnTouch  = 0x14a
+
+	evdevKeyCapsLock = 58
+)
+
+// An evdevEvent is the input_event structure which is read from evdev
+// devices.
+type evdevEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// An evdevAbsInfo is the input_absinfo structure which describes an absolute
+// axis.
+type evdevAbsInfo struct {
+	Value      int32
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32
+	Flat       int32
+	Resolution int32
+}
+
+// evdevKeysyms maps evdev key codes to the X keysyms which they type, without
+// and with shift, on a US keyboard.
+var evdevKeysyms = map[uint16][2]uint32{
+	1:   {0xff1b, 0xff1b}, // Escape
+	2:   {'1', '!'},
+	3:   {'2', '@'},
+	4:   {'3', '#'},
+	5:   {'4', '$'},
+	6:   {'5', '%'},
+	7:   {'6', '^'},
+	8:   {'7', '&'},
+	9:   {'8', '*'},
+	10:  {'9', '('},
+	11:  {'0', ')'},
+	12:  {'-', '_'},
+	13:  {'=', '+'},
+	14:  {0xff08, 0xff08}, // BackSpace
+	15:  {0xff09, 0xff09}, // Tab
+	16:  {'q', 'Q'},
+	17:  {'w', 'W'},
+	18:  {'e', 'E'},
+	19:  {'r', 'R'},
+	20:  {'t', 'T'},
+	21:  {'y', 'Y'},
+	22:  {'u', 'U'},
+	23:  {'i', 'I'},
+	24:  {'o', 'O'},
+	25:  {'p', 'P'},
+	26:  {'[', '{'},
+	27:  {']', '}'},
+	28:  {0xff0d, 0xff0d}, // Return
+	29:  {0xffe3, 0xffe3}, // Control_L
+	30:  {'a', 'A'},
+	31:  {'s', 'S'},
+	32:  {'d', 'D'},
+	33:  {'f', 'F'},
+	34:  {'g', 'G'},
+	35:  {'h', 'H'},
+	36:  {'j', 'J'},
+	37:  {'k', 'K'},
+	38:  {'l', 'L'},
+	39:  {';', ':'},
+	40:  {'\'', '"'},
+	41:  {'`', '~'},
+	42:  {0xffe1, 0xffe1}, // Shift_L
+	43:  {'\\', '|'},
+	44:  {'z', 'Z'},
+	45:  {'x', 'X'},
+	46:  {'c', 'C'},
+	47:  {'v', 'V'},
+	48:  {'b', 'B'},
+	49:  {'n', 'N'},
+	50:  {'m', 'M'},
+	51:  {',', '<'},
+	52:  {'.', '>'},
+	53:  {'/', '?'},
+	54:  {0xffe2, 0xffe2}, // Shift_R
+	55:  {'*', '*'},       // KP_Multiply
+	56:  {0xffe9, 0xffe9}, // Alt_L
+	57:  {' ', ' '},
+	58:  {0xffe5, 0xffe5}, // Caps_Lock
+	59:  {0xffbe, 0xffbe}, // F1
+	60:  {0xffbf, 0xffbf}, // F2
+	61:  {0xffc0, 0xffc0}, // F3
+	62:  {0xffc1, 0xffc1}, // F4
+	63:  {0xffc2, 0xffc2}, // F5
+	64:  {0xffc3, 0xffc3}, // F6
+	65:  {0xffc4, 0xffc4}, // F7
+	66:  {0xffc5, 0xffc5}, // F8
+	67:  {0xffc6, 0xffc6}, // F9
+	68:  {0xffc7, 0xffc7}, // F10
+	71:  {'7', '7'},       // KP_7
+	72:  {'8', '8'},       // KP_8
+	73:  {'9', '9'},       // KP_9
+	74:  {'-', '-'},       // KP_Subtract
+	75:  {'4', '4'},       // KP_4
+	76:  {'5', '5'},       // KP_5
+	77:  {'6', '6'},       // KP_6
+	78:  {'+', '+'},       // KP_Add
+	79:  {'1', '1'},       // KP_1
+	80:  {'2', '2'},       // KP_2
+	81:  {'3', '3'},       // KP_3
+	82:  {'0', '0'},       // KP_0
+	83:  {'.', '.'},       // KP_Decimal
+	87:  {0xffc8, 0xffc8}, // F11
+	88:  {0xffc9, 0xffc9}, // F12
+	96:  {0xff8d, 0xff8d}, // KP_Enter
+	97:  {0xffe4, 0xffe4}, // Control_R
+	98:  {'/', '/'},       // KP_Divide
+	100: {0xffea, 0xffea}, // Alt_R
+	102: {0xff50, 0xff50}, // Home
+	103: {0xff52, 0xff52}, // Up
+	104: {0xff55, 0xff55}, // Page_Up
+	105: {0xff51, 0xff51}, // Left
+	106: {0xff53, 0xff53}, // Right
+	107: {0xff57, 0xff57}, // End
+	108: {0xff54, 0xff54}, // Down
+	109: {0xff56, 0xff56}, // Page_Down
+	110: {0xff63, 0xff63}, // Insert
+	111: {0xffff, 0xffff}, // Delete
+	119: {0xff13, 0xff13}, // Pause
+	125: {0xffeb, 0xffeb}, // Super_L
+	126: {0xffec, 0xffec}, // Super_R
+}
+
+// evdevModifierFlags maps the key codes of modifier keys to their key flags.
+var evdevModifierFlags = map[uint16]int{
+	29:  keyFlagCtrl,
+	97:  keyFlagCtrl,
+	42:  keyFlagShift,
+	54:  keyFlagShift,
+	56:  keyFlagAlt,
+	100: keyFlagAlt,
+	125: keyFlagMeta,
+	126: keyFlagMeta,
+}
+
+// An evdevDevice reads events from one input device. Events are grouped into
+// frames which end with a SYN_REPORT event, like the kernel sends them.
+type evdevDevice struct {
+	file    *os.File
+	reader  *bufio.Reader
+	absInfo [2]*evdevAbsInfo
+}
+
+// openEvdevDevice opens an input device. Any file which produces input_event
+// structures may be used, although absolute axes are only scaled to the
+// screen if their ranges can be queried from the kernel.
+func openEvdevDevice(path string) (*evdevDevice, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &evdevDevice{file: f, reader: bufio.NewReader(f)}
+	for axis := range d.absInfo {
+		var info evdevAbsInfo
+		request := uintptr(2<<30 | unsafe.Sizeof(info)<<16 | 'E'<<8 |
+			(0x40 + uintptr(axis)))
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request,
+			uintptr(unsafe.Pointer(&info)))
+		if errno == 0 && info.Maximum > info.Minimum {
+			d.absInfo[axis] = &info
+		}
+	}
+	return d, nil
+}
+
+// readFrame reads the events up to and including the next SYN_REPORT.
+func (d *evdevDevice) readFrame() ([]evdevEvent, error) {
+	var frame []evdevEvent
+	for {
+		var evt evdevEvent
+		if err := binary.Read(d.reader, binary.LittleEndian, &evt); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		frame = append(frame, evt)
+		if evt.Type == evdevSyn && evt.Code == evdevSynReport {
+			return frame, nil
+		}
+	}
+}
+
+// scaleAbs converts the value of an absolute axis to a screen coordinate. If
+// the range of the axis is unknown, values are taken to be in pixels.
+func (d *evdevDevice) scaleAbs(axis int, value int32, screenSize float64) float64 {
+	info := d.absInfo[axis]
+	if info == nil {
+		return float64(value)
+	}
+	return float64(value-info.Minimum) * screenSize /
+		float64(info.Maximum-info.Minimum+1)
+}
@@ -0,0 +1,405 @@
+// +build fbdev
+// +build linux
+
+package gogui
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode"
+	"unsafe"
+)
+
+// fbTitleHeight is the height of the title bars which the framebuffer backend
+// draws above windows.
+const fbTitleHeight = 22
+
+// The fbdev driver draws windows into a Linux framebuffer and reads input from
+// evdev devices. It is available when the framebuffer can be opened.
+//
+// The framebuffer is /dev/fb0 unless the GOGUI_FB environment variable names
+// another device or file. Its size and pixel format are read from the device,
+// or from the GOGUI_FB_SIZE (e.g. "800x480"), GOGUI_FB_STRIDE (bytes per row),
+// and GOGUI_FB_FORMAT (e.g. "rgb565" or "xrgb8888") environment variables.
+// Input is read from the evdev devices in GOGUI_INPUT, which is a
+// colon-separated list of paths, or from every /dev/input/event* device.
+func init() {
+	RegisterDriver("fbdev", newSoftApp(newFBHost(fbConfigFromEnv())))
+}
+
+// An fbConfig says which devices the framebuffer backend uses. The geometry
+// fields override what the framebuffer device reports; a zero value or nil
+// format means that the device should be asked.
+type fbConfig struct {
+	device string
+	inputs []string
+
+	width  int
+	height int
+	stride int
+	format *fbFormat
+
+	// err records an invalid setting, which is reported by Main.
+	err error
+}
+
+func fbConfigFromEnv() *fbConfig {
+	c := &fbConfig{device: os.Getenv("GOGUI_FB")}
+	if c.device == "" {
+		c.device = "/dev/fb0"
+	}
+	if inputs := os.Getenv("GOGUI_INPUT"); inputs != "" {
+		c.inputs = filepath.SplitList(inputs)
+	} else {
+		c.inputs, _ = filepath.Glob("/dev/input/event*")
+	}
+	if size := os.Getenv("GOGUI_FB_SIZE"); size != "" {
+		parts := strings.Split(size, "x")
+		if len(parts) == 2 {
+			c.width, _ = strconv.Atoi(parts[0])
+			c.height, _ = strconv.Atoi(parts[1])
+		}
+		if c.width <= 0 || c.height <= 0 {
+			c.err = errors.New("invalid GOGUI_FB_SIZE: " + size)
+		}
+	}
+	if stride := os.Getenv("GOGUI_FB_STRIDE"); stride != "" {
+		var err error
+		c.stride, err = strconv.Atoi(stride)
+		if err != nil || c.stride <= 0 {
+			c.err = errors.New("invalid GOGUI_FB_STRIDE: " + stride)
+		}
+	}
+	if name := os.Getenv("GOGUI_FB_FORMAT"); name != "" {
+		format, err := parseFBFormat(name)
+		if err != nil {
+			c.err = err
+		}
+		c.format = &format
+	}
+	return c
+}
+
+// fbVarScreenInfo is the fb_var_screeninfo structure. The fields which are
+// not used are lumped together at the end.
+type fbVarScreenInfo struct {
+	XRes, YRes               uint32
+	XResVirtual, YResVirtual uint32
+	XOffset, YOffset         uint32
+	BitsPerPixel             uint32
+	Grayscale                uint32
+	Red, Green, Blue, Transp [3]uint32
+	Rest                     [20]uint32
+}
+
+// fbFixScreenInfo is the fb_fix_screeninfo structure.
+type fbFixScreenInfo struct {
+	ID           [16]byte
+	SmemStart    uintptr
+	SmemLen      uint32
+	Type         uint32
+	TypeAux      uint32
+	Visual       uint32
+	XPanStep     uint16
+	YPanStep     uint16
+	YWrapStep    uint16
+	LineLength   uint32
+	MmioStart    uintptr
+	MmioLen      uint32
+	Accel        uint32
+	Capabilities uint16
+	Reserved     [2]uint16
+}
+
+const (
+	fbIoctlGetVarInfo = 0x4600
+	fbIoctlGetFixInfo = 0x4602
+)
+
+// An fbHost composites windows into a Linux framebuffer and reads input from
+// evdev devices.
+type fbHost struct {
+	config  *fbConfig
+	app     *softApp
+	desktop *softDesktop
+
+	file   *os.File
+	width  int
+	height int
+	stride int
+	format fbFormat
+
+	// rows holds the encoded rows which were last written, so that only
+	// changed rows are written again.
+	rows [][]byte
+
+	pointer       point
+	pointerDown   bool
+	cursorVisible bool
+
+	modifiers int
+	capsLock  bool
+}
+
+func newFBHost(c *fbConfig) *fbHost {
+	return &fbHost{config: c}
+}
+
+func (f *fbHost) available() bool {
+	file, err := os.OpenFile(f.config.device, os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	file.Close()
+	return true
+}
+
+func (f *fbHost) start(a *softApp, info *AppInfo) error {
+	if f.config.err != nil {
+		return f.config.err
+	}
+	f.app = a
+	f.desktop = newSoftDesktop(a, fbTitleHeight, f.present)
+	file, err := os.OpenFile(f.config.device, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	if err := f.readGeometry(); err != nil {
+		file.Close()
+		return err
+	}
+	a.screen = Rect{0, 0, float64(f.width), float64(f.height)}
+	f.pointer = point{float64(f.width) / 2, float64(f.height) / 2}
+
+	for _, path := range f.config.inputs {
+		device, err := openEvdevDevice(path)
+		if err != nil {
+			continue
+		}
+		go f.readInput(device)
+	}
+	f.desktop.invalidate()
+	return nil
+}
+
+func (f *fbHost) showWindow(w *softWindow) {
+	f.desktop.invalidate()
+}
+
+func (f *fbHost) hideWindow(w *softWindow) {
+	f.desktop.windowHidden(w)
+}
+
+func (f *fbHost) updateWindow(w *softWindow) {
+	f.desktop.invalidate()
+}
+
+func (f *fbHost) drawWindow(w *softWindow) {
+	f.desktop.invalidate()
+}
+
+// readGeometry determines the size, stride, and pixel format of the
+// framebuffer from the configuration and the device.
+func (f *fbHost) readGeometry() error {
+	var varInfo fbVarScreenInfo
+	var fixInfo fbFixScreenInfo
+	isDevice := fbIoctl(f.file, fbIoctlGetVarInfo,
+		unsafe.Pointer(&varInfo)) == nil
+	if isDevice {
+		isDevice = fbIoctl(f.file, fbIoctlGetFixInfo,
+			unsafe.Pointer(&fixInfo)) == nil
+	}
+
+	f.width, f.height = f.config.width, f.config.height
+	if f.width == 0 {
+		if !isDevice {
+			return errors.New("framebuffer size is unknown; set GOGUI_FB_SIZE")
+		}
+		f.width, f.height = int(varInfo.XRes), int(varInfo.YRes)
+	}
+
+	if f.config.format != nil {
+		f.format = *f.config.format
+	} else if isDevice {
+		field := func(x [3]uint32) fbBitfield {
+			return fbBitfield{offset: x[0], length: x[1]}
+		}
+		f.format = fbFormat{
+			bitsPerPixel: int(varInfo.BitsPerPixel),
+			red:          field(varInfo.Red),
+			green:        field(varInfo.Green),
+			blue:         field(varInfo.Blue),
+			alpha:        field(varInfo.Transp),
+		}
+		if f.format.bitsPerPixel < 16 {
+			return errors.New("unsupported framebuffer depth: " +
+				strconv.Itoa(f.format.bitsPerPixel))
+		}
+	} else {
+		f.format = fbFormats["xrgb8888"]
+	}
+
+	f.stride = f.config.stride
+	if f.stride == 0 {
+		if isDevice && fixInfo.LineLength > 0 {
+			f.stride = int(fixInfo.LineLength)
+		} else {
+			f.stride = f.width * f.format.bytesPerPixel()
+		}
+	}
+	if f.stride < f.width*f.format.bytesPerPixel() {
+		return errors.New("framebuffer stride is too small")
+	}
+	return nil
+}
+
+// present writes the changed rows of the composited screen to the
+// framebuffer.
+func (f *fbHost) present(img *image.RGBA) {
+	// The cursor is only shown once a mouse has moved, since a touch screen
+	// needs no cursor.
+	if f.cursorVisible {
+		drawSoftCursor(img, f.pointer.X, f.pointer.Y)
+	}
+	if len(f.rows) != f.height {
+		f.rows = make([][]byte, f.height)
+	}
+	rowSize := f.width * f.format.bytesPerPixel()
+	row := make([]byte, rowSize)
+	for y := 0; y < f.height; y++ {
+		f.format.encodeRow(row, img, y)
+		if bytes.Equal(row, f.rows[y]) {
+			continue
+		}
+		if _, err := f.file.WriteAt(row, int64(y*f.stride)); err != nil {
+			return
+		}
+		if f.rows[y] == nil {
+			f.rows[y] = make([]byte, rowSize)
+		}
+		copy(f.rows[y], row)
+	}
+}
+
+// readInput reads event frames from a device and handles them on the main
+// loop.
+func (f *fbHost) readInput(d *evdevDevice) {
+	defer d.file.Close()
+	for {
+		frame, err := d.readFrame()
+		if err != nil {
+			return
+		}
+		f.app.RunOnMain(func() {
+			f.handleFrame(d, frame)
+		})
+	}
+}
+
+func (f *fbHost) handleFrame(d *evdevDevice, frame []evdevEvent) {
+	oldPointer := f.pointer
+	oldDown := f.pointerDown
+	for _, evt := range frame {
+		switch evt.Type {
+		case evdevKey:
+			if evt.Code == evdevBtnLeft || evt.Code == evdevBtnTouch {
+				f.pointerDown = evt.Value != 0
+			} else {
+				f.handleKey(evt.Code, evt.Value)
+			}
+		case evdevRel:
+			if evt.Code == evdevRelX {
+				f.pointer.X += float64(evt.Value)
+			} else if evt.Code == evdevRelY {
+				f.pointer.Y += float64(evt.Value)
+			}
+			if !f.cursorVisible {
+				f.cursorVisible = true
+				f.desktop.invalidate()
+			}
+		case evdevAbs:
+			if evt.Code == evdevAbsX {
+				f.pointer.X = d.scaleAbs(0, evt.Value, float64(f.width))
+			} else if evt.Code == evdevAbsY {
+				f.pointer.Y = d.scaleAbs(1, evt.Value, float64(f.height))
+			}
+		}
+	}
+	f.pointer.X = math.Max(0, math.Min(float64(f.width-1), f.pointer.X))
+	f.pointer.Y = math.Max(0, math.Min(float64(f.height-1), f.pointer.Y))
+
+	moved := f.pointer != oldPointer
+	if moved && f.cursorVisible {
+		f.desktop.invalidate()
+	}
+	switch {
+	case f.pointerDown && !oldDown:
+		f.desktop.mouse(mouseEventDown, f.pointer.X, f.pointer.Y)
+	case !f.pointerDown && oldDown:
+		f.desktop.mouse(mouseEventUp, f.pointer.X, f.pointer.Y)
+	case moved && f.pointerDown:
+		f.desktop.mouse(mouseEventDrag, f.pointer.X, f.pointer.Y)
+	case moved:
+		f.desktop.mouse(mouseEventMove, f.pointer.X, f.pointer.Y)
+	}
+}
+
+// handleKey handles a key event. The value is 0 for a release, 1 for a press,
+// or 2 for an automatic repeat.
+func (f *fbHost) handleKey(code uint16, value int32) {
+	if flag, ok := evdevModifierFlags[code]; ok {
+		if value == 0 {
+			f.modifiers &^= flag
+		} else {
+			f.modifiers |= flag
+		}
+	}
+	if code == evdevKeyCapsLock && value == 1 {
+		f.capsLock = !f.capsLock
+	}
+
+	syms, ok := evdevKeysyms[code]
+	w := f.desktop.frontWindow()
+	if !ok || w == nil {
+		return
+	}
+	rawCode := keysymCharCode(syms[0])
+	if value == 0 {
+		w.keyEvent(keyEventUp, int(code), rawCode, 0, f.modifiers)
+		return
+	}
+
+	modCode := -1
+	if !keysymIsModifier(syms[0]) {
+		modSym := syms[0]
+		if (f.modifiers & keyFlagShift) != 0 {
+			modSym = syms[1]
+		}
+		if r := keysymRune(modSym); r >= 0 {
+			if f.capsLock {
+				r = unicode.ToUpper(r)
+			}
+			modCode = int(r)
+		} else {
+			modCode = keysymCharCode(modSym)
+		}
+	}
+	w.keyEvent(keyEventDown, int(code), rawCode, modCode, f.modifiers)
+}
+
+func fbIoctl(file *os.File, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), request,
+		uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
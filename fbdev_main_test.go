@@ -0,0 +1,98 @@
+// +build fbdev
+// +build linux
+
+package gogui
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFramebuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gogui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fbPath := filepath.Join(dir, "fb")
+	inputPath := filepath.Join(dir, "input")
+	err = ioutil.WriteFile(fbPath, make([]byte, 100*80*4), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(inputPath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"GOGUI_FB": fbPath,
+		"GOGUI_FB_SIZE": "100x80", "GOGUI_FB_FORMAT": "xrgb8888",
+		"GOGUI_INPUT": inputPath} {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	app := newSoftApp(newFBHost(fbConfigFromEnv()))
+	w, _ := app.NewWindow(Rect{20, 30, 40, 30})
+	c, _ := app.NewCanvas(Rect{0, 0, 40, 30})
+	c.SetDrawHandler(func(ctx DrawContext) {
+		ctx.SetFill(Color{1, 0, 0, 1})
+		ctx.FillRect(Rect{5, 5, 10, 10})
+	})
+	w.Add(c)
+	clicks := make(chan MouseEvent, 1)
+	w.SetMouseDownHandler(func(e MouseEvent) {
+		clicks <- e
+	})
+	w.Show()
+	go app.Main(&AppInfo{})
+
+	// Opening the pipe waits for the driver to open the other end.
+	input, err := os.OpenFile(inputPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+
+	// Find the red square on the screen.
+	redX, redY := -1, -1
+	deadline := time.Now().Add(5 * time.Second)
+	for redX < 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		data, err := ioutil.ReadFile(fbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i+3 < len(data); i += 4 {
+			if data[i] == 0 && data[i+1] == 0 && data[i+2] == 0xff {
+				redX, redY = i/4%100, i/4/100
+				break
+			}
+		}
+	}
+	if redX < 0 {
+		t.Fatal("the window was not drawn into the framebuffer")
+	}
+
+	// Touch the top-left corner of the square.
+	events := []evdevEvent{
+		{Type: evdevAbs, Code: evdevAbsX, Value: int32(redX)},
+		{Type: evdevAbs, Code: evdevAbsY, Value: int32(redY)},
+		{Type: evdevKey, Code: evdevBtnTouch, Value: 1},
+		{Type: evdevSyn, Code: evdevSynReport},
+	}
+	if err := binary.Write(input, binary.LittleEndian, events); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-clicks:
+		if e.X != 5 || e.Y != 5 {
+			t.Error("unexpected touch:", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the window did not get the touch")
+	}
+}
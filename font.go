@@ -0,0 +1,209 @@
+package gogui
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A FontWeight is the boldness of a font, from 1 to 1000, as in CSS.
+type FontWeight int
+
+// The weights which have names, from the thinnest to the boldest.
+const (
+	FontWeightThin       FontWeight = 100
+	FontWeightExtraLight FontWeight = 200
+	FontWeightLight      FontWeight = 300
+	FontWeightNormal     FontWeight = 400
+	FontWeightMedium     FontWeight = 500
+	FontWeightSemiBold   FontWeight = 600
+	FontWeightBold       FontWeight = 700
+	FontWeightExtraBold  FontWeight = 800
+	FontWeightBlack      FontWeight = 900
+)
+
+// A FontStretch is how narrow or wide the glyphs of a font are.
+type FontStretch int
+
+// The widths which have names, from the narrowest to the widest.
+const (
+	FontStretchUltraCondensed FontStretch = iota + 1
+	FontStretchExtraCondensed
+	FontStretchCondensed
+	FontStretchSemiCondensed
+	FontStretchNormal
+	FontStretchSemiExpanded
+	FontStretchExpanded
+	FontStretchExtraExpanded
+	FontStretchUltraExpanded
+)
+
+var fontStretchNames = []string{"ultra-condensed", "extra-condensed",
+	"condensed", "semi-condensed", "normal", "semi-expanded", "expanded",
+	"extra-expanded", "ultra-expanded"}
+
+// A Font describes a font by its family and style. Backends draw with the
+// installed font which matches it best.
+//
+// If neither the family nor any of the fallbacks is installed, text is drawn
+// with the default font of the backend: Helvetica on OS X (or the system font
+// if Helvetica is missing), the browser's sans-serif font on the web, and the
+// built-in font in the software renderer. The software renderer has no other
+// font, so it ignores everything but the size.
+type Font struct {
+	// Family is the name of a font family, such as "Helvetica", or the
+	// PostScript name of a font, such as "Helvetica-Bold".
+	Family string
+
+	// Size is the size of the font in points.
+	Size float64
+
+	// Weight is the boldness of the font. Zero means FontWeightNormal.
+	Weight FontWeight
+
+	// Italic selects the italic or oblique style of the family.
+	Italic bool
+
+	// Stretch is the width of the font. Zero means FontStretchNormal.
+	Stretch FontStretch
+
+	// Fallbacks are the families to try, in order, if Family is not
+	// installed.
+	Fallbacks []string
+}
+
+// families returns the names to try for a font, in order.
+func (f Font) families() []string {
+	var res []string
+	for _, name := range append([]string{f.Family}, f.Fallbacks...) {
+		if name != "" {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// weight returns the font's weight, replacing zero with FontWeightNormal and
+// clamping it to the range CSS allows.
+func (f Font) weight() FontWeight {
+	if f.Weight == 0 {
+		return FontWeightNormal
+	} else if f.Weight < 1 {
+		return 1
+	} else if f.Weight > 1000 {
+		return 1000
+	}
+	return f.Weight
+}
+
+// stretch returns the font's stretch, replacing invalid values with
+// FontStretchNormal.
+func (f Font) stretch() FontStretch {
+	if f.Stretch < FontStretchUltraCondensed ||
+		f.Stretch > FontStretchUltraExpanded {
+		return FontStretchNormal
+	}
+	return f.Stretch
+}
+
+// FontMetrics holds the vertical measurements of a font. Every field is a
+// distance from the baseline, or a size, and none of them are negative for
+// ordinary fonts.
+type FontMetrics struct {
+	// Ascent is how far the font goes above the baseline.
+	Ascent float64
+
+	// Descent is how far the font goes below the baseline.
+	Descent float64
+
+	// Leading is the extra space which the font asks for between lines.
+	Leading float64
+
+	// XHeight is the height of lowercase letters such as x.
+	XHeight float64
+
+	// CapHeight is the height of capital letters.
+	CapHeight float64
+
+	// UnderlinePosition is how far below the baseline the center of an
+	// underline goes.
+	UnderlinePosition float64
+
+	// UnderlineThickness is the thickness of an underline.
+	UnderlineThickness float64
+}
+
+// estimateUnderline fills in the underline of a font's metrics, for browsers
+// which do not report it.
+func estimateUnderline(m *FontMetrics, size float64) {
+	m.UnderlinePosition = m.Descent / 3
+	m.UnderlineThickness = size / 15
+}
+
+var errCannotListFonts = errors.New("gogui: the driver cannot list fonts")
+
+// Fonts returns the PostScript names of the fonts which the current driver
+// can draw with, sorted by name. It fails for drivers which cannot tell, such
+// as those that draw in a web browser.
+func Fonts() ([]string, error) {
+	d, err := chooseDriver("")
+	if err != nil {
+		return nil, err
+	}
+	fd, ok := d.(FontDriver)
+	if !ok {
+		return nil, errCannotListFonts
+	}
+	names, err := fd.Fonts()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// FontFamilies returns the names of the font families which the current
+// driver can draw with, sorted by name. It fails for drivers which cannot
+// tell, such as those that draw in a web browser.
+func FontFamilies() ([]string, error) {
+	d, err := chooseDriver("")
+	if err != nil {
+		return nil, err
+	}
+	fd, ok := d.(FontDriver)
+	if !ok {
+		return nil, errCannotListFonts
+	}
+	names, err := fd.FontFamilies()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// cssFont returns the CSS font shorthand for a font. Browsers skip families
+// which are not installed, and Helvetica and sans-serif come last so that
+// there is always a match.
+func cssFont(f Font) string {
+	var parts []string
+	if f.Italic {
+		parts = append(parts, "italic")
+	}
+	if w := f.weight(); w != FontWeightNormal {
+		parts = append(parts, strconv.Itoa(int(w)))
+	}
+	if s := f.stretch(); s != FontStretchNormal {
+		parts = append(parts, fontStretchNames[s-1])
+	}
+	parts = append(parts, strconv.FormatFloat(f.Size, 'g', -1, 64)+"px")
+	var families []string
+	for _, name := range f.families() {
+		name = strings.Replace(name, `\`, `\\`, -1)
+		name = strings.Replace(name, `"`, `\"`, -1)
+		families = append(families, `"`+name+`"`)
+	}
+	families = append(families, "Helvetica", "sans-serif")
+	return strings.Join(parts, " ") + " " + strings.Join(families, ", ")
+}
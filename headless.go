@@ -0,0 +1,8 @@
+package gogui
+
+// The headless driver draws windows in memory with the software renderer and
+// never shows them to the user. It is always available, so it is used when no
+// other driver can run.
+func init() {
+	RegisterDriver("headless", newSoftApp(nil))
+}
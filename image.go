@@ -0,0 +1,198 @@
+package gogui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+)
+
+// An Interpolation says how images are sampled when they are scaled, rotated,
+// or drawn at fractional positions.
+type Interpolation int
+
+const (
+	// InterpolationNearest uses the nearest pixel, which keeps pixel art
+	// sharp.
+	InterpolationNearest Interpolation = iota
+
+	// InterpolationBilinear blends the four nearest pixels. This is the
+	// default.
+	InterpolationBilinear
+
+	// InterpolationHigh gives the smoothest result, at the cost of speed.
+	InterpolationHigh
+)
+
+// An Image is an image which can be drawn over and over without being
+// converted each time. Backends keep their own copy of its pixels, such as a
+// CGImage on OS X, the first time it is drawn.
+//
+// An Image is an image.Image, so it may be passed to anything that takes one.
+type Image struct {
+	rgba *image.RGBA
+
+	lock   sync.Mutex
+	native interface{}
+}
+
+// NewImage copies an image into an Image. Changing img afterwards does not
+// change the Image.
+func NewImage(img image.Image) *Image {
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+	return &Image{rgba: rgba}
+}
+
+// At returns the color of a pixel.
+func (i *Image) At(x, y int) color.Color {
+	return i.rgba.At(x, y)
+}
+
+// Bounds returns the bounds of the image.
+func (i *Image) Bounds() image.Rectangle {
+	return i.rgba.Bounds()
+}
+
+// ColorModel returns color.RGBAModel.
+func (i *Image) ColorModel() color.Model {
+	return i.rgba.ColorModel()
+}
+
+// nativeImage returns the copy of the image which a backend keeps, creating it
+// with a function the first time.
+func (i *Image) nativeImage(create func() interface{}) interface{} {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.native == nil {
+		i.native = create()
+	}
+	return i.native
+}
+
+// imageRGBA returns the pixels of an image as an RGBA image, which may be the
+// image itself.
+func imageRGBA(img image.Image) *image.RGBA {
+	switch img := img.(type) {
+	case *Image:
+		return img.rgba
+	case *image.RGBA:
+		return img
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+	return rgba
+}
+
+// imageNRGBA returns the pixels in part of an RGBA image without
+// premultiplied alpha, which is the format of HTML5 ImageData.
+func imageNRGBA(img *image.RGBA, r image.Rectangle) []byte {
+	res := make([]byte, 0, r.Dx()*r.Dy()*4)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			a := row[i+3]
+			if a == 0 {
+				res = append(res, 0, 0, 0, 0)
+				continue
+			}
+			for _, c := range row[i : i+3] {
+				res = append(res, uint8((int(c)*0xff+int(a)/2)/int(a)))
+			}
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// imageBoundsRect returns the bounds of an image as a Rect.
+func imageBoundsRect(img image.Image) Rect {
+	b := img.Bounds()
+	return Rect{float64(b.Min.X), float64(b.Min.Y), float64(b.Dx()),
+		float64(b.Dy())}
+}
+
+// imageSourceRect returns the pixels which a source rectangle touches, or an
+// empty rectangle if it is degenerate.
+func imageSourceRect(img image.Image, src Rect) image.Rectangle {
+	if !(src.Width > 0 && src.Height > 0) {
+		return image.Rectangle{}
+	}
+	r := image.Rect(int(math.Floor(src.X)), int(math.Floor(src.Y)),
+		int(math.Ceil(src.X+src.Width)), int(math.Ceil(src.Y+src.Height)))
+	return r.Intersect(img.Bounds())
+}
+
+// sampleImage returns the premultiplied color of an image at a point in the
+// image's coordinates. Only pixels inside of r are used.
+func sampleImage(img *image.RGBA, r image.Rectangle, x, y float64,
+	q Interpolation) Color {
+	pixel := func(px, py int) [4]float64 {
+		px = clampInt(px, r.Min.X, r.Max.X-1)
+		py = clampInt(py, r.Min.Y, r.Max.Y-1)
+		i := img.PixOffset(px, py)
+		p := img.Pix[i : i+4]
+		return [4]float64{float64(p[0]) / 0xff, float64(p[1]) / 0xff,
+			float64(p[2]) / 0xff, float64(p[3]) / 0xff}
+	}
+	if q == InterpolationNearest {
+		p := pixel(int(math.Floor(x)), int(math.Floor(y)))
+		return Color{p[0], p[1], p[2], p[3]}
+	}
+
+	// Pixel centers are at half-integer coordinates.
+	fx, fy := x-0.5, y-0.5
+	x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+	tx, ty := fx-float64(x0), fy-float64(y0)
+	var sum [4]float64
+	if q == InterpolationHigh {
+		wx, wy := cubicWeights(tx), cubicWeights(ty)
+		for j := 0; j < 4; j++ {
+			for i := 0; i < 4; i++ {
+				p := pixel(x0+i-1, y0+j-1)
+				for k := range sum {
+					sum[k] += p[k] * wx[i] * wy[j]
+				}
+			}
+		}
+	} else {
+		wx, wy := [2]float64{1 - tx, tx}, [2]float64{1 - ty, ty}
+		for j := 0; j < 2; j++ {
+			for i := 0; i < 2; i++ {
+				p := pixel(x0+i, y0+j)
+				for k := range sum {
+					sum[k] += p[k] * wx[i] * wy[j]
+				}
+			}
+		}
+	}
+
+	// Cubic filters overshoot, so keep the color valid.
+	a := clampUnit(sum[3])
+	return Color{math.Min(a, math.Max(0, sum[0])),
+		math.Min(a, math.Max(0, sum[1])), math.Min(a, math.Max(0, sum[2])), a}
+}
+
+// cubicWeights returns the Catmull-Rom weights of four pixels for a point
+// which is t of the way from the second pixel to the third.
+func cubicWeights(t float64) [4]float64 {
+	t2, t3 := t*t, t*t*t
+	return [4]float64{
+		(-t3 + 2*t2 - t) / 2,
+		(3*t3 - 5*t2 + 2) / 2,
+		(-3*t3 + 4*t2 + t) / 2,
+		(t3 - t2) / 2,
+	}
+}
+
+func clampInt(x, min, max int) int {
+	if x < min {
+		return min
+	} else if x > max {
+		return max
+	}
+	return x
+}
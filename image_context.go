@@ -0,0 +1,800 @@
+package gogui
+
+import (
+	"image"
+	"math"
+)
+
+type imageContext struct {
+	image *image.RGBA
+	base  Matrix
+
+	path []polyline
+
+	state imageState
+	saved []imageState
+}
+
+// imageState is the part of an imageContext which Save and Restore keep track
+// of.
+type imageState struct {
+	transform Matrix
+
+	// clip is the coverage of the clipping region if clipped is set. A nil
+	// clip means that everything is clipped away.
+	clipped bool
+	clip    *image.Alpha
+
+	fill     Paint
+	stroke   Paint
+	line     lineStyle
+	fillRule FillRule
+	font     Font
+
+	textAlign    TextAlign
+	textBaseline TextBaseline
+
+	interpolation Interpolation
+	globalAlpha   float64
+	compositeMode CompositeMode
+	shadow        shadowStyle
+}
+
+// NewImageContext creates a DrawContext which draws into an image using the
+// software renderer.
+// The point (0, 0) in the context is the top-left corner of the image's bounds.
+// Text is drawn with a built-in font regardless of the font passed to SetFont
+// or SetFontDescriptor, except for its size.
+func NewImageContext(img *image.RGBA) DrawContext {
+	min := img.Bounds().Min
+	return newImageContext(img, TranslationMatrix(float64(min.X),
+		float64(min.Y)))
+}
+
+// newImageContext creates an imageContext which draws into img, mapping every
+// point through base.
+func newImageContext(img *image.RGBA, base Matrix) *imageContext {
+	return &imageContext{
+		image: img,
+		base:  base,
+		state: imageState{
+			transform: base,
+			fill:      Color{0, 0, 0, 1},
+			stroke:    Color{0, 0, 0, 1},
+			line: lineStyle{
+				thickness:  1,
+				cap:        LineCapRound,
+				join:       LineJoinRound,
+				miterLimit: defaultMiterLimit,
+			},
+			font: Font{Family: "Helvetica", Size: 18},
+
+			interpolation: InterpolationBilinear,
+			globalAlpha:   1,
+			compositeMode: CompositeSourceOver,
+		},
+	}
+}
+
+func (d *imageContext) Arc(x, y, radius, startAngle, endAngle float64,
+	clockwise bool) {
+	radius = math.Max(0, radius)
+	sweep := arcSweep(startAngle, endAngle, clockwise)
+	full := float64(ellipseSegments(radius * d.deviceScale()))
+	count := int(math.Ceil(full * math.Abs(sweep) / (2 * math.Pi)))
+	if count < 1 {
+		count = 1
+	}
+	for i := 0; i <= count; i++ {
+		angle := startAngle + sweep*float64(i)/float64(count)
+		p := d.toDevice(x+radius*math.Cos(angle), y+radius*math.Sin(angle))
+		if i == 0 && len(d.path) == 0 {
+			d.path = append(d.path, polyline{points: []point{p}})
+		} else {
+			d.lineTo(p)
+		}
+	}
+}
+
+func (d *imageContext) ArcTo(x1, y1, x2, y2, radius float64) {
+	if len(d.path) == 0 {
+		d.MoveTo(x1, y1)
+	}
+	inv, ok := d.state.transform.Invert()
+	if !ok {
+		return
+	}
+	var p0 point
+	p0.X, p0.Y = inv.Apply(d.currentPoint().X, d.currentPoint().Y)
+	center, start, end, clockwise, ok := tangentArc(p0, point{x1, y1},
+		point{x2, y2}, radius)
+	if !ok {
+		d.LineTo(x1, y1)
+		return
+	}
+	d.Arc(center.X, center.Y, radius, start, end, clockwise)
+}
+
+func (d *imageContext) AttributedTextSize(s *AttributedString) (float64, float64) {
+	width, height, _ := attributedTextSize(layoutMeasurer(d), s)
+	return width, height
+}
+
+func (d *imageContext) BeginPath() {
+	d.path = nil
+}
+
+func (d *imageContext) ClearShadow() {
+	d.state.shadow = shadowStyle{}
+}
+
+func (d *imageContext) ClipPath() {
+	d.clipPolygons(d.pathPolygons(), d.state.fillRule)
+	d.path = nil
+}
+
+func (d *imageContext) ClipRect(r Rect) {
+	d.clipPolygons([]polygon{d.rectPolygon(r)}, NonZero)
+}
+
+func (d *imageContext) ClosePath() {
+	if len(d.path) > 0 {
+		d.path[len(d.path)-1].closed = true
+	}
+}
+
+func (d *imageContext) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	if len(d.path) == 0 {
+		d.MoveTo(c1x, c1y)
+	}
+	points := flattenCubic(d.currentPoint(), d.toDevice(c1x, c1y),
+		d.toDevice(c2x, c2y), d.toDevice(x, y))
+	for _, p := range points {
+		d.lineTo(p)
+	}
+}
+
+func (d *imageContext) DrawImage(img image.Image, dst Rect) {
+	d.DrawSubImage(img, imageBoundsRect(img), dst)
+}
+
+func (d *imageContext) DrawSubImage(img image.Image, src Rect, dst Rect) {
+	pixels := imageSourceRect(img, src)
+	if pixels.Empty() || dst.Width == 0 || dst.Height == 0 {
+		return
+	}
+
+	// toSource maps device space to the image's coordinates.
+	toSource, ok := d.state.transform.Invert()
+	if !ok {
+		return
+	}
+	sx, sy := src.Width/dst.Width, src.Height/dst.Height
+	toSource = toSource.Concat(Matrix{A: sx, D: sy, E: src.X - dst.X*sx,
+		F: src.Y - dst.Y*sy})
+
+	// When an image is shrunk, InterpolationHigh averages several samples per
+	// pixel so that no part of the image is skipped.
+	q := d.state.interpolation
+	n := 1
+	if q == InterpolationHigh {
+		shrink := math.Max(math.Hypot(toSource.A, toSource.B),
+			math.Hypot(toSource.C, toSource.D))
+		n = int(math.Max(1, math.Min(8, math.Ceil(shrink))))
+	}
+
+	rgba := imageRGBA(img)
+	weight := 1 / float64(n*n)
+	shade := func(x, y int) (Color, bool) {
+		var sum Color
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				px, py := toSource.Apply(float64(x)+(float64(j)+0.5)/float64(n),
+					float64(y)+(float64(i)+0.5)/float64(n))
+				c := sampleImage(rgba, pixels, px, py, q)
+				sum.R += c.R * weight
+				sum.G += c.G * weight
+				sum.B += c.B * weight
+				sum.A += c.A * weight
+			}
+		}
+		return sum, true
+	}
+	d.drawShape([]polygon{d.rectPolygon(dst)}, NonZero, shade)
+}
+
+func (d *imageContext) DrawTextLayout(t *TextLayout, x, y float64) {
+	drawTextLayout(d, t, x, y)
+}
+
+func (d *imageContext) FillAttributedText(s *AttributedString, x, y float64) {
+	drawAttributedText(d, s, x, y, d.state.textAlign, d.state.textBaseline)
+}
+
+func (d *imageContext) FillEllipse(r Rect) {
+	d.fillPolygons([]polygon{d.ellipsePolygon(r)}, NonZero, d.state.fill)
+}
+
+func (d *imageContext) FillPath() {
+	d.fillPolygons(d.pathPolygons(), d.state.fillRule, d.state.fill)
+	d.path = nil
+}
+
+func (d *imageContext) FillPathObject(p *Path) {
+	d.BeginPath()
+	p.addTo(d)
+	d.FillPath()
+}
+
+func (d *imageContext) FillRect(r Rect) {
+	d.fillPolygons([]polygon{d.rectPolygon(r)}, NonZero, d.state.fill)
+}
+
+func (d *imageContext) FillText(text string, x, y float64) {
+	x, y = d.textOrigin(text, x, y)
+	scale := d.state.font.Size / fontUnitsPerEm
+	polys := fontTextPolygons(text)
+	for _, poly := range polys {
+		for i, p := range poly {
+			poly[i] = d.toDevice(x+p.X*scale, y+p.Y*scale)
+		}
+	}
+	d.fillPolygons(polys, NonZero, d.state.fill)
+}
+
+func (d *imageContext) FontMetrics() FontMetrics {
+	return d.fontMetrics(d.state.font)
+}
+
+func (d *imageContext) LineTo(x, y float64) {
+	if len(d.path) == 0 {
+		d.MoveTo(x, y)
+		return
+	}
+	d.lineTo(d.toDevice(x, y))
+}
+
+func (d *imageContext) MoveTo(x, y float64) {
+	d.path = append(d.path, polyline{points: []point{d.toDevice(x, y)}})
+}
+
+func (d *imageContext) QuadraticTo(cx, cy, x, y float64) {
+	if len(d.path) == 0 {
+		d.MoveTo(cx, cy)
+	}
+	points := flattenQuadratic(d.currentPoint(), d.toDevice(cx, cy),
+		d.toDevice(x, y))
+	for _, p := range points {
+		d.lineTo(p)
+	}
+}
+
+func (d *imageContext) Restore() {
+	if len(d.saved) > 0 {
+		d.state = d.saved[len(d.saved)-1]
+		d.saved = d.saved[:len(d.saved)-1]
+	}
+}
+
+func (d *imageContext) Rotate(angle float64) {
+	d.Transform(RotationMatrix(angle))
+}
+
+func (d *imageContext) Save() {
+	d.saved = append(d.saved, d.state)
+}
+
+func (d *imageContext) Scale(x, y float64) {
+	d.Transform(ScaleMatrix(x, y))
+}
+
+func (d *imageContext) SetCompositeMode(m CompositeMode) {
+	d.state.compositeMode = m
+}
+
+func (d *imageContext) SetFill(c Color) {
+	d.state.fill = c
+}
+
+func (d *imageContext) SetFillPaint(p Paint) {
+	d.state.fill = p
+}
+
+func (d *imageContext) SetFillRule(rule FillRule) {
+	d.state.fillRule = rule
+}
+
+func (d *imageContext) SetFont(size float64, name string) {
+	d.state.font = Font{Family: name, Size: size}
+}
+
+func (d *imageContext) SetFontDescriptor(f Font) {
+	d.state.font = f
+}
+
+func (d *imageContext) SetGlobalAlpha(alpha float64) {
+	if alpha >= 0 && alpha <= 1 {
+		d.state.globalAlpha = alpha
+	}
+}
+
+func (d *imageContext) SetInterpolation(q Interpolation) {
+	d.state.interpolation = q
+}
+
+func (d *imageContext) SetLineCap(c LineCap) {
+	d.state.line.cap = c
+}
+
+func (d *imageContext) SetLineDash(pattern []float64, phase float64) {
+	if dash, ok := normalizeLineDash(pattern); ok {
+		d.state.line.dash = dash
+		d.state.line.dashPhase = phase
+	}
+}
+
+func (d *imageContext) SetLineJoin(j LineJoin) {
+	d.state.line.join = j
+}
+
+func (d *imageContext) SetMiterLimit(limit float64) {
+	if limit > 0 {
+		d.state.line.miterLimit = limit
+	}
+}
+
+func (d *imageContext) SetShadow(offsetX, offsetY, blur float64, c Color) {
+	if validShadow(offsetX, offsetY, blur) {
+		d.state.shadow = shadowStyle{offsetX, offsetY, blur, c}
+	}
+}
+
+func (d *imageContext) SetStroke(c Color) {
+	d.state.stroke = c
+}
+
+func (d *imageContext) SetStrokePaint(p Paint) {
+	d.state.stroke = p
+}
+
+func (d *imageContext) SetTextAlign(a TextAlign) {
+	d.state.textAlign = a
+}
+
+func (d *imageContext) SetTextBaseline(b TextBaseline) {
+	d.state.textBaseline = b
+}
+
+func (d *imageContext) SetThickness(thickness float64) {
+	d.state.line.thickness = thickness
+}
+
+func (d *imageContext) SetTransform(m Matrix) {
+	d.state.transform = m.Concat(d.base)
+}
+
+func (d *imageContext) StrokeEllipse(r Rect) {
+	line := polyline{points: d.ellipsePolygon(r), closed: true}
+	d.strokePolylines([]polyline{line})
+}
+
+func (d *imageContext) StrokePath() {
+	d.strokePolylines(d.path)
+	d.path = nil
+}
+
+func (d *imageContext) StrokePathObject(p *Path) {
+	d.BeginPath()
+	p.addTo(d)
+	d.StrokePath()
+}
+
+func (d *imageContext) StrokeRect(r Rect) {
+	line := polyline{points: d.rectPolygon(r), closed: true}
+	d.strokePolylines([]polyline{line})
+}
+
+func (d *imageContext) StrokeText(text string, x, y float64) {
+	x, y = d.textOrigin(text, x, y)
+	scale := d.state.font.Size / fontUnitsPerEm
+	lines := fontTextOutlines(text)
+	for _, line := range lines {
+		for i, p := range line.points {
+			line.points[i] = d.toDevice(x+p.X*scale, y+p.Y*scale)
+		}
+	}
+	d.strokePolylines(lines)
+}
+
+func (d *imageContext) TextPositions(text string) *TextPositions {
+	return textPositions(layoutMeasurer(d), d.state.font, text)
+}
+
+func (d *imageContext) TextSize(text string) (float64, float64) {
+	return d.textSize(text, d.state.font)
+}
+
+func (d *imageContext) Transform(m Matrix) {
+	d.state.transform = m.Concat(d.state.transform)
+}
+
+func (d *imageContext) Translate(x, y float64) {
+	d.Transform(TranslationMatrix(x, y))
+}
+
+// currentPoint returns the point in device space where the next segment of the
+// path starts. The path must not be empty.
+func (d *imageContext) currentPoint() point {
+	sub := d.path[len(d.path)-1]
+	if sub.closed {
+		return sub.points[0]
+	}
+	return sub.points[len(sub.points)-1]
+}
+
+// deviceScale returns the largest factor by which the transform stretches
+// lengths.
+func (d *imageContext) deviceScale() float64 {
+	m := d.state.transform
+	return math.Sqrt(math.Max(m.A*m.A+m.B*m.B, m.C*m.C+m.D*m.D))
+}
+
+func (d *imageContext) ellipsePolygon(r Rect) polygon {
+	// The ellipse is built at its size on the screen so that it gets enough
+	// points, and then mapped back through the transform.
+	scale := d.deviceScale()
+	if scale == 0 {
+		return nil
+	}
+	cx, cy := r.X+r.Width/2, r.Y+r.Height/2
+	poly := ellipsePolygon(0, 0, r.Width/2*scale, r.Height/2*scale)
+	for i, p := range poly {
+		poly[i] = d.toDevice(cx+p.X/scale, cy+p.Y/scale)
+	}
+	return poly
+}
+
+// clipPolygons intersects the clipping region with the inside of polygons.
+func (d *imageContext) clipPolygons(polys []polygon, rule FillRule) {
+	mask := rasterizePolygons(polys, d.maskBounds(), rule)
+	if d.state.clipped {
+		mask = intersectMasks(mask, d.state.clip)
+	}
+	d.state.clipped = true
+	d.state.clip = mask
+}
+
+// composite draws premultiplied colors from a function wherever a mask is set,
+// using the global alpha and the composite mode.
+func (d *imageContext) composite(mask *image.Alpha,
+	shade func(x, y int) (Color, bool)) {
+	alpha := clampUnit(d.state.globalAlpha)
+	compositeShader(d.image, mask, d.state.compositeMode,
+		func(x, y int) (Color, bool) {
+			c, ok := shade(x, y)
+			return Color{c.R * alpha, c.G * alpha, c.B * alpha, c.A * alpha}, ok
+		})
+}
+
+// coverageMask rasterizes polygons and clips the result.
+func (d *imageContext) coverageMask(polys []polygon,
+	rule FillRule) *image.Alpha {
+	mask := rasterizePolygons(polys, d.maskBounds(), rule)
+	if d.state.clipped {
+		mask = intersectMasks(mask, d.state.clip)
+	}
+	return mask
+}
+
+// drawBounds returns the part of device space where drawing may show. This
+// is the clipping region and, if there is a shadow, the area which casts a
+// shadow into it.
+func (d *imageContext) drawBounds() Rect {
+	b := d.maskBounds()
+	r := Rect{float64(b.Min.X), float64(b.Min.Y), float64(b.Dx()),
+		float64(b.Dy())}
+	if !d.state.shadow.visible() || b.Empty() {
+		return r
+	}
+	dx, dy, sigma := d.shadowOffset()
+	radius := float64(blurRadius(sigma))
+	x := math.Min(r.X, r.X-dx-radius)
+	y := math.Min(r.Y, r.Y-dy-radius)
+	return Rect{x, y, math.Max(r.X+r.Width, r.X+r.Width-dx+radius) - x,
+		math.Max(r.Y+r.Height, r.Y+r.Height-dy+radius) - y}
+}
+
+// drawShadow draws the shadow of polygons which are filled with colors from a
+// function. The polygons are moved by the shadow's offset and turned into an
+// alpha mask, which is blurred and then filled with the shadow's color.
+func (d *imageContext) drawShadow(polys []polygon, rule FillRule,
+	shade func(x, y int) (Color, bool)) {
+	dx, dy, sigma := d.shadowOffset()
+
+	// The shadow is only drawn in the clipping region, but the blur spreads
+	// pixels into it from around it.
+	bounds := d.maskBounds()
+	if bounds.Empty() {
+		return
+	}
+	radius := blurRadius(sigma)
+	area := bounds.Inset(-radius)
+	moved := make([]polygon, len(polys))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, poly := range polys {
+		moved[i] = make(polygon, len(poly))
+		for j, p := range poly {
+			p = point{p.X + dx, p.Y + dy}
+			moved[i][j] = p
+			minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+			maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+	}
+	if !(minX <= maxX && minY <= maxY) {
+		return
+	}
+
+	// The blur does not spread pixels further than its radius from the
+	// shapes, so the rest of the area stays empty.
+	shapes := image.Rect(int(math.Max(minX, -1e9))-1,
+		int(math.Max(minY, -1e9))-1, int(math.Min(maxX, 1e9))+1,
+		int(math.Min(maxY, 1e9))+1)
+	area = area.Intersect(shapes.Inset(-radius))
+	if area.Empty() {
+		return
+	}
+	mask := rasterizePolygons(moved, area, rule)
+	if mask == nil {
+		return
+	}
+
+	// The alpha of the shape comes from the pixel which casts the shadow.
+	shiftX, shiftY := int(math.Floor(dx+0.5)), int(math.Floor(dy+0.5))
+	globalAlpha := clampUnit(d.state.globalAlpha)
+	width := area.Dx()
+	alpha := make([]float64, width*area.Dy())
+	r := mask.Rect
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		maskIdx := mask.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			coverage := float64(mask.Pix[maskIdx]) / 0xff
+			maskIdx++
+			if coverage == 0 {
+				continue
+			}
+			if c, ok := shade(x-shiftX, y-shiftY); ok {
+				idx := (y-area.Min.Y)*width + x - area.Min.X
+				alpha[idx] = coverage * clampUnit(c.A) * globalAlpha
+			}
+		}
+	}
+	gaussianBlur(alpha, width, area.Dy(), sigma)
+
+	shadow := image.NewAlpha(bounds)
+	drawn := bounds.Intersect(area)
+	for y := drawn.Min.Y; y < drawn.Max.Y; y++ {
+		idx := shadow.PixOffset(drawn.Min.X, y)
+		for x := drawn.Min.X; x < drawn.Max.X; x++ {
+			a := alpha[(y-area.Min.Y)*width+x-area.Min.X]
+			shadow.Pix[idx] = uint8(clampUnit(a)*0xff + 0.5)
+			idx++
+		}
+	}
+	if d.state.clipped {
+		shadow = intersectMasks(shadow, d.state.clip)
+		if shadow == nil {
+			return
+		}
+	}
+	c := premultiply(d.state.shadow.color)
+	compositeShader(d.image, shadow, d.state.compositeMode,
+		func(x, y int) (Color, bool) {
+			return c, true
+		})
+}
+
+// drawShape fills polygons with colors from a function, drawing their shadow
+// first if there is one.
+func (d *imageContext) drawShape(polys []polygon, rule FillRule,
+	shade func(x, y int) (Color, bool)) {
+	if d.state.shadow.visible() {
+		d.drawShadow(polys, rule, shade)
+	}
+	if mask := d.coverageMask(polys, rule); mask != nil {
+		d.composite(mask, shade)
+	}
+}
+
+func (d *imageContext) fillPolygons(polys []polygon, rule FillRule,
+	p Paint) {
+	if c, ok := paintColor(p); ok {
+		src := premultiply(c)
+		d.drawShape(polys, rule, func(x, y int) (Color, bool) {
+			return src, true
+		})
+	} else if g, ok := paintGradient(p); ok {
+		inv, ok := d.state.transform.Invert()
+		if !ok {
+			return
+		}
+		d.drawShape(polys, rule, func(x, y int) (Color, bool) {
+			// Sample the gradient at the center of the pixel.
+			var p point
+			p.X, p.Y = inv.Apply(float64(x)+0.5, float64(y)+0.5)
+			t, ok := g.param(p)
+			return premultiply(g.colorAt(t)), ok
+		})
+	}
+}
+
+// fontMetrics returns the metrics of the built-in font at the size of f.
+func (d *imageContext) fontMetrics(f Font) FontMetrics {
+	return softFontMetrics(f)
+}
+
+// lineTo adds a line to a point in device space. The path must not be empty.
+func (d *imageContext) lineTo(p point) {
+	sub := &d.path[len(d.path)-1]
+	if sub.closed {
+		// Like CoreGraphics, start a new subpath at the start of the closed one.
+		d.path = append(d.path, polyline{points: []point{sub.points[0]}})
+		sub = &d.path[len(d.path)-1]
+	}
+	sub.points = append(sub.points, p)
+}
+
+// maskBounds returns the part of the image which may be drawn in.
+func (d *imageContext) maskBounds() image.Rectangle {
+	if !d.state.clipped {
+		return d.image.Bounds()
+	} else if d.state.clip == nil {
+		return image.Rectangle{}
+	}
+	return d.state.clip.Rect
+}
+
+func (d *imageContext) pathPolygons() []polygon {
+	polys := make([]polygon, 0, len(d.path))
+	for _, line := range d.path {
+		polys = append(polys, polygon(line.points))
+	}
+	return polys
+}
+
+func (d *imageContext) rectPolygon(r Rect) polygon {
+	return polygon{
+		d.toDevice(r.X, r.Y),
+		d.toDevice(r.X+r.Width, r.Y),
+		d.toDevice(r.X+r.Width, r.Y+r.Height),
+		d.toDevice(r.X, r.Y+r.Height),
+	}
+}
+
+// shadowOffset returns the offset of the shadow and the standard deviation
+// of its blur in device space. Like the transform, the shadow settings are
+// relative to the base matrix.
+func (d *imageContext) shadowOffset() (dx, dy, sigma float64) {
+	s := d.state.shadow
+	scale := math.Sqrt(math.Abs(d.base.A*d.base.D - d.base.B*d.base.C))
+	sigma = math.Min(shadowSigma(s.blur)*scale, maxShadowSigma)
+	return s.offsetX * scale, s.offsetY * scale, sigma
+}
+
+// strokePolylines strokes lines whose points are in device space.
+//
+// The thickness and dashes are in user space, so the lines are stroked in user
+// space and the outline is mapped back to device space. When the transform
+// keeps angles, the line style is simply scaled instead.
+func (d *imageContext) strokePolylines(lines []polyline) {
+	m := d.state.transform
+	if (m.A == m.D && m.B == -m.C) || (m.A == -m.D && m.B == m.C) {
+		style := d.state.line.scaled(math.Sqrt(m.A*m.A + m.B*m.B))
+		polys := strokePolylines(lines, style, d.drawBounds(), 1)
+		d.fillPolygons(polys, NonZero, d.state.stroke)
+		return
+	}
+	inv, ok := m.Invert()
+	if !ok {
+		return
+	}
+
+	// The lines are dashed in user space, inside of a rectangle around the
+	// part of it which may show.
+	b := d.drawBounds()
+	bounds := Rect{math.Inf(1), math.Inf(1), 0, 0}
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, c := range []point{{b.X, b.Y}, {b.X + b.Width, b.Y},
+		{b.X, b.Y + b.Height}, {b.X + b.Width, b.Y + b.Height}} {
+		x, y := inv.Apply(c.X, c.Y)
+		bounds.X, bounds.Y = math.Min(bounds.X, x), math.Min(bounds.Y, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	bounds.Width, bounds.Height = maxX-bounds.X, maxY-bounds.Y
+
+	userLines := make([]polyline, len(lines))
+	for i, line := range lines {
+		points := make([]point, len(line.points))
+		for j, p := range line.points {
+			points[j].X, points[j].Y = inv.Apply(p.X, p.Y)
+		}
+		userLines[i] = polyline{points: points, closed: line.closed}
+	}
+	polys := strokePolylines(userLines, d.state.line, bounds,
+		1/d.deviceScale())
+	for _, poly := range polys {
+		for i, p := range poly {
+			poly[i] = d.toDevice(p.X, p.Y)
+		}
+	}
+	d.fillPolygons(polys, NonZero, d.state.stroke)
+}
+
+// textOrigin returns the top-left corner of text which is drawn at a point
+// with the current alignment and baseline.
+func (d *imageContext) textOrigin(text string, x, y float64) (float64,
+	float64) {
+	width, height := d.TextSize(text)
+	ascent := d.fontMetrics(d.state.font).Ascent
+	return textOrigin(x, y, width, height, ascent, d.state.textAlign,
+		d.state.textBaseline)
+}
+
+func (d *imageContext) textSize(text string, f Font) (float64, float64) {
+	return softTextSize(text, f)
+}
+
+func (d *imageContext) toDevice(x, y float64) point {
+	x, y = d.state.transform.Apply(x, y)
+	return point{x, y}
+}
+
+// compositeShader composites colors with an image wherever a coverage mask is
+// set. The color of each pixel comes from a function, premultiplied by alpha.
+// Pixels for which the function returns false are left alone.
+func compositeShader(img *image.RGBA, mask *image.Alpha, mode CompositeMode,
+	shade func(x, y int) (Color, bool)) {
+	r := mask.Rect.Intersect(img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		maskIdx := mask.PixOffset(r.Min.X, y)
+		imgIdx := img.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			coverage := float64(mask.Pix[maskIdx]) / 0xff
+			if coverage != 0 {
+				if c, ok := shade(x, y); ok {
+					blendPixel(img.Pix[imgIdx:imgIdx+4], c, coverage, mode)
+				}
+			}
+			maskIdx++
+			imgIdx += 4
+		}
+	}
+}
+
+// blendPixel composites a premultiplied color with an RGBA pixel. Partial
+// coverage mixes the result with the original pixel, as it does in
+// CoreGraphics.
+func blendPixel(pix []uint8, c Color, coverage float64, mode CompositeMode) {
+	dst := Color{float64(pix[0]) / 0xff, float64(pix[1]) / 0xff,
+		float64(pix[2]) / 0xff, float64(pix[3]) / 0xff}
+	src := Color{clampUnit(c.R), clampUnit(c.G), clampUnit(c.B),
+		clampUnit(c.A)}
+	res := mode.composite(src, dst)
+	mix := func(r, d float64) uint8 {
+		return uint8((d+(r-d)*coverage)*0xff + 0.5)
+	}
+	pix[0] = mix(res.R, dst.R)
+	pix[1] = mix(res.G, dst.G)
+	pix[2] = mix(res.B, dst.B)
+	pix[3] = mix(res.A, dst.A)
+}
+
+// premultiply multiplies the components of a color by its alpha.
+func premultiply(c Color) Color {
+	a := clampUnit(c.A)
+	return Color{clampUnit(c.R) * a, clampUnit(c.G) * a, clampUnit(c.B) * a, a}
+}
+
+func clampUnit(x float64) float64 {
+	return math.Max(0, math.Min(1, x))
+}
@@ -0,0 +1,122 @@
+package gogui
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFillRule(t *testing.T) {
+	donut := func(ctx DrawContext) {
+		ctx.BeginPath()
+		ctx.Arc(20, 20, 15, 0, 6.3, true)
+		ctx.ClosePath()
+		ctx.MoveTo(25, 20)
+		ctx.Arc(20, 20, 5, 0, 6.3, true)
+		ctx.ClosePath()
+	}
+	for _, rule := range []FillRule{NonZero, EvenOdd} {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+		ctx := NewImageContext(img)
+		ctx.SetFillRule(rule)
+		donut(ctx)
+		ctx.FillPath()
+		center, ring := img.RGBAAt(20, 20).A, img.RGBAAt(20, 30).A
+		if ring != 0xff || (rule == EvenOdd) != (center == 0) {
+			t.Error("fill", rule, center, ring)
+		}
+
+		img = image.NewRGBA(image.Rect(0, 0, 40, 40))
+		ctx = NewImageContext(img)
+		ctx.SetFillRule(rule)
+		donut(ctx)
+		ctx.ClipPath()
+		ctx.SetFillRule(NonZero)
+		ctx.FillRect(Rect{0, 0, 40, 40})
+		center, ring = img.RGBAAt(20, 20).A, img.RGBAAt(20, 30).A
+		if ring != 0xff || (rule == EvenOdd) != (center == 0) {
+			t.Error("clip", rule, center, ring)
+		}
+	}
+}
+
+func TestClip(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	ctx := NewImageContext(img)
+	ctx.Save()
+	ctx.ClipRect(Rect{10, 10, 50, 50})
+	ctx.ClipRect(Rect{30, 30, 50, 50})
+	ctx.FillRect(Rect{0, 0, 100, 100})
+	ctx.Restore()
+	if img.RGBAAt(35, 35).A != 0xff || img.RGBAAt(20, 20).A != 0 ||
+		img.RGBAAt(65, 65).A != 0 {
+		t.Error("clipping regions were not intersected")
+	}
+
+	ctx.SetFill(Color{1, 0, 0, 1})
+	ctx.FillRect(Rect{90, 90, 5, 5})
+	if img.RGBAAt(92, 92).R != 0xff {
+		t.Error("Restore did not reset the clip")
+	}
+
+	// Clipping regions which do not overlap leave nothing to draw in.
+	ctx.Save()
+	ctx.ClipRect(Rect{0, 0, 10, 10})
+	ctx.ClipRect(Rect{50, 50, 1, 1})
+	ctx.FillRect(Rect{0, 0, 100, 100})
+	ctx.Restore()
+	if img.RGBAAt(1, 1).R == 0xff || img.RGBAAt(50, 50).R == 0xff {
+		t.Error("drew outside of an empty clip")
+	}
+}
+
+func TestCompositeModes(t *testing.T) {
+	// Each test fills the left half of an image with red and then draws a
+	// blue rectangle over the middle.
+	for _, test := range []struct {
+		mode  CompositeMode
+		alpha float64
+		both  color.RGBA
+		blue  color.RGBA
+	}{
+		{CompositeSourceOver, 1, color.RGBA{0, 0, 0xff, 0xff},
+			color.RGBA{0, 0, 0xff, 0xff}},
+		{CompositeSourceOver, 0.5, color.RGBA{0x80, 0, 0x80, 0xff},
+			color.RGBA{0, 0, 0x80, 0x80}},
+		{CompositeSourceIn, 1, color.RGBA{0, 0, 0xff, 0xff},
+			color.RGBA{}},
+		{CompositeDestinationOut, 1, color.RGBA{}, color.RGBA{}},
+		{CompositeDestinationOver, 1, color.RGBA{0xff, 0, 0, 0xff},
+			color.RGBA{0, 0, 0xff, 0xff}},
+		{CompositeXor, 1, color.RGBA{}, color.RGBA{0, 0, 0xff, 0xff}},
+	} {
+		img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+		ctx := NewImageContext(img)
+		ctx.SetFill(Color{1, 0, 0, 1})
+		ctx.FillRect(Rect{0, 0, 10, 20})
+		ctx.SetCompositeMode(test.mode)
+		ctx.SetGlobalAlpha(test.alpha)
+		ctx.SetFill(Color{0, 0, 1, 1})
+		ctx.FillRect(Rect{5, 0, 10, 20})
+		if p := img.RGBAAt(7, 5); !closeRGBA(p, test.both) {
+			t.Error(test.mode, test.alpha, "over red:", p)
+		}
+		if p := img.RGBAAt(12, 5); !closeRGBA(p, test.blue) {
+			t.Error(test.mode, test.alpha, "alone:", p)
+		}
+		if p := img.RGBAAt(2, 5); p != (color.RGBA{0xff, 0, 0, 0xff}) {
+			t.Error(test.mode, test.alpha, "outside of the shape:", p)
+		}
+	}
+}
+
+// closeRGBA returns true if two colors are within rounding error.
+func closeRGBA(c1, c2 color.RGBA) bool {
+	for _, d := range []int{int(c1.R) - int(c2.R), int(c1.G) - int(c2.G),
+		int(c1.B) - int(c2.B), int(c1.A) - int(c2.A)} {
+		if d < -2 || d > 2 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package gogui
+
+import (
+	"unicode"
+)
+
+// keysymCharCodes maps X keysyms for non-printing keys to the char codes that
+// key down and key up events report for them.
+var keysymCharCodes = map[uint32]int{
+	0xff08: 8,   // BackSpace
+	0xff09: 9,   // Tab
+	0xff0d: 13,  // Return
+	0xff8d: 13,  // KP_Enter
+	0xff13: 19,  // Pause
+	0xffe5: 20,  // Caps_Lock
+	0xff1b: 27,  // Escape
+	0xff55: 33,  // Page_Up
+	0xff56: 34,  // Page_Down
+	0xff57: 35,  // End
+	0xff50: 36,  // Home
+	0xff51: 37,  // Left
+	0xff52: 38,  // Up
+	0xff53: 39,  // Right
+	0xff54: 40,  // Down
+	0xff63: 45,  // Insert
+	0xffff: 46,  // Delete
+	0xffe1: 16,  // Shift_L
+	0xffe2: 16,  // Shift_R
+	0xffe3: 17,  // Control_L
+	0xffe4: 17,  // Control_R
+	0xffe9: 18,  // Alt_L
+	0xffea: 18,  // Alt_R
+	0xffe7: 91,  // Meta_L
+	0xffe8: 91,  // Meta_R
+	0xffeb: 91,  // Super_L
+	0xffec: 91,  // Super_R
+	0xffbe: 112, // F1
+	0xffbf: 113, // F2
+	0xffc0: 114, // F3
+	0xffc1: 115, // F4
+	0xffc2: 116, // F5
+	0xffc3: 117, // F6
+	0xffc4: 118, // F7
+	0xffc5: 119, // F8
+	0xffc6: 120, // F9
+	0xffc7: 121, // F10
+	0xffc8: 122, // F11
+	0xffc9: 123, // F12
+}
+
+// keysymIsModifier returns true if a keysym is for a modifier key like shift.
+// Modifier keys do not generate key press events.
+func keysymIsModifier(sym uint32) bool {
+	return sym >= 0xffe1 && sym <= 0xffee
+}
+
+// keysymRune returns the character typed by a keysym, or -1 if the keysym
+// does not type a character.
+func keysymRune(sym uint32) rune {
+	switch {
+	case sym >= 0x20 && sym <= 0x7e, sym >= 0xa0 && sym <= 0xff:
+		return rune(sym)
+	case sym >= 0x1000100 && sym <= 0x110ffff:
+		return rune(sym - 0x1000000)
+	case sym >= 0xffb0 && sym <= 0xffb9:
+		return rune('0' + sym - 0xffb0)
+	}
+	if code, ok := keysymCharCodes[sym]; ok && code < 32 {
+		return rune(code)
+	}
+	return -1
+}
+
+// keysymCharCode returns the char code which key down and key up events should
+// report for a keysym. Like on OS X, a key which types a character reports
+// the upper case version of that character.
+func keysymCharCode(sym uint32) int {
+	if code, ok := keysymCharCodes[sym]; ok {
+		return code
+	}
+	if r := keysymRune(sym); r >= 0 {
+		return int(unicode.ToUpper(r))
+	}
+	return 0
+}
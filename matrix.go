@@ -0,0 +1,73 @@
+package gogui
+
+import (
+	"math"
+)
+
+// A Matrix is an affine transformation. It maps the point (x, y) to
+// (A*x + C*y + E, B*x + D*y + F), just like a CGAffineTransform or the matrix
+// of an HTML5 canvas.
+type Matrix struct {
+	A float64
+	B float64
+	C float64
+	D float64
+	E float64
+	F float64
+}
+
+// IdentityMatrix returns the matrix which leaves every point where it is.
+func IdentityMatrix() Matrix {
+	return Matrix{A: 1, D: 1}
+}
+
+// TranslationMatrix returns a matrix which moves points by (x, y).
+func TranslationMatrix(x, y float64) Matrix {
+	return Matrix{A: 1, D: 1, E: x, F: y}
+}
+
+// ScaleMatrix returns a matrix which scales points away from the origin.
+func ScaleMatrix(x, y float64) Matrix {
+	return Matrix{A: x, D: y}
+}
+
+// RotationMatrix returns a matrix which rotates points around the origin by an
+// angle in radians. Since the Y axis points down, positive angles rotate
+// clockwise on the screen.
+func RotationMatrix(angle float64) Matrix {
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	return Matrix{A: cos, B: sin, C: -sin, D: cos}
+}
+
+// Apply transforms a point.
+func (m Matrix) Apply(x, y float64) (float64, float64) {
+	return m.A*x + m.C*y + m.E, m.B*x + m.D*y + m.F
+}
+
+// Concat returns a matrix which applies m and then n.
+func (m Matrix) Concat(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.B*n.C,
+		B: m.A*n.B + m.B*n.D,
+		C: m.C*n.A + m.D*n.C,
+		D: m.C*n.B + m.D*n.D,
+		E: m.E*n.A + m.F*n.C + n.E,
+		F: m.E*n.B + m.F*n.D + n.F,
+	}
+}
+
+// Invert returns the inverse of m. If m has no inverse, Invert returns false.
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m.A*m.D - m.B*m.C
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Matrix{}, false
+	}
+	return Matrix{
+		A: m.D / det,
+		B: -m.B / det,
+		C: -m.C / det,
+		D: m.A / det,
+		E: (m.C*m.F - m.D*m.E) / det,
+		F: (m.B*m.E - m.A*m.F) / det,
+	}, true
+}
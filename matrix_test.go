@@ -0,0 +1,42 @@
+package gogui
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMatrixConcat(t *testing.T) {
+	m := TranslationMatrix(3, 4).Concat(ScaleMatrix(2, 5))
+	if x, y := m.Apply(1, 1); x != 8 || y != 25 {
+		t.Error("translate then scale:", x, y)
+	}
+	m = ScaleMatrix(2, 5).Concat(TranslationMatrix(3, 4))
+	if x, y := m.Apply(1, 1); x != 5 || y != 9 {
+		t.Error("scale then translate:", x, y)
+	}
+	m = RotationMatrix(math.Pi / 2).Concat(IdentityMatrix())
+	if x, y := m.Apply(1, 0); math.Abs(x) > 1e-9 || math.Abs(y-1) > 1e-9 {
+		t.Error("rotate:", x, y)
+	}
+}
+
+func TestMatrixInvert(t *testing.T) {
+	m := RotationMatrix(0.3).Concat(TranslationMatrix(3, 4)).
+		Concat(ScaleMatrix(2, 5))
+	inv, ok := m.Invert()
+	if !ok {
+		t.Fatal("matrix was not invertible")
+	}
+	for _, n := range []Matrix{m.Concat(inv), inv.Concat(m)} {
+		if x, y := n.Apply(7, 9); math.Abs(x-7) > 1e-9 ||
+			math.Abs(y-9) > 1e-9 {
+			t.Error(x, y)
+		}
+	}
+	if _, ok := ScaleMatrix(0, 1).Invert(); ok {
+		t.Error("singular matrix was inverted")
+	}
+	if _, ok := ScaleMatrix(math.NaN(), 1).Invert(); ok {
+		t.Error("NaN matrix was inverted")
+	}
+}
@@ -4,11 +4,83 @@ package gogui
 
 /*
 #cgo CFLAGS: -x objective-c
-#cgo LDFLAGS: -framework Cocoa
+#cgo LDFLAGS: -framework Cocoa -framework CoreText
 #import <Cocoa/Cocoa.h>
+#import <CoreText/CoreText.h>
 
 extern void canvasDrawRect(void * window, void * canvas, void * ctx);
 
+static void SetContextDefaults(CGContextRef c) {
+	CGContextSetLineCap(c, kCGLineCapRound);
+	CGContextSetLineJoin(c, kCGLineJoinRound);
+	CGContextSetInterpolationQuality(c, kCGInterpolationLow);
+}
+
+// A FontDescriptor holds the fields of a Font. The names are the family and
+// its fallbacks, separated by newlines.
+typedef struct {
+	char * names;
+	double size;
+	int weight;
+	int italic;
+	int stretch;
+} FontDescriptor;
+
+// FindFont returns the installed font which best matches a descriptor, falling
+// back on Helvetica and then the system font. It frees the names.
+static NSFont * FindFont(FontDescriptor d) {
+	NSString * names = [NSString stringWithUTF8String:d.names];
+	free((void *)d.names);
+	CGFloat size = (CGFloat)d.size;
+
+	NSFontTraitMask traits = 0;
+	if (d.italic) {
+		traits |= NSItalicFontMask;
+	}
+	if (d.stretch < 5) {
+		traits |= NSCondensedFontMask;
+	} else if (d.stretch > 5) {
+		traits |= NSExpandedFontMask;
+	}
+
+	// AppKit weights go from 0 to 15, and 5 is normal.
+	static const int weights[] = {2, 3, 4, 5, 6, 8, 9, 10, 11};
+	int index = (d.weight + 50) / 100 - 1;
+	int weight = weights[index < 0 ? 0 : (index > 8 ? 8 : index)];
+
+	// Traits are dropped one at a time before moving on to the next name, so
+	// that a missing style does not skip a family which is installed.
+	NSFontManager * manager = [NSFontManager sharedFontManager];
+	NSArray * list = [[names componentsSeparatedByString:@"\n"]
+		arrayByAddingObject:@"Helvetica"];
+	for (NSString * name in list) {
+		if ([name length] == 0) {
+			continue;
+		}
+		NSFontTraitMask tries[] = {traits, traits & NSItalicFontMask, 0};
+		for (int i = 0; i < 3; i++) {
+			NSFont * font = [manager fontWithFamily:name traits:tries[i]
+				weight:weight size:size];
+			if (font != nil) {
+				return font;
+			}
+		}
+		NSFont * font = [manager fontWithFamily:name traits:0 weight:5
+			size:size];
+		if (font == nil) {
+			// The name may be the PostScript name of a single font.
+			font = [NSFont fontWithName:name size:size];
+		}
+		if (font != nil) {
+			if (d.italic) {
+				font = [manager convertFont:font toHaveTrait:NSItalicFontMask];
+			}
+			return font;
+		}
+	}
+	return [NSFont systemFontOfSize:size];
+}
+
 @interface Canvas : NSView {
 }
 @end
@@ -18,8 +90,7 @@ extern void canvasDrawRect(void * window, void * canvas, void * ctx);
 - (void)drawRect:(NSRect)ignored {
 	CGContextRef c = (CGContextRef)[[NSGraphicsContext currentContext]
 		graphicsPort];
-	CGContextSetLineCap(c, kCGLineCapRound);
-	CGContextSetLineJoin(c, kCGLineJoinRound);
+	SetContextDefaults(c);
 	canvasDrawRect((void *)self.window, (void *)self, (void *)c);
 }
 
@@ -32,26 +103,240 @@ extern void canvasDrawRect(void * window, void * canvas, void * ctx);
 #define ASSERT_MAIN NSCAssert([NSThread isMainThread], \
 	@"Call must be from main thread.")
 
+// AttributedStringAppend adds text to an NSMutableAttributedString. The colors
+// are the RGBA components of the text and then of the background, which is
+// only used if hasBackground is set.
+void AttributedStringAppend(void * s, char * text, FontDescriptor d,
+	double * colors, int hasBackground, int underline, int strikethrough,
+	double baselineOffset) {
+	NSMutableDictionary * attrs = [NSMutableDictionary dictionary];
+	attrs[NSFontAttributeName] = FindFont(d);
+	attrs[NSForegroundColorAttributeName] = [NSColor
+		colorWithRed:(CGFloat)colors[0] green:(CGFloat)colors[1]
+		blue:(CGFloat)colors[2] alpha:(CGFloat)colors[3]];
+	if (hasBackground) {
+		attrs[NSBackgroundColorAttributeName] = [NSColor
+			colorWithRed:(CGFloat)colors[4] green:(CGFloat)colors[5]
+			blue:(CGFloat)colors[6] alpha:(CGFloat)colors[7]];
+	}
+	if (underline) {
+		attrs[NSUnderlineStyleAttributeName] = @(NSUnderlineStyleSingle);
+	}
+	if (strikethrough) {
+		attrs[NSStrikethroughStyleAttributeName] = @(NSUnderlineStyleSingle);
+	}
+	if (baselineOffset != 0) {
+		attrs[NSBaselineOffsetAttributeName] = @(baselineOffset);
+	}
+
+	NSString * string = [NSString stringWithUTF8String:text];
+	free((void *)text);
+	if (string == nil) {
+		// The text is not valid UTF-8.
+		return;
+	}
+	NSAttributedString * run = [[NSAttributedString alloc]
+		initWithString:string attributes:attrs];
+	[(NSMutableAttributedString *)s appendAttributedString:run];
+	[run release];
+}
+
+NSSize AttributedStringSize(void * s) {
+	return [(NSAttributedString *)s size];
+}
+
 void CanvasNeedsUpdate(void * v) {
 	ASSERT_MAIN;
 	[(NSView *)v setNeedsDisplay:YES];
 }
 
+void ContextArc(void * c, double x, double y, double radius, double start,
+	double end, int clockwise) {
+	// The view is flipped, so an arc which is clockwise on the screen is
+	// counterclockwise to CoreGraphics.
+	CGContextAddArc((CGContextRef)c, (CGFloat)x, (CGFloat)y, (CGFloat)radius,
+		(CGFloat)start, (CGFloat)end, !clockwise);
+}
+
+void ContextArcTo(void * c, double x1, double y1, double x2, double y2,
+	double radius) {
+	if (CGContextIsPathEmpty((CGContextRef)c)) {
+		CGContextMoveToPoint((CGContextRef)c, (CGFloat)x1, (CGFloat)y1);
+	}
+	CGContextAddArcToPoint((CGContextRef)c, (CGFloat)x1, (CGFloat)y1,
+		(CGFloat)x2, (CGFloat)y2, (CGFloat)radius);
+}
+
+// ContextAttributedText draws an NSAttributedString with its top-left corner
+// at a point.
+void ContextAttributedText(void * c, void * s, double x, double y) {
+	CGContextSetTextDrawingMode((CGContextRef)c, kCGTextFill);
+	NSGraphicsContext * oldContext = [NSGraphicsContext currentContext];
+	[NSGraphicsContext setCurrentContext:[NSGraphicsContext
+		graphicsContextWithGraphicsPort:c flipped:YES]];
+	[(NSAttributedString *)s drawAtPoint:NSMakePoint((CGFloat)x, (CGFloat)y)];
+	[NSGraphicsContext setCurrentContext:oldContext];
+}
+
+// ContextBeginLayer starts a transparency layer, so that a shape which is
+// drawn by clipping casts one shadow instead of having it clipped away.
+void ContextBeginLayer(void * c) {
+	CGContextBeginTransparencyLayer((CGContextRef)c, NULL);
+}
+
 void ContextBeginPath(void * c) {
 	CGContextBeginPath((CGContextRef)c);
 }
 
+void ContextClearShadow(void * c) {
+	CGContextSetShadowWithColor((CGContextRef)c, CGSizeZero, 0, NULL);
+}
+
+void ContextClipPath(void * c, int evenOdd) {
+	if (evenOdd) {
+		CGContextEOClip((CGContextRef)c);
+	} else {
+		CGContextClip((CGContextRef)c);
+	}
+}
+
+void ContextClipRect(void * c, double x, double y, double w, double h) {
+	CGContextClipToRect((CGContextRef)c, CGRectMake((CGFloat)x, (CGFloat)y,
+		(CGFloat)w, (CGFloat)h));
+}
+
+CGRect ContextClipBounds(void * c) {
+	return CGContextGetClipBoundingBox((CGContextRef)c);
+}
+
+// ContextClipToShape intersects the clipping region with the current path
+// (shape 0), a rectangle (shape 1) or an ellipse (shape 2). If stroke is set,
+// it clips to the outline which a Stroke call would draw instead. The current
+// path is used up, but it is left alone for rectangles and ellipses.
+// If evenOdd is set, the path is filled with the even-odd rule.
+void ContextClipToShape(void * c, int shape, int stroke, int evenOdd,
+	double x, double y, double w, double h) {
+	CGContextRef ctx = (CGContextRef)c;
+	CGPathRef saved = NULL;
+	if (shape != 0) {
+		if (!CGContextIsPathEmpty(ctx)) {
+			saved = CGContextCopyPath(ctx);
+		}
+		CGContextBeginPath(ctx);
+		CGRect r = CGRectMake((CGFloat)x, (CGFloat)y, (CGFloat)w, (CGFloat)h);
+		if (shape == 1) {
+			CGContextAddRect(ctx, r);
+		} else {
+			CGContextAddEllipseInRect(ctx, r);
+		}
+	}
+	if (stroke) {
+		CGContextReplacePathWithStrokedPath(ctx);
+	}
+	ContextClipPath(ctx, evenOdd);
+	if (saved != NULL) {
+		CGContextAddPath(ctx, saved);
+		CGPathRelease(saved);
+	}
+}
+
 void ContextClosePath(void * c) {
 	CGContextClosePath((CGContextRef)c);
 }
 
+void ContextConcat(void * c, double a, double b, double cc, double d,
+	double tx, double ty) {
+	CGContextConcatCTM((CGContextRef)c, CGAffineTransformMake((CGFloat)a,
+		(CGFloat)b, (CGFloat)cc, (CGFloat)d, (CGFloat)tx, (CGFloat)ty));
+}
+
+void ContextCubicTo(void * c, double c1x, double c1y, double c2x,
+	double c2y, double x, double y) {
+	if (CGContextIsPathEmpty((CGContextRef)c)) {
+		CGContextMoveToPoint((CGContextRef)c, (CGFloat)c1x, (CGFloat)c1y);
+	}
+	CGContextAddCurveToPoint((CGContextRef)c, (CGFloat)c1x, (CGFloat)c1y,
+		(CGFloat)c2x, (CGFloat)c2y, (CGFloat)x, (CGFloat)y);
+}
+
+// ContextDrawGradient fills the clipping region with a gradient. Each stop is
+// five numbers: the offset and then the RGBA color.
+void ContextDrawGradient(void * c, int radial, double x1, double y1, double r1,
+	double x2, double y2, double r2, double * stops, int count) {
+	CGFloat * locations = (CGFloat *)malloc(sizeof(CGFloat) * count);
+	CGFloat * components = (CGFloat *)malloc(sizeof(CGFloat) * count * 4);
+	for (int i = 0; i < count; ++i) {
+		locations[i] = (CGFloat)stops[i * 5];
+		for (int j = 0; j < 4; ++j) {
+			components[i*4 + j] = (CGFloat)stops[i*5 + j + 1];
+		}
+	}
+	CGColorSpaceRef space = CGColorSpaceCreateDeviceRGB();
+	CGGradientRef gradient = CGGradientCreateWithColorComponents(space,
+		components, locations, (size_t)count);
+	CGColorSpaceRelease(space);
+	free(locations);
+	free(components);
+
+	CGGradientDrawingOptions options = kCGGradientDrawsBeforeStartLocation |
+		kCGGradientDrawsAfterEndLocation;
+	if (radial) {
+		CGContextDrawRadialGradient((CGContextRef)c, gradient,
+			CGPointMake((CGFloat)x1, (CGFloat)y1), (CGFloat)r1,
+			CGPointMake((CGFloat)x2, (CGFloat)y2), (CGFloat)r2, options);
+	} else {
+		CGContextDrawLinearGradient((CGContextRef)c, gradient,
+			CGPointMake((CGFloat)x1, (CGFloat)y1),
+			CGPointMake((CGFloat)x2, (CGFloat)y2), options);
+	}
+	CGGradientRelease(gradient);
+}
+
+void ContextDrawImage(void * c, void * image, int cx, int cy, int cw,
+	int ch, double sx, double sy, double sw, double sh, double x, double y,
+	double w, double h) {
+	CGContextRef ctx = (CGContextRef)c;
+	CGImageRef sub = CGImageCreateWithImageInRect((CGImageRef)image,
+		CGRectMake((CGFloat)cx, (CGFloat)cy, (CGFloat)cw, (CGFloat)ch));
+	if (!sub) {
+		return;
+	}
+
+	// The source rectangle may cut pixels of sub in half, so draw all of sub
+	// and clip to the destination. A transparency layer keeps the clip from
+	// cutting off the shadow.
+	double scaleX = w / sw;
+	double scaleY = h / sh;
+	CGContextSaveGState(ctx);
+	CGContextBeginTransparencyLayer(ctx, NULL);
+	CGContextClipToRect(ctx, CGRectMake((CGFloat)x, (CGFloat)y, (CGFloat)w,
+		(CGFloat)h));
+
+	// The view is flipped, so the image has to be flipped back.
+	CGContextTranslateCTM(ctx, (CGFloat)(x - sx*scaleX),
+		(CGFloat)(y + (ch-sy)*scaleY));
+	CGContextScaleCTM(ctx, (CGFloat)scaleX, (CGFloat)-scaleY);
+	CGContextDrawImage(ctx, CGRectMake(0, 0, (CGFloat)cw, (CGFloat)ch), sub);
+	CGContextEndTransparencyLayer(ctx);
+	CGContextRestoreGState(ctx);
+	CGImageRelease(sub);
+}
+
+void ContextEndLayer(void * c) {
+	CGContextEndTransparencyLayer((CGContextRef)c);
+}
+
 void ContextFillEllipse(void * c, double x, double y, double w, double h) {
 	CGContextFillEllipseInRect((CGContextRef)c, CGRectMake((CGFloat)x,
 		(CGFloat)y, (CGFloat)w, (CGFloat)h));
 }
 
-void ContextFillPath(void * c) {
-	CGContextFillPath((CGContextRef)c);
+void ContextFillPath(void * c, int evenOdd) {
+	if (evenOdd) {
+		CGContextEOFillPath((CGContextRef)c);
+	} else {
+		CGContextFillPath((CGContextRef)c);
+	}
 }
 
 void ContextFillRect(void * c, double x, double y, double w, double h) {
@@ -59,6 +344,24 @@ void ContextFillRect(void * c, double x, double y, double w, double h) {
 		(CGFloat)w, (CGFloat)h));
 }
 
+// ContextFontMetrics fills out with the ascent, descent, leading, x-height,
+// cap height, underline position and underline thickness of a font, in the
+// order of the fields of FontMetrics.
+void ContextFontMetrics(FontDescriptor d, double * out) {
+	NSFont * font = FindFont(d);
+	out[0] = (double)[font ascender];
+	out[1] = -(double)[font descender];
+	out[2] = (double)[font leading];
+	out[3] = (double)[font xHeight];
+	out[4] = (double)[font capHeight];
+	out[5] = -(double)[font underlinePosition];
+	out[6] = (double)[font underlineThickness];
+}
+
+CGAffineTransform ContextGetCTM(void * c) {
+	return CGContextGetCTM((CGContextRef)c);
+}
+
 void ContextLineTo(void * c, double x, double y) {
 	CGContextAddLineToPoint((CGContextRef)c, (CGFloat)x, (CGFloat)y);
 }
@@ -67,11 +370,88 @@ void ContextMoveTo(void * c, double x, double y) {
 	CGContextMoveToPoint((CGContextRef)c, (CGFloat)x, (CGFloat)y);
 }
 
+void ContextQuadraticTo(void * c, double cx, double cy, double x, double y) {
+	if (CGContextIsPathEmpty((CGContextRef)c)) {
+		CGContextMoveToPoint((CGContextRef)c, (CGFloat)cx, (CGFloat)cy);
+	}
+	CGContextAddQuadCurveToPoint((CGContextRef)c, (CGFloat)cx, (CGFloat)cy,
+		(CGFloat)x, (CGFloat)y);
+}
+
+void ContextRestore(void * c) {
+	CGContextRestoreGState((CGContextRef)c);
+}
+
+void ContextRotate(void * c, double angle) {
+	CGContextRotateCTM((CGContextRef)c, (CGFloat)angle);
+}
+
+void ContextSave(void * c) {
+	CGContextSaveGState((CGContextRef)c);
+}
+
+void ContextScale(void * c, double x, double y) {
+	CGContextScaleCTM((CGContextRef)c, (CGFloat)x, (CGFloat)y);
+}
+
+void ContextSetAlpha(void * c, double alpha) {
+	CGContextSetAlpha((CGContextRef)c, (CGFloat)alpha);
+}
+
+void ContextSetBlendMode(void * c, int mode) {
+	CGBlendMode modes[] = {kCGBlendModeNormal, kCGBlendModeSourceIn,
+		kCGBlendModeSourceOut, kCGBlendModeSourceAtop,
+		kCGBlendModeDestinationOver, kCGBlendModeDestinationIn,
+		kCGBlendModeDestinationOut, kCGBlendModeDestinationAtop,
+		kCGBlendModeCopy, kCGBlendModeXOR, kCGBlendModePlusLighter,
+		kCGBlendModeMultiply, kCGBlendModeScreen, kCGBlendModeOverlay,
+		kCGBlendModeDarken, kCGBlendModeLighten};
+	CGContextSetBlendMode((CGContextRef)c, modes[mode]);
+}
+
 void ContextSetFill(void * c, double r, double g, double b, double a) {
 	CGContextSetRGBFillColor((CGContextRef)c, (CGFloat)r, (CGFloat)g,
 		(CGFloat)b, (CGFloat)a);
 }
 
+void ContextSetInterpolation(void * c, int quality) {
+	CGInterpolationQuality qualities[] = {kCGInterpolationNone,
+		kCGInterpolationLow, kCGInterpolationHigh};
+	CGContextSetInterpolationQuality((CGContextRef)c, qualities[quality]);
+}
+
+void ContextSetLineCap(void * c, int cap) {
+	CGContextSetLineCap((CGContextRef)c, (CGLineCap)cap);
+}
+
+void ContextSetLineDash(void * c, double phase, double * lengths, int count) {
+	CGFloat cgLengths[count > 0 ? count : 1];
+	for (int i = 0; i < count; ++i) {
+		cgLengths[i] = (CGFloat)lengths[i];
+	}
+	CGContextSetLineDash((CGContextRef)c, (CGFloat)phase, cgLengths,
+		(size_t)count);
+}
+
+void ContextSetLineJoin(void * c, int join) {
+	CGContextSetLineJoin((CGContextRef)c, (CGLineJoin)join);
+}
+
+void ContextSetMiterLimit(void * c, double limit) {
+	CGContextSetMiterLimit((CGContextRef)c, (CGFloat)limit);
+}
+
+void ContextSetShadow(void * c, double dx, double dy, double blur, double r,
+	double g, double b, double a) {
+	CGColorSpaceRef space = CGColorSpaceCreateDeviceRGB();
+	CGFloat components[] = {(CGFloat)r, (CGFloat)g, (CGFloat)b, (CGFloat)a};
+	CGColorRef color = CGColorCreate(space, components);
+	CGColorSpaceRelease(space);
+	CGContextSetShadowWithColor((CGContextRef)c, CGSizeMake((CGFloat)dx,
+		(CGFloat)dy), (CGFloat)blur, color);
+	CGColorRelease(color);
+}
+
 void ContextSetStroke(void * c, double r, double g, double b, double a) {
 	CGContextSetRGBStrokeColor((CGContextRef)c, (CGFloat)r, (CGFloat)g,
 		(CGFloat)b, (CGFloat)a);
@@ -81,6 +461,16 @@ void ContextSetThickness(void * c, double thickness) {
 	CGContextSetLineWidth((CGContextRef)c, (CGFloat)thickness);
 }
 
+void ContextSetTransform(void * c, CGAffineTransform base, double a, double b,
+	double cc, double d, double tx, double ty) {
+	// There is no call to replace the CTM, so undo the current one first.
+	CGContextRef ctx = (CGContextRef)c;
+	CGContextConcatCTM(ctx, CGAffineTransformInvert(CGContextGetCTM(ctx)));
+	CGContextConcatCTM(ctx, base);
+	CGContextConcatCTM(ctx, CGAffineTransformMake((CGFloat)a, (CGFloat)b,
+		(CGFloat)cc, (CGFloat)d, (CGFloat)tx, (CGFloat)ty));
+}
+
 void ContextStrokeEllipse(void * c, double x, double y, double w, double h) {
 	CGContextStrokeEllipseInRect((CGContextRef)c, CGRectMake((CGFloat)x,
 		(CGFloat)y, (CGFloat)w, (CGFloat)h));
@@ -95,12 +485,10 @@ void ContextStrokeRect(void * c, double x, double y, double w, double h) {
 		(CGFloat)w, (CGFloat)h));
 }
 
-void ContextText(char * text, double x, double y, double fontSize,
-	char * fontName, double r, double g, double b, double a) {
+void ContextText(void * c, char * text, double x, double y, FontDescriptor d,
+	double r, double g, double b, double a, int mode) {
 	// Generate the font
-	NSString * name = [NSString stringWithUTF8String:fontName];
-	free((void *)fontName);
-	NSFont * font = [NSFont fontWithName:name size:(CGFloat)fontSize];
+	NSFont * font = FindFont(d);
 	
 	// Generate the color
 	NSColor * color = [NSColor colorWithRed:(CGFloat)r green:(CGFloat)g
@@ -111,15 +499,24 @@ void ContextText(char * text, double x, double y, double fontSize,
 		NSForegroundColorAttributeName: color};
 	NSString * string = [NSString stringWithUTF8String:text];
 	free((void *)text);
+
+	// Clipping to the text lets the caller fill it with a gradient. Stroking
+	// uses the line style of the context.
+	CGTextDrawingMode modes[] = {kCGTextFill, kCGTextClip, kCGTextStroke};
+	CGContextSetTextDrawingMode((CGContextRef)c, modes[mode]);
+
+	// Draw into the context itself so that the text follows its CTM.
+	NSGraphicsContext * oldContext = [NSGraphicsContext currentContext];
+	[NSGraphicsContext setCurrentContext:[NSGraphicsContext
+		graphicsContextWithGraphicsPort:c flipped:YES]];
 	[string drawAtPoint:NSMakePoint((CGFloat)x, (CGFloat)y)
 		withAttributes:dict];
+	[NSGraphicsContext setCurrentContext:oldContext];
 }
 
-NSSize ContextTextSize(char * text, char * fontName, double size) {
+NSSize ContextTextSize(char * text, FontDescriptor d) {
 	// Generate the font
-	NSString * name = [NSString stringWithUTF8String:fontName];
-	free((void *)fontName);
-	NSFont * font = [NSFont fontWithName:name size:(CGFloat)size];
+	NSFont * font = FindFont(d);
 	
 	// Generate the attributes and draw the string
 	NSDictionary * dict = @{NSFontAttributeName: font};
@@ -131,6 +528,81 @@ NSSize ContextTextSize(char * text, char * fontName, double size) {
 	return [string sizeWithAttributes:dict];
 }
 
+// ContextTextOffsets lays text out once and finds the x offset of each UTF-16
+// index in it. It frees the text.
+void ContextTextOffsets(char * text, FontDescriptor d, long * indices,
+	double * offsets, int count) {
+	NSFont * font = FindFont(d);
+	NSString * string = [NSString stringWithUTF8String:text];
+	free((void *)text);
+	if (string == nil) {
+		// The text is not valid UTF-8.
+		for (int i = 0; i < count; i++) {
+			offsets[i] = 0;
+		}
+		return;
+	}
+	NSAttributedString * attributed = [[NSAttributedString alloc]
+		initWithString:string attributes:@{NSFontAttributeName: font}];
+	CTLineRef line = CTLineCreateWithAttributedString(
+		(CFAttributedStringRef)attributed);
+	for (int i = 0; i < count; i++) {
+		offsets[i] = (double)CTLineGetOffsetForStringIndex(line,
+			(CFIndex)indices[i], NULL);
+	}
+	CFRelease(line);
+	[attributed release];
+}
+
+void ContextTranslate(void * c, double x, double y) {
+	CGContextTranslateCTM((CGContextRef)c, (CGFloat)x, (CGFloat)y);
+}
+
+// CopyFontNames returns the PostScript names of the installed fonts, or the
+// names of the font families, separated by newlines. The caller frees the
+// result.
+char * CopyFontNames(int families) {
+	NSFontManager * manager = [NSFontManager sharedFontManager];
+	NSArray * names = families ? [manager availableFontFamilies] :
+		[manager availableFonts];
+	return strdup([[names componentsJoinedByString:@"\n"] UTF8String]);
+}
+
+void * CreateAttributedString() {
+	return [[NSMutableAttributedString alloc] init];
+}
+
+void * CreateImage(void * pixels, int width, int height, int stride) {
+	// The pixels are premultiplied RGBA, like those of an image.RGBA.
+	CFDataRef data = CFDataCreate(NULL, (const UInt8 *)pixels,
+		(CFIndex)stride*(height-1) + width*4);
+	CGDataProviderRef provider = CGDataProviderCreateWithCFData(data);
+	CGColorSpaceRef space = CGColorSpaceCreateDeviceRGB();
+	CGImageRef image = CGImageCreate(width, height, 8, 32, stride, space,
+		kCGImageAlphaPremultipliedLast, provider, NULL, true,
+		kCGRenderingIntentDefault);
+	CGColorSpaceRelease(space);
+	CGDataProviderRelease(provider);
+	CFRelease(data);
+	return (void *)image;
+}
+
+void * CreateBitmapContext(int width, int height, double scale) {
+	CGColorSpaceRef space = CGColorSpaceCreateDeviceRGB();
+	CGContextRef c = CGBitmapContextCreate(NULL, width, height, 8, width*4,
+		space, kCGImageAlphaPremultipliedLast);
+	CGColorSpaceRelease(space);
+	if (!c) {
+		return NULL;
+	}
+
+	// Flip the context like a Canvas view.
+	CGContextTranslateCTM(c, 0, (CGFloat)height);
+	CGContextScaleCTM(c, (CGFloat)scale, (CGFloat)-scale);
+	SetContextDefaults(c);
+	return (void *)c;
+}
+
 void * CreateCanvas(double x, double y, double w, double h) {
 	ASSERT_MAIN;
 	NSRect r = NSMakeRect((CGFloat)x, (CGFloat)y, (CGFloat)w,
@@ -138,6 +610,10 @@ void * CreateCanvas(double x, double y, double w, double h) {
 	return (void *)[[Canvas alloc] initWithFrame:r];
 }
 
+void DestroyAttributedString(void * s) {
+	[(NSMutableAttributedString *)s release];
+}
+
 void DestroyCanvas(void * c) {
 	ASSERT_MAIN;
 	NSView * v = (NSView *)c;
@@ -145,6 +621,22 @@ void DestroyCanvas(void * c) {
 	[v release];
 }
 
+void DestroyBitmapContext(void * c) {
+	CGContextRelease((CGContextRef)c);
+}
+
+void DestroyImage(void * image) {
+	CGImageRelease((CGImageRef)image);
+}
+
+void * GetBitmapData(void * c) {
+	return CGBitmapContextGetData((CGContextRef)c);
+}
+
+int GetBitmapStride(void * c) {
+	return (int)CGBitmapContextGetBytesPerRow((CGContextRef)c);
+}
+
 NSRect GetViewFrame(void * v) {
 	ASSERT_MAIN;
 	return [(NSView *)v frame];
@@ -161,7 +653,12 @@ void SetViewFrame(void * v, double x, double y, double w, double h) {
 import "C"
 
 import (
+	"errors"
+	"image"
+	"math"
 	"runtime"
+	"strings"
+	"unicode/utf16"
 	"unsafe"
 )
 
@@ -171,7 +668,15 @@ type canvas struct {
 	parent  parentRemover
 }
 
-func NewCanvas(r Rect) (Canvas, error) {
+func (cocoaDriver) FontFamilies() ([]string, error) {
+	return cocoaFontNames(1), nil
+}
+
+func (cocoaDriver) Fonts() ([]string, error) {
+	return cocoaFontNames(0), nil
+}
+
+func (cocoaDriver) NewCanvas(r Rect) (Canvas, error) {
 	ptr := C.CreateCanvas(C.double(r.X), C.double(r.Y), C.double(r.Width),
 		C.double(r.Height))
 	res := &canvas{pointer: ptr}
@@ -214,6 +719,39 @@ func (c *canvas) SetFrame(r Rect) {
 		C.double(r.Width), C.double(r.Height))
 }
 
+func (c *canvas) Snapshot() (image.Image, error) {
+	return c.SnapshotScale(1)
+}
+
+func (c *canvas) SnapshotScale(scale float64) (image.Image, error) {
+	bounds, err := snapshotBounds(c.Frame(), scale)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(bounds)
+	if c.handler == nil || bounds.Empty() {
+		return img, nil
+	}
+	width, height := bounds.Dx(), bounds.Dy()
+	ctx := C.CreateBitmapContext(C.int(width), C.int(height), C.double(scale))
+	if ctx == nil {
+		return nil, errors.New("cannot create bitmap context")
+	}
+	defer C.DestroyBitmapContext(ctx)
+	d := newDrawContext(ctx)
+	d.shadowScale = scale
+	c.handler(d)
+	d.pointer = nil
+
+	// Rows of the bitmap may be padded.
+	stride := int(C.GetBitmapStride(ctx))
+	data := C.GoBytes(C.GetBitmapData(ctx), C.int(stride*height))
+	for y := 0; y < height; y++ {
+		copy(img.Pix[y*img.Stride:], data[y*stride:y*stride+width*4])
+	}
+	return img, nil
+}
+
 func (c *canvas) setParent(p parentRemover) {
 	c.parent = p
 }
@@ -222,51 +760,244 @@ func (c *canvas) viewPointer() unsafe.Pointer {
 	return c.pointer
 }
 
+// cocoaFontNames returns the installed fonts, or font families if families is
+// set.
+func cocoaFontNames(families C.int) []string {
+	names := C.CopyFontNames(families)
+	defer C.free(unsafe.Pointer(names))
+	return strings.Split(C.GoString(names), "\n")
+}
+
+// fontDescriptor converts a font for the C functions, which free the names.
+func fontDescriptor(f Font) C.FontDescriptor {
+	var italic C.int
+	if f.Italic {
+		italic = 1
+	}
+	return C.FontDescriptor{
+		names:   C.CString(strings.Join(f.families(), "\n")),
+		size:    C.double(f.Size),
+		weight:  C.int(f.weight()),
+		italic:  italic,
+		stretch: C.int(f.stretch()),
+	}
+}
+
 func finalizeCanvas(c *canvas) {
-	RunOnMain(func() {
+	cocoaDriver{}.RunOnMain(func() {
 		c.Remove()
 		C.DestroyCanvas(c.pointer)
 	})
 }
 
+// createCGImage copies the pixels of an image into a CGImage.
+func createCGImage(img *image.RGBA) unsafe.Pointer {
+	b := img.Bounds()
+	return C.CreateImage(unsafe.Pointer(&img.Pix[0]), C.int(b.Dx()),
+		C.int(b.Dy()), C.int(img.Stride))
+}
+
+func finalizeImage(i *Image) {
+	C.DestroyImage(i.native.(unsafe.Pointer))
+}
+
 type drawContext struct {
 	pointer unsafe.Pointer
-	fontSize  float64
-	fontName  string
-	fillColor Color
+	base    C.CGAffineTransform
+
+	// shadowScale converts shadow settings to the base space of the context,
+	// which CoreGraphics measures shadows in. It is in points for views but
+	// in pixels for bitmaps.
+	shadowScale float64
+
+	// CoreGraphics does not know about fonts or gradients, so Save and
+	// Restore keep track of them here.
+	drawContextState
+	saved []drawContextState
 }
 
+type drawContextState struct {
+	font         Font
+	fill         Paint
+	stroke       Paint
+	fillRule     FillRule
+	textAlign    TextAlign
+	textBaseline TextBaseline
+}
+
+// Drawing modes for ContextText.
+const (
+	textModeFill   = 0
+	textModeClip   = 1
+	textModeStroke = 2
+)
+
+// Shapes for ContextClipToShape.
+const (
+	drawShapePath    = 0
+	drawShapeRect    = 1
+	drawShapeEllipse = 2
+)
+
 func newDrawContext(p unsafe.Pointer) *drawContext {
-	return &drawContext{p, 18, "Helvetica", Color{0, 0, 0, 1}}
+	black := Color{0, 0, 0, 1}
+	return &drawContext{
+		pointer:     p,
+		base:        C.ContextGetCTM(p),
+		shadowScale: 1,
+		drawContextState: drawContextState{
+			font:   Font{Family: "Helvetica", Size: 18},
+			fill:   black,
+			stroke: black,
+		},
+	}
+}
+
+func (d *drawContext) Arc(x, y, radius, startAngle, endAngle float64,
+	clockwise bool) {
+	var cw C.int
+	if clockwise {
+		cw = 1
+	}
+	// Normalize the angles so that CoreGraphics agrees with the other
+	// backends about arcs of 2*Pi or more.
+	endAngle = startAngle + arcSweep(startAngle, endAngle, clockwise)
+	C.ContextArc(d.pointer, C.double(x), C.double(y),
+		C.double(math.Max(0, radius)), C.double(startAngle), C.double(endAngle),
+		cw)
+}
+
+func (d *drawContext) ArcTo(x1, y1, x2, y2, radius float64) {
+	C.ContextArcTo(d.pointer, C.double(x1), C.double(y1), C.double(x2),
+		C.double(y2), C.double(math.Max(0, radius)))
+}
+
+func (d *drawContext) AttributedTextSize(s *AttributedString) (float64,
+	float64) {
+	if a := d.createAttributedString(s); a != nil {
+		defer C.DestroyAttributedString(a)
+		size := C.AttributedStringSize(a)
+		return float64(size.width), float64(size.height)
+	}
+	width, height, _ := attributedTextSize(d, s)
+	return width, height
 }
 
 func (d *drawContext) BeginPath() {
 	C.ContextBeginPath(d.pointer)
 }
 
+func (d *drawContext) ClearShadow() {
+	C.ContextClearShadow(d.pointer)
+}
+
+func (d *drawContext) ClipPath() {
+	C.ContextClipPath(d.pointer, d.evenOdd())
+}
+
+func (d *drawContext) ClipRect(r Rect) {
+	C.ContextClipRect(d.pointer, C.double(r.X), C.double(r.Y),
+		C.double(r.Width), C.double(r.Height))
+}
+
 func (d *drawContext) ClosePath() {
 	C.ContextClosePath(d.pointer)
 }
 
+func (d *drawContext) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	C.ContextCubicTo(d.pointer, C.double(c1x), C.double(c1y), C.double(c2x),
+		C.double(c2y), C.double(x), C.double(y))
+}
+
+func (d *drawContext) DrawImage(img image.Image, dst Rect) {
+	d.DrawSubImage(img, imageBoundsRect(img), dst)
+}
+
+func (d *drawContext) DrawSubImage(img image.Image, src Rect, dst Rect) {
+	pixels := imageSourceRect(img, src)
+	if pixels.Empty() || dst.Width == 0 || dst.Height == 0 {
+		return
+	}
+	var cgImage unsafe.Pointer
+	var origin image.Point
+	if i, ok := img.(*Image); ok {
+		// An Image keeps a CGImage with all of its pixels.
+		cgImage = i.nativeImage(func() interface{} {
+			runtime.SetFinalizer(i, finalizeImage)
+			return createCGImage(i.rgba)
+		}).(unsafe.Pointer)
+		origin = i.rgba.Rect.Min
+	} else {
+		rgba := imageRGBA(img).SubImage(pixels).(*image.RGBA)
+		cgImage = createCGImage(rgba)
+		defer C.DestroyImage(cgImage)
+		origin = pixels.Min
+	}
+	crop := pixels.Sub(origin)
+	C.ContextDrawImage(d.pointer, cgImage, C.int(crop.Min.X),
+		C.int(crop.Min.Y), C.int(crop.Dx()), C.int(crop.Dy()),
+		C.double(src.X-float64(pixels.Min.X)),
+		C.double(src.Y-float64(pixels.Min.Y)), C.double(src.Width),
+		C.double(src.Height), C.double(dst.X), C.double(dst.Y),
+		C.double(dst.Width), C.double(dst.Height))
+}
+
+func (d *drawContext) DrawTextLayout(t *TextLayout, x, y float64) {
+	drawTextLayout(d, t, x, y)
+}
+
+func (d *drawContext) FillAttributedText(s *AttributedString, x, y float64) {
+	a := d.createAttributedString(s)
+	if a == nil {
+		drawAttributedText(d, s, x, y, d.textAlign, d.textBaseline)
+		return
+	}
+	defer C.DestroyAttributedString(a)
+	size := C.AttributedStringSize(a)
+	_, _, ascent := attributedTextSize(d, s)
+	x, y = textOrigin(x, y, float64(size.width), float64(size.height), ascent,
+		d.textAlign, d.textBaseline)
+	C.ContextAttributedText(d.pointer, a, C.double(x), C.double(y))
+}
+
 func (d *drawContext) FillEllipse(r Rect) {
+	if g, ok := paintGradient(d.fill); ok {
+		d.drawGradient(g, drawShapeEllipse, false, r)
+		return
+	}
 	C.ContextFillEllipse(d.pointer, C.double(r.X), C.double(r.Y),
 		C.double(r.Width), C.double(r.Height))
 }
 
 func (d *drawContext) FillPath() {
-	C.ContextFillPath(d.pointer)
+	if g, ok := paintGradient(d.fill); ok {
+		d.drawGradient(g, drawShapePath, false, Rect{})
+		return
+	}
+	C.ContextFillPath(d.pointer, d.evenOdd())
+}
+
+func (d *drawContext) FillPathObject(p *Path) {
+	d.BeginPath()
+	p.addTo(d)
+	d.FillPath()
 }
 
 func (d *drawContext) FillRect(r Rect) {
+	if g, ok := paintGradient(d.fill); ok {
+		d.drawGradient(g, drawShapeRect, false, r)
+		return
+	}
 	C.ContextFillRect(d.pointer, C.double(r.X), C.double(r.Y),
 		C.double(r.Width), C.double(r.Height))
 }
 
 func (d *drawContext) FillText(text string, x, y float64) {
-	c := d.fillColor
-	C.ContextText(C.CString(text), C.double(x), C.double(y),
-		C.double(d.fontSize), C.CString(d.fontName), C.double(c.R),
-		C.double(c.G), C.double(c.B), C.double(c.A))
+	d.drawText(text, x, y, d.fill, false)
+}
+
+func (d *drawContext) FontMetrics() FontMetrics {
+	return d.fontMetrics(d.font)
 }
 
 func (d *drawContext) LineTo(x, y float64) {
@@ -277,42 +1008,395 @@ func (d *drawContext) MoveTo(x, y float64) {
 	C.ContextMoveTo(d.pointer, C.double(x), C.double(y))
 }
 
+func (d *drawContext) QuadraticTo(cx, cy, x, y float64) {
+	C.ContextQuadraticTo(d.pointer, C.double(cx), C.double(cy), C.double(x),
+		C.double(y))
+}
+
+func (d *drawContext) Restore() {
+	if len(d.saved) == 0 {
+		return
+	}
+	d.drawContextState = d.saved[len(d.saved)-1]
+	d.saved = d.saved[:len(d.saved)-1]
+	C.ContextRestore(d.pointer)
+}
+
+func (d *drawContext) Rotate(angle float64) {
+	C.ContextRotate(d.pointer, C.double(angle))
+}
+
+func (d *drawContext) Save() {
+	d.saved = append(d.saved, d.drawContextState)
+	C.ContextSave(d.pointer)
+}
+
+func (d *drawContext) Scale(x, y float64) {
+	C.ContextScale(d.pointer, C.double(x), C.double(y))
+}
+
+func (d *drawContext) SetCompositeMode(m CompositeMode) {
+	if m >= CompositeSourceOver && m <= CompositeLighten {
+		C.ContextSetBlendMode(d.pointer, C.int(m))
+	}
+}
+
 func (d *drawContext) SetFill(c Color) {
 	C.ContextSetFill(d.pointer, C.double(c.R), C.double(c.G), C.double(c.B),
 		C.double(c.A))
-	d.fillColor = c
+	d.fill = c
+}
+
+func (d *drawContext) SetFillPaint(p Paint) {
+	if c, ok := paintColor(p); ok {
+		d.SetFill(c)
+	} else {
+		d.fill = p
+	}
+}
+
+func (d *drawContext) SetFillRule(rule FillRule) {
+	d.fillRule = rule
 }
 
 func (d *drawContext) SetFont(size float64, name string) {
-	d.fontSize = size
-	d.fontName = name
+	d.font = Font{Family: name, Size: size}
+}
+
+func (d *drawContext) SetFontDescriptor(f Font) {
+	d.font = f
+}
+
+func (d *drawContext) SetGlobalAlpha(alpha float64) {
+	if alpha >= 0 && alpha <= 1 {
+		C.ContextSetAlpha(d.pointer, C.double(alpha))
+	}
+}
+
+func (d *drawContext) SetInterpolation(q Interpolation) {
+	if q < InterpolationNearest || q > InterpolationHigh {
+		q = InterpolationBilinear
+	}
+	C.ContextSetInterpolation(d.pointer, C.int(q))
+}
+
+func (d *drawContext) SetLineCap(c LineCap) {
+	if c >= LineCapButt && c <= LineCapSquare {
+		C.ContextSetLineCap(d.pointer, C.int(c))
+	}
+}
+
+func (d *drawContext) SetLineDash(pattern []float64, phase float64) {
+	dash, ok := normalizeLineDash(pattern)
+	if !ok {
+		return
+	}
+	lengths := make([]C.double, len(dash)+1)
+	for i, x := range dash {
+		lengths[i] = C.double(x)
+	}
+	C.ContextSetLineDash(d.pointer, C.double(phase), &lengths[0],
+		C.int(len(dash)))
+}
+
+func (d *drawContext) SetLineJoin(j LineJoin) {
+	if j >= LineJoinMiter && j <= LineJoinBevel {
+		C.ContextSetLineJoin(d.pointer, C.int(j))
+	}
+}
+
+func (d *drawContext) SetMiterLimit(limit float64) {
+	if limit > 0 {
+		C.ContextSetMiterLimit(d.pointer, C.double(limit))
+	}
+}
+
+func (d *drawContext) SetShadow(offsetX, offsetY, blur float64, c Color) {
+	if !validShadow(offsetX, offsetY, blur) {
+		return
+	}
+
+	// The Y axis of base space points up.
+	s := d.shadowScale
+	C.ContextSetShadow(d.pointer, C.double(offsetX*s), C.double(-offsetY*s),
+		C.double(blur*s), C.double(c.R), C.double(c.G), C.double(c.B),
+		C.double(c.A))
 }
 
 func (d *drawContext) SetStroke(c Color) {
 	C.ContextSetStroke(d.pointer, C.double(c.R), C.double(c.G), C.double(c.B),
 		C.double(c.A))
+	d.stroke = c
+}
+
+func (d *drawContext) SetStrokePaint(p Paint) {
+	if c, ok := paintColor(p); ok {
+		d.SetStroke(c)
+	} else {
+		d.stroke = p
+	}
+}
+
+func (d *drawContext) SetTextAlign(a TextAlign) {
+	d.textAlign = a
+}
+
+func (d *drawContext) SetTextBaseline(b TextBaseline) {
+	d.textBaseline = b
 }
 
 func (d *drawContext) SetThickness(thickness float64) {
 	C.ContextSetThickness(d.pointer, C.double(thickness))
 }
 
+func (d *drawContext) SetTransform(m Matrix) {
+	C.ContextSetTransform(d.pointer, d.base, C.double(m.A), C.double(m.B),
+		C.double(m.C), C.double(m.D), C.double(m.E), C.double(m.F))
+}
+
 func (d *drawContext) StrokeEllipse(r Rect) {
+	if g, ok := paintGradient(d.stroke); ok {
+		d.drawGradient(g, drawShapeEllipse, true, r)
+		return
+	}
 	C.ContextStrokeEllipse(d.pointer, C.double(r.X), C.double(r.Y),
 		C.double(r.Width), C.double(r.Height))
 }
 
 func (d *drawContext) StrokePath() {
+	if g, ok := paintGradient(d.stroke); ok {
+		d.drawGradient(g, drawShapePath, true, Rect{})
+		return
+	}
 	C.ContextStrokePath(d.pointer)
 }
 
+func (d *drawContext) StrokePathObject(p *Path) {
+	d.BeginPath()
+	p.addTo(d)
+	d.StrokePath()
+}
+
 func (d *drawContext) StrokeRect(r Rect) {
+	if g, ok := paintGradient(d.stroke); ok {
+		d.drawGradient(g, drawShapeRect, true, r)
+		return
+	}
 	C.ContextStrokeRect(d.pointer, C.double(r.X), C.double(r.Y),
 		C.double(r.Width), C.double(r.Height))
 }
 
+func (d *drawContext) StrokeText(text string, x, y float64) {
+	d.drawText(text, x, y, d.stroke, true)
+}
+
+func (d *drawContext) TextPositions(text string) *TextPositions {
+	return textPositions(layoutMeasurer(d), d.font, text)
+}
+
 func (d *drawContext) TextSize(text string) (float64, float64) {
-	var cText = C.CString(text)
-	s := C.ContextTextSize(cText, C.CString(d.fontName), C.double(d.fontSize))
+	return d.textSize(text, d.font)
+}
+
+func (d *drawContext) Transform(m Matrix) {
+	C.ContextConcat(d.pointer, C.double(m.A), C.double(m.B), C.double(m.C),
+		C.double(m.D), C.double(m.E), C.double(m.F))
+}
+
+func (d *drawContext) Translate(x, y float64) {
+	C.ContextTranslate(d.pointer, C.double(x), C.double(y))
+}
+
+// createAttributedString converts an AttributedString to an
+// NSMutableAttributedString, which the caller destroys. It returns nil if the
+// string is empty or if any of its paints are gradients, since Cocoa can only
+// draw attributed text with colors.
+func (d *drawContext) createAttributedString(
+	s *AttributedString) unsafe.Pointer {
+	runs := s.Runs()
+	if len(runs) == 0 {
+		return nil
+	}
+	colors := make([][8]C.double, len(runs))
+	for i, r := range runs {
+		p := r.Style.Color
+		if p == nil {
+			p = d.fill
+		}
+		c, ok := paintColor(p)
+		if !ok {
+			return nil
+		}
+		colors[i][0], colors[i][1] = C.double(c.R), C.double(c.G)
+		colors[i][2], colors[i][3] = C.double(c.B), C.double(c.A)
+		if r.Style.Background != nil {
+			c, ok = paintColor(r.Style.Background)
+			if !ok {
+				return nil
+			}
+			colors[i][4], colors[i][5] = C.double(c.R), C.double(c.G)
+			colors[i][6], colors[i][7] = C.double(c.B), C.double(c.A)
+		}
+	}
+
+	res := C.CreateAttributedString()
+	text := s.Text()
+	for i, r := range runs {
+		var background, underline, strikethrough C.int
+		if r.Style.Background != nil {
+			background = 1
+		}
+		if r.Style.Underline {
+			underline = 1
+		}
+		if r.Style.Strikethrough {
+			strikethrough = 1
+		}
+		C.AttributedStringAppend(res, C.CString(text[r.Start:r.End]),
+			fontDescriptor(r.Style.Font), &colors[i][0], background, underline,
+			strikethrough, C.double(r.Style.BaselineOffset))
+	}
+	return res
+}
+
+// drawGradient fills or strokes a shape with a gradient by clipping to the
+// shape and then filling the clipping region.
+func (d *drawContext) drawGradient(g *gradient, shape int, stroke bool,
+	r Rect) {
+	var cStroke, evenOdd C.int
+	if stroke {
+		cStroke = 1
+	} else if shape == drawShapePath {
+		evenOdd = d.evenOdd()
+	}
+	C.ContextSave(d.pointer)
+	C.ContextBeginLayer(d.pointer)
+	C.ContextClipToShape(d.pointer, C.int(shape), cStroke, evenOdd,
+		C.double(r.X), C.double(r.Y), C.double(r.Width), C.double(r.Height))
+	d.fillClip(g)
+	C.ContextEndLayer(d.pointer)
+	C.ContextRestore(d.pointer)
+}
+
+// drawText fills or strokes text with a paint.
+func (d *drawContext) drawText(text string, x, y float64, p Paint,
+	stroke bool) {
+	x, y = d.textOrigin(text, x, y)
+	var mode C.int = textModeFill
+	if stroke {
+		mode = textModeStroke
+	}
+	if c, ok := paintColor(p); ok {
+		C.ContextText(d.pointer, C.CString(text), C.double(x), C.double(y),
+			fontDescriptor(d.font), C.double(c.R), C.double(c.G), C.double(c.B),
+			C.double(c.A), mode)
+	} else if g, ok := paintGradient(p); ok {
+		C.ContextSave(d.pointer)
+		C.ContextBeginLayer(d.pointer)
+		if stroke {
+			// Text cannot be clipped to its stroke, so the gradient is only
+			// kept where the stroke was drawn.
+			C.ContextText(d.pointer, C.CString(text), C.double(x), C.double(y),
+				fontDescriptor(d.font), 0, 0, 0, 1, mode)
+			C.ContextSetBlendMode(d.pointer, C.int(CompositeSourceIn))
+		} else {
+			C.ContextText(d.pointer, C.CString(text), C.double(x), C.double(y),
+				fontDescriptor(d.font), 0, 0, 0, 1, textModeClip)
+		}
+		d.fillClip(g)
+		C.ContextEndLayer(d.pointer)
+		C.ContextRestore(d.pointer)
+	}
+}
+
+// evenOdd returns 1 if paths are filled with the even-odd rule.
+func (d *drawContext) evenOdd() C.int {
+	if d.fillRule == EvenOdd {
+		return 1
+	}
+	return 0
+}
+
+// fillClip fills the clipping region with a gradient.
+func (d *drawContext) fillClip(g *gradient) {
+	b := C.ContextClipBounds(d.pointer)
+	x, y := float64(b.origin.x), float64(b.origin.y)
+	w, h := float64(b.size.width), float64(b.size.height)
+	g = g.padded([]point{{x, y}, {x + w, y}, {x, y + h}, {x + w, y + h}})
+	if len(g.stops) == 0 {
+		return
+	}
+	stops := make([]C.double, 0, len(g.stops)*5)
+	for _, s := range g.stops {
+		stops = append(stops, C.double(s.Offset), C.double(s.Color.R),
+			C.double(s.Color.G), C.double(s.Color.B), C.double(s.Color.A))
+	}
+	var radial C.int
+	if g.radial {
+		radial = 1
+	}
+	C.ContextDrawGradient(d.pointer, radial, C.double(g.start.X),
+		C.double(g.start.Y), C.double(g.r1), C.double(g.end.X),
+		C.double(g.end.Y), C.double(g.r2), &stops[0], C.int(len(stops)/5))
+}
+
+func (d *drawContext) fontMetrics(f Font) FontMetrics {
+	var m [7]C.double
+	C.ContextFontMetrics(fontDescriptor(f), &m[0])
+	return FontMetrics{
+		Ascent:             float64(m[0]),
+		Descent:            float64(m[1]),
+		Leading:            float64(m[2]),
+		XHeight:            float64(m[3]),
+		CapHeight:          float64(m[4]),
+		UnderlinePosition:  float64(m[5]),
+		UnderlineThickness: float64(m[6]),
+	}
+}
+
+// prefixWidths finds the widths of the prefixes of text from the offsets of a
+// single CTLine, rather than laying out each prefix by itself.
+func (d *drawContext) prefixWidths(text string, ends []int,
+	f Font) []float64 {
+	res := make([]float64, len(ends))
+	if len(ends) == 0 {
+		return res
+	}
+
+	// CoreText indexes strings by UTF-16 code unit.
+	indices := make([]C.long, len(ends))
+	var index, j int
+	for i, r := range text {
+		for j < len(ends) && ends[j] <= i {
+			indices[j] = C.long(index)
+			j++
+		}
+		index += len(utf16.Encode([]rune{r}))
+	}
+	for ; j < len(ends); j++ {
+		indices[j] = C.long(index)
+	}
+
+	offsets := make([]C.double, len(ends))
+	C.ContextTextOffsets(C.CString(text), fontDescriptor(f), &indices[0],
+		&offsets[0], C.int(len(ends)))
+	for i, x := range offsets {
+		res[i] = float64(x)
+	}
+	return res
+}
+
+// textOrigin returns the point where text is drawn so that it lines up with
+// the current alignment and baseline.
+func (d *drawContext) textOrigin(text string, x, y float64) (float64,
+	float64) {
+	width, height := d.TextSize(text)
+	ascent := d.FontMetrics().Ascent
+	return textOrigin(x, y, width, height, ascent, d.textAlign,
+		d.textBaseline)
+}
+
+func (d *drawContext) textSize(text string, f Font) (float64, float64) {
+	s := C.ContextTextSize(C.CString(text), fontDescriptor(f))
 	return float64(s.width), float64(s.height)
 }
@@ -70,6 +70,12 @@ void MainLoop(const char * name) {
 	[pool release];
 }
 
+void MainScreenSize(double * size) {
+	NSRect frame = [NSScreen mainScreen].frame;
+	size[0] = frame.size.width;
+	size[1] = frame.size.height;
+}
+
 void DispatchMainEvent() {
 	dispatch_async(dispatch_get_main_queue(), ^{
 		runNextEvent();
@@ -86,16 +92,34 @@ import (
 func init() {
 	// Make sure main.main runs on the main OS thread.
 	runtime.LockOSThread()
+
+	RegisterDriver("cocoa", cocoaDriver{})
+}
+
+// A cocoaDriver shows windows with Cocoa.
+type cocoaDriver struct{}
+
+// Available returns true, since Cocoa is always present on OS X.
+func (cocoaDriver) Available() bool {
+	return true
 }
 
 // Main runs the Cocoa runloop. You must call this from main.main.
-func Main(info *AppInfo) {
+func (cocoaDriver) Main(info *AppInfo) {
 	C.MainLoop(C.CString(info.Name))
 }
 
 // RunOnMain runs a function on the main goroutine asynchronously using the
 // dispatch_async() API.
-func RunOnMain(f func()) {
+func (cocoaDriver) RunOnMain(f func()) {
 	pushEvent(f)
 	C.DispatchMainEvent()
 }
+
+// screenBounds returns the frame of the main screen. Window frames are
+// measured from its top-left corner.
+func (cocoaDriver) screenBounds() Rect {
+	var size [2]C.double
+	C.MainScreenSize(&size[0])
+	return Rect{0, 0, float64(size[0]), float64(size[1])}
+}
@@ -227,6 +227,9 @@ void ShowWindow(void * w) {
 import "C"
 
 import (
+	"image"
+	"image/draw"
+	"math"
 	"runtime"
 	"unsafe"
 )
@@ -245,7 +248,7 @@ type window struct {
 // NewWindow creates a new window with a given content rectangle.
 // The created window will not be showing by default.
 // You must call this from the main goroutine.
-func NewWindow(r Rect) (Window, error) {
+func (cocoaDriver) NewWindow(r Rect) (Window, error) {
 	ptr := C.CreateWindow(C.double(r.X), C.double(r.Y), C.double(r.Width),
 		C.double(r.Height))
 	res := &window{pointer: ptr, widgets: []Widget{}}
@@ -255,7 +258,7 @@ func NewWindow(r Rect) (Window, error) {
 
 // ShowingWindows returns a slice containing all the currently active windows.
 // You must call this from the main goroutine.
-func ShowingWindows() []Window {
+func (cocoaDriver) ShowingWindows() []Window {
 	cpy := make([]Window, len(showingWindows))
 	copy(cpy, showingWindows)
 	return cpy
@@ -340,6 +343,36 @@ func (w *window) Showing() bool {
 	return w.showing
 }
 
+func (w *window) Snapshot() (image.Image, error) {
+	return w.SnapshotScale(1)
+}
+
+func (w *window) SnapshotScale(scale float64) (image.Image, error) {
+	bounds, err := snapshotBounds(w.Frame(), scale)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, image.NewUniform(softWindowBackground), image.ZP,
+		draw.Src)
+	for _, widget := range w.widgets {
+		c, ok := widget.(Canvas)
+		if !ok {
+			continue
+		}
+		snapshot, err := c.SnapshotScale(scale)
+		if err != nil {
+			return nil, err
+		}
+		f := c.Frame()
+		offset := image.Pt(int(math.Floor(f.X*scale+0.5)),
+			int(math.Floor(f.Y*scale+0.5)))
+		draw.Draw(img, snapshot.Bounds().Add(offset), snapshot, image.ZP,
+			draw.Over)
+	}
+	return img, nil
+}
+
 func (w *window) removeView(v ptrView) {
 	ptr := v.viewPointer()
 	C.RemoveFromSuperview(ptr)
@@ -359,7 +392,7 @@ func (w *window) removeView(v ptrView) {
 }
 
 func finalizeWindow(w *window) {
-	RunOnMain(func() {
+	cocoaDriver{}.RunOnMain(func() {
 		for len(w.widgets) > 0 {
 			w.widgets[0].Remove()
 		}
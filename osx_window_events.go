@@ -8,25 +8,6 @@ import (
 	"unsafe"
 )
 
-const (
-	keyEventDown = iota
-	keyEventUp   = iota
-)
-
-const (
-	keyFlagAlt   = 1
-	keyFlagCtrl  = 2
-	keyFlagMeta  = 4
-	keyFlagShift = 8
-)
-
-const (
-	mouseEventDown = iota
-	mouseEventDrag = iota
-	mouseEventMove = iota
-	mouseEventUp   = iota
-)
-
 func callKeyDown(w *window, evt KeyEvent) {
 	if h := w.KeyDownHandler(); h != nil {
 		h(evt)
@@ -55,23 +36,6 @@ func findWindow(ptr unsafe.Pointer) *window {
 	return nil
 }
 
-func makeKeyEvent(keyCode, charCode int, flags int) KeyEvent {
-	res := KeyEvent{KeyCode: keyCode, CharCode: charCode}
-	if (flags & keyFlagAlt) != 0 {
-		res.AltKey = true
-	}
-	if (flags & keyFlagCtrl) != 0 {
-		res.CtrlKey = true
-	}
-	if (flags & keyFlagMeta) != 0 {
-		res.MetaKey = true
-	}
-	if (flags & keyFlagShift) != 0 {
-		res.ShiftKey = true
-	}
-	return res
-}
-
 //export windowClosed
 func windowClosed(ptr unsafe.Pointer) {
 	for i, w := range showingWindows {
@@ -0,0 +1,302 @@
+package gogui
+
+import (
+	"math"
+	"sort"
+)
+
+// A Paint is something that DrawContext can fill and stroke with. It is a
+// Color, a LinearGradient, or a RadialGradient.
+type Paint interface {
+	isPaint()
+}
+
+func (c Color) isPaint() {}
+
+// An ExtendMode says what a gradient shows past its first and last stops.
+type ExtendMode int
+
+const (
+	// ExtendPad continues the colors of the first and last stops.
+	ExtendPad ExtendMode = iota
+
+	// ExtendRepeat repeats the gradient.
+	ExtendRepeat
+
+	// ExtendReflect repeats the gradient, mirroring every other copy.
+	ExtendReflect
+)
+
+// A GradientStop is a color at some offset along a gradient. The offset goes
+// from 0 at the start of the gradient to 1 at the end.
+type GradientStop struct {
+	Offset float64
+	Color  Color
+}
+
+// A LinearGradient changes color along the line from (X1, Y1) to (X2, Y2).
+//
+// The points of a gradient are in the coordinate system which is current when
+// something is drawn with it, not when it is passed to SetFillPaint.
+type LinearGradient struct {
+	X1     float64
+	Y1     float64
+	X2     float64
+	Y2     float64
+	Stops  []GradientStop
+	Extend ExtendMode
+}
+
+func (l LinearGradient) isPaint() {}
+
+// A RadialGradient changes color from the circle at (X1, Y1) with radius R1 to
+// the circle at (X2, Y2) with radius R2, like a radial gradient on an HTML5
+// canvas. Usually the first circle is the center of the second one, with a
+// radius of zero.
+type RadialGradient struct {
+	X1     float64
+	Y1     float64
+	R1     float64
+	X2     float64
+	Y2     float64
+	R2     float64
+	Stops  []GradientStop
+	Extend ExtendMode
+}
+
+func (r RadialGradient) isPaint() {}
+
+// paintColor returns the color of a Paint which is a solid color. A nil Paint
+// is transparent.
+func paintColor(p Paint) (Color, bool) {
+	switch p := p.(type) {
+	case nil:
+		return Color{}, true
+	case Color:
+		return p, true
+	case *Color:
+		return *p, true
+	}
+	return Color{}, false
+}
+
+// A gradient is a LinearGradient or a RadialGradient in a form which is easy to
+// render. The radii of a linear gradient are zero.
+type gradient struct {
+	radial bool
+	start  point
+	end    point
+	r1     float64
+	r2     float64
+	extend ExtendMode
+
+	// stops are sorted by offset, and the offsets are between 0 and 1.
+	stops []GradientStop
+}
+
+// paintGradient returns the gradient of a Paint which is a gradient.
+func paintGradient(p Paint) (*gradient, bool) {
+	var g gradient
+	var stops []GradientStop
+	switch p := p.(type) {
+	case *LinearGradient:
+		return paintGradient(*p)
+	case *RadialGradient:
+		return paintGradient(*p)
+	case LinearGradient:
+		g.start, g.end = point{p.X1, p.Y1}, point{p.X2, p.Y2}
+		g.extend, stops = p.Extend, p.Stops
+	case RadialGradient:
+		g.radial = true
+		g.start, g.end = point{p.X1, p.Y1}, point{p.X2, p.Y2}
+		g.r1, g.r2 = math.Max(0, p.R1), math.Max(0, p.R2)
+		g.extend, stops = p.Extend, p.Stops
+	default:
+		return nil, false
+	}
+	g.stops = make([]GradientStop, len(stops))
+	for i, s := range stops {
+		g.stops[i] = GradientStop{clampUnit(s.Offset), s.Color}
+	}
+	sort.SliceStable(g.stops, func(i, j int) bool {
+		return g.stops[i].Offset < g.stops[j].Offset
+	})
+	return &g, true
+}
+
+// param returns the position along the gradient of a point in user space. It
+// returns false if the gradient does not cover the point.
+func (g *gradient) param(p point) (float64, bool) {
+	dx, dy := g.end.X-g.start.X, g.end.Y-g.start.Y
+	px, py := p.X-g.start.X, p.Y-g.start.Y
+	if !g.radial {
+		lenSq := dx*dx + dy*dy
+		if lenSq == 0 {
+			return 0, false
+		}
+		return (px*dx + py*dy) / lenSq, true
+	}
+
+	// Find the largest t for which the point is on the circle between the
+	// two circles at t and the radius of that circle is not negative.
+	dr := g.r2 - g.r1
+	a := dx*dx + dy*dy - dr*dr
+	b := px*dx + py*dy + g.r1*dr
+	c := px*px + py*py - g.r1*g.r1
+	var roots []float64
+	if a == 0 {
+		if b == 0 {
+			return 0, false
+		}
+		roots = []float64{c / (2 * b)}
+	} else {
+		disc := b*b - a*c
+		if disc < 0 {
+			return 0, false
+		}
+		sqrt := math.Sqrt(disc)
+		roots = []float64{(b + sqrt) / a, (b - sqrt) / a}
+		if roots[1] > roots[0] {
+			roots[0], roots[1] = roots[1], roots[0]
+		}
+	}
+	for _, t := range roots {
+		if g.r1+t*dr >= 0 {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// colorAt returns the color at a position along the gradient, taking the
+// extend mode into account.
+func (g *gradient) colorAt(t float64) Color {
+	if len(g.stops) == 0 {
+		return Color{}
+	}
+	switch g.extend {
+	case ExtendRepeat:
+		t -= math.Floor(t)
+	case ExtendReflect:
+		t = math.Mod(math.Abs(t), 2)
+		if t > 1 {
+			t = 2 - t
+		}
+	}
+	first, last := g.stops[0], g.stops[len(g.stops)-1]
+	if !(t > first.Offset) {
+		return first.Color
+	} else if t >= last.Offset {
+		return last.Color
+	}
+	i := sort.Search(len(g.stops), func(i int) bool {
+		return g.stops[i].Offset > t
+	})
+	s1, s2 := g.stops[i-1], g.stops[i]
+	frac := (t - s1.Offset) / (s2.Offset - s1.Offset)
+	mix := func(a, b float64) float64 {
+		return a + (b-a)*frac
+	}
+	return Color{mix(s1.Color.R, s2.Color.R), mix(s1.Color.G, s2.Color.G),
+		mix(s1.Color.B, s2.Color.B), mix(s1.Color.A, s2.Color.A)}
+}
+
+// padded returns a gradient which looks the same in the area spanned by some
+// points, but which only needs ExtendPad. Backends which can only pad use it to
+// draw repeating and reflecting gradients.
+func (g *gradient) padded(area []point) *gradient {
+	if g.extend == ExtendPad || len(g.stops) == 0 {
+		return g
+	}
+	t0, t1 := 0.0, 1.0
+	for _, p := range area {
+		if t, ok := g.param(p); ok {
+			t0, t1 = math.Min(t0, t), math.Max(t1, t)
+		}
+	}
+	if dr := g.r2 - g.r1; g.radial && dr > 0 {
+		// Smaller circles have negative radii, so they are never drawn.
+		t0 = -g.r1 / dr
+	} else if g.radial && dr < 0 {
+		t1 = math.Min(t1, -g.r1/dr)
+	}
+	t0, t1 = math.Max(t0, t1-1000), math.Min(t1, t0+1000)
+
+	at := func(t float64) point {
+		return point{g.start.X + (g.end.X-g.start.X)*t,
+			g.start.Y + (g.end.Y-g.start.Y)*t}
+	}
+	res := &gradient{
+		radial: g.radial,
+		start:  at(t0),
+		end:    at(t1),
+		r1:     g.r1 + (g.r2-g.r1)*t0,
+		r2:     g.r1 + (g.r2-g.r1)*t1,
+		extend: ExtendPad,
+	}
+	addStop := func(t float64, c Color) {
+		offset := 0.0
+		if t1 > t0 {
+			offset = (t - t0) / (t1 - t0)
+		}
+		res.stops = append(res.stops, GradientStop{offset, c})
+	}
+	addStop(t0, g.colorAt(t0))
+
+	// Each copy of the gradient pads up to its ends.
+	stops := g.stops
+	if first := stops[0]; first.Offset > 0 {
+		stops = append([]GradientStop{{0, first.Color}}, stops...)
+	}
+	if last := stops[len(stops)-1]; last.Offset < 1 {
+		stops = append(stops, GradientStop{1, last.Color})
+	}
+	for period := math.Floor(t0); period < t1; period++ {
+		for i := range stops {
+			stop := stops[i]
+			if g.extend == ExtendReflect && int64(period)%2 != 0 {
+				stop = stops[len(stops)-1-i]
+				stop.Offset = 1 - stop.Offset
+			}
+			if t := period + stop.Offset; t > t0 && t < t1 {
+				addStop(t, stop.Color)
+			}
+		}
+	}
+	addStop(t1, g.colorAt(t1))
+	return res
+}
+
+// args encodes the gradient as the arguments of a drawCommand.
+func (g *gradient) args() []float64 {
+	var kind float64
+	if g.radial {
+		kind = 1
+	}
+	res := []float64{kind, float64(g.extend), g.start.X, g.start.Y, g.r1,
+		g.end.X, g.end.Y, g.r2}
+	for _, s := range g.stops {
+		res = append(res, s.Offset, s.Color.R, s.Color.G, s.Color.B, s.Color.A)
+	}
+	return res
+}
+
+// decodeGradient reverses gradient.args. It returns false if the arguments
+// are malformed.
+func decodeGradient(args []float64) (Paint, bool) {
+	if len(args) < 8 || (len(args)-8)%5 != 0 {
+		return nil, false
+	}
+	var stops []GradientStop
+	for i := 8; i < len(args); i += 5 {
+		a := args[i:]
+		stops = append(stops, GradientStop{a[0], Color{a[1], a[2], a[3], a[4]}})
+	}
+	extend := ExtendMode(args[1])
+	if args[0] != 0 {
+		return RadialGradient{args[2], args[3], args[4], args[5], args[6],
+			args[7], stops, extend}, true
+	}
+	return LinearGradient{args[2], args[3], args[5], args[6], stops,
+		extend}, true
+}
@@ -0,0 +1,358 @@
+package gogui
+
+import (
+	"math"
+)
+
+// A FillRule decides which points are inside of a path whose outline crosses
+// itself or which has several subpaths.
+type FillRule int
+
+const (
+	// NonZero counts a point as inside if the outline winds around it a
+	// different number of times in each direction.
+	NonZero FillRule = iota
+
+	// EvenOdd counts a point as inside if a ray from the point crosses the
+	// outline an odd number of times.
+	EvenOdd
+)
+
+// contains reports whether a winding number is inside of a shape.
+func (f FillRule) contains(winding int) bool {
+	if f == EvenOdd {
+		return winding%2 != 0
+	}
+	return winding != 0
+}
+
+// A Path is a shape made of lines and curves.
+//
+// Unlike the current path of a DrawContext, a Path can be kept between draw
+// handlers. Draw it with FillPathObject or StrokePathObject and then use
+// Contains or StrokeContains to find out whether mouse events hit it.
+//
+// The zero value is an empty path. Arcs are stored as cubic Bezier curves, so a
+// path only ever holds lines and curves.
+type Path struct {
+	segments []pathSegment
+
+	start      point
+	current    point
+	hasCurrent bool
+}
+
+type pathSegmentType int
+
+const (
+	pathMoveTo pathSegmentType = iota
+	pathLineTo
+	pathQuadraticTo
+	pathCubicTo
+	pathClose
+)
+
+// A pathSegment is one path verb. The points are the control points followed
+// by the end point, so a pathClose has no points.
+type pathSegment struct {
+	kind   pathSegmentType
+	points []point
+}
+
+// Arc adds an arc of a circle to the path, as DrawContext.Arc does.
+func (p *Path) Arc(x, y, radius, startAngle, endAngle float64,
+	clockwise bool) {
+	radius = math.Max(0, radius)
+	sweep := arcSweep(startAngle, endAngle, clockwise)
+	start := point{x + radius*math.Cos(startAngle),
+		y + radius*math.Sin(startAngle)}
+	if p.hasCurrent {
+		p.LineTo(start.X, start.Y)
+	} else {
+		p.MoveTo(start.X, start.Y)
+	}
+
+	// Each piece of at most a quarter turn is close to a cubic curve.
+	count := int(math.Ceil(math.Abs(sweep) / (math.Pi / 2)))
+	for i := 0; i < count; i++ {
+		a0 := startAngle + sweep*float64(i)/float64(count)
+		a1 := startAngle + sweep*float64(i+1)/float64(count)
+		k := 4.0 / 3 * math.Tan((a1-a0)/4) * radius
+		sin0, cos0 := math.Sin(a0), math.Cos(a0)
+		sin1, cos1 := math.Sin(a1), math.Cos(a1)
+		p.CubicTo(x+radius*cos0-k*sin0, y+radius*sin0+k*cos0,
+			x+radius*cos1+k*sin1, y+radius*sin1-k*cos1,
+			x+radius*cos1, y+radius*sin1)
+	}
+}
+
+// ArcTo adds a tangent arc to the path, as DrawContext.ArcTo does.
+func (p *Path) ArcTo(x1, y1, x2, y2, radius float64) {
+	if !p.hasCurrent {
+		p.MoveTo(x1, y1)
+	}
+	center, start, end, clockwise, ok := tangentArc(p.current,
+		point{x1, y1}, point{x2, y2}, radius)
+	if !ok {
+		p.LineTo(x1, y1)
+		return
+	}
+	p.Arc(center.X, center.Y, radius, start, end, clockwise)
+}
+
+// Bounds returns the smallest rectangle which contains the path. It does not
+// count control points which the curves do not reach.
+func (p *Path) Bounds() Rect {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	add := func(q point) {
+		minX, maxX = math.Min(minX, q.X), math.Max(maxX, q.X)
+		minY, maxY = math.Min(minY, q.Y), math.Max(maxY, q.Y)
+	}
+	var last point
+	for _, s := range p.segments {
+		switch s.kind {
+		case pathQuadraticTo:
+			for _, t := range quadraticExtrema(last, s.points[0], s.points[1]) {
+				u := 1 - t
+				add(point{u*u*last.X + 2*t*u*s.points[0].X + t*t*s.points[1].X,
+					u*u*last.Y + 2*t*u*s.points[0].Y + t*t*s.points[1].Y})
+			}
+		case pathCubicTo:
+			for _, t := range cubicExtrema(last, s.points[0], s.points[1],
+				s.points[2]) {
+				u := 1 - t
+				a, b, c, d := u*u*u, 3*t*u*u, 3*t*t*u, t*t*t
+				add(point{a*last.X + b*s.points[0].X + c*s.points[1].X +
+					d*s.points[2].X, a*last.Y + b*s.points[0].Y +
+					c*s.points[1].Y + d*s.points[2].Y})
+			}
+		}
+		if len(s.points) > 0 {
+			last = s.points[len(s.points)-1]
+			add(last)
+		}
+	}
+	if minX > maxX {
+		return Rect{}
+	}
+	return Rect{minX, minY, maxX - minX, maxY - minY}
+}
+
+// ClosePath closes the current subpath by connecting its first and last
+// points.
+func (p *Path) ClosePath() {
+	if p.hasCurrent {
+		p.segments = append(p.segments, pathSegment{kind: pathClose})
+		p.current = p.start
+	}
+}
+
+// Contains reports whether a point is inside the path, as FillPathObject would
+// fill it with the given rule. Open subpaths are treated as closed.
+// Pass the rule which was given to DrawContext.SetFillRule to test clicks
+// against a filled path.
+func (p *Path) Contains(x, y float64, rule FillRule) bool {
+	winding := 0
+	for _, line := range p.polylines() {
+		points := line.points
+		for i, a := range points {
+			b := points[(i+1)%len(points)]
+			side := (b.X-a.X)*(y-a.Y) - (x-a.X)*(b.Y-a.Y)
+			if a.Y <= y {
+				if b.Y > y && side > 0 {
+					winding++
+				}
+			} else if b.Y <= y && side < 0 {
+				winding--
+			}
+		}
+	}
+	return rule.contains(winding)
+}
+
+// CubicTo adds a cubic Bezier curve to the path.
+func (p *Path) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	if !p.hasCurrent {
+		p.MoveTo(c1x, c1y)
+	}
+	p.add(pathCubicTo, point{c1x, c1y}, point{c2x, c2y}, point{x, y})
+}
+
+// LineTo adds a line from the current point to another point. If the path is
+// empty, LineTo acts like MoveTo.
+func (p *Path) LineTo(x, y float64) {
+	if !p.hasCurrent {
+		p.MoveTo(x, y)
+		return
+	}
+	p.add(pathLineTo, point{x, y})
+}
+
+// MoveTo starts a new subpath at a point.
+func (p *Path) MoveTo(x, y float64) {
+	p.add(pathMoveTo, point{x, y})
+	p.start = point{x, y}
+	p.hasCurrent = true
+}
+
+// QuadraticTo adds a quadratic Bezier curve to the path.
+func (p *Path) QuadraticTo(cx, cy, x, y float64) {
+	if !p.hasCurrent {
+		p.MoveTo(cx, cy)
+	}
+	p.add(pathQuadraticTo, point{cx, cy}, point{x, y})
+}
+
+// StrokeContains reports whether a point is covered by the outline of the
+// path when it is stroked with a given thickness and round caps and joins.
+func (p *Path) StrokeContains(x, y, thickness float64) bool {
+	radius := thickness / 2
+	for _, line := range p.polylines() {
+		points := line.points
+		if len(points) < 2 {
+			continue
+		}
+		segCount := len(points) - 1
+		if line.closed {
+			segCount++
+		}
+		for i := 0; i < segCount; i++ {
+			a, b := points[i], points[(i+1)%len(points)]
+			if segmentDistance(point{x, y}, a, b) <= radius {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Transform returns a copy of the path with every point mapped through a
+// matrix. The original path is not changed.
+func (p *Path) Transform(m Matrix) *Path {
+	mapPoint := func(q point) point {
+		x, y := m.Apply(q.X, q.Y)
+		return point{x, y}
+	}
+	res := &Path{
+		segments:   make([]pathSegment, len(p.segments)),
+		start:      mapPoint(p.start),
+		current:    mapPoint(p.current),
+		hasCurrent: p.hasCurrent,
+	}
+	for i, s := range p.segments {
+		points := make([]point, len(s.points))
+		for j, q := range s.points {
+			points[j] = mapPoint(q)
+		}
+		res.segments[i] = pathSegment{s.kind, points}
+	}
+	return res
+}
+
+func (p *Path) add(kind pathSegmentType, points ...point) {
+	p.segments = append(p.segments, pathSegment{kind, points})
+	p.current = points[len(points)-1]
+}
+
+// addTo adds the path to the current path of a DrawContext.
+func (p *Path) addTo(ctx DrawContext) {
+	for _, s := range p.segments {
+		q := s.points
+		switch s.kind {
+		case pathMoveTo:
+			ctx.MoveTo(q[0].X, q[0].Y)
+		case pathLineTo:
+			ctx.LineTo(q[0].X, q[0].Y)
+		case pathQuadraticTo:
+			ctx.QuadraticTo(q[0].X, q[0].Y, q[1].X, q[1].Y)
+		case pathCubicTo:
+			ctx.CubicTo(q[0].X, q[0].Y, q[1].X, q[1].Y, q[2].X, q[2].Y)
+		case pathClose:
+			ctx.ClosePath()
+		}
+	}
+}
+
+// polylines flattens the path into line segments.
+func (p *Path) polylines() []polyline {
+	var res []polyline
+	for _, s := range p.segments {
+		if s.kind == pathMoveTo {
+			res = append(res, polyline{points: []point{s.points[0]}})
+			continue
+		}
+		line := &res[len(res)-1]
+		if line.closed {
+			res = append(res, polyline{points: []point{line.points[0]}})
+			line = &res[len(res)-1]
+		}
+		last := line.points[len(line.points)-1]
+		switch s.kind {
+		case pathLineTo:
+			line.points = append(line.points, s.points[0])
+		case pathQuadraticTo:
+			line.points = append(line.points, flattenQuadratic(last,
+				s.points[0], s.points[1])...)
+		case pathCubicTo:
+			line.points = append(line.points, flattenCubic(last, s.points[0],
+				s.points[1], s.points[2])...)
+		case pathClose:
+			line.closed = true
+		}
+	}
+	return res
+}
+
+// quadraticExtrema returns the values of t in (0, 1) where a quadratic curve
+// turns around in x or y.
+func quadraticExtrema(p0, p1, p2 point) []float64 {
+	var res []float64
+	for _, c := range [][3]float64{{p0.X, p1.X, p2.X}, {p0.Y, p1.Y, p2.Y}} {
+		denom := c[0] - 2*c[1] + c[2]
+		if denom != 0 {
+			if t := (c[0] - c[1]) / denom; t > 0 && t < 1 {
+				res = append(res, t)
+			}
+		}
+	}
+	return res
+}
+
+// cubicExtrema returns the values of t in (0, 1) where a cubic curve turns
+// around in x or y.
+func cubicExtrema(p0, p1, p2, p3 point) []float64 {
+	var res []float64
+	coords := [][4]float64{{p0.X, p1.X, p2.X, p3.X}, {p0.Y, p1.Y, p2.Y, p3.Y}}
+	for _, c := range coords {
+		// The derivative is a*t^2 + b*t + c, up to a factor of 3.
+		a := -c[0] + 3*c[1] - 3*c[2] + c[3]
+		b := 2 * (c[0] - 2*c[1] + c[2])
+		k := c[1] - c[0]
+		var roots []float64
+		if math.Abs(a) < 1e-12 {
+			if b != 0 {
+				roots = []float64{-k / b}
+			}
+		} else if disc := b*b - 4*a*k; disc >= 0 {
+			sqrt := math.Sqrt(disc)
+			roots = []float64{(-b + sqrt) / (2 * a), (-b - sqrt) / (2 * a)}
+		}
+		for _, t := range roots {
+			if t > 0 && t < 1 {
+				res = append(res, t)
+			}
+		}
+	}
+	return res
+}
+
+// segmentDistance returns the distance from a point to a line segment.
+func segmentDistance(p, a, b point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if lenSq := dx*dx + dy*dy; lenSq > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/lenSq))
+	}
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
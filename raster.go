@@ -0,0 +1,238 @@
+package gogui
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// rasterSubsamples is the number of sample rows used for each row of pixels.
+// Horizontal coverage is computed exactly, so this only affects the quality of
+// anti-aliasing for nearly horizontal edges.
+const rasterSubsamples = 4
+
+// A point is a location in device space.
+type point struct {
+	X float64
+	Y float64
+}
+
+// A polygon is a closed list of points. The last point is implicitly
+// connected to the first one.
+type polygon []point
+
+// area computes the signed area of the polygon.
+func (p polygon) area() float64 {
+	var res float64
+	for i, a := range p {
+		b := p[(i+1)%len(p)]
+		res += a.X*b.Y - b.X*a.Y
+	}
+	return res / 2
+}
+
+// oriented returns the polygon with its points in positive order. Polygons
+// which are filled together with the non-zero winding rule must agree on their
+// orientation so that their overlapping regions do not cancel out.
+func (p polygon) oriented() polygon {
+	if p.area() >= 0 {
+		return p
+	}
+	res := make(polygon, len(p))
+	for i, x := range p {
+		res[len(p)-i-1] = x
+	}
+	return res
+}
+
+type rasterEdge struct {
+	x0, y0 float64
+	x1, y1 float64
+	dir    int
+}
+
+func (e *rasterEdge) xAt(y float64) float64 {
+	return e.x0 + (y-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+}
+
+type rasterCrossing struct {
+	x   float64
+	dir int
+}
+
+type crossingList []rasterCrossing
+
+func (c crossingList) Len() int {
+	return len(c)
+}
+
+func (c crossingList) Less(i, j int) bool {
+	return c[i].x < c[j].x
+}
+
+func (c crossingList) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// rasterizePolygons computes the anti-aliased coverage of a set of polygons
+// using a fill rule.
+//
+// The returned mask only spans the part of bounds which the polygons touch.
+// If they touch nothing, rasterizePolygons returns nil.
+func rasterizePolygons(polys []polygon, bounds image.Rectangle,
+	rule FillRule) *image.Alpha {
+	edges := make([]rasterEdge, 0, 16)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range polys {
+		for i, a := range p {
+			minX, maxX = math.Min(minX, a.X), math.Max(maxX, a.X)
+			minY, maxY = math.Min(minY, a.Y), math.Max(maxY, a.Y)
+			b := p[(i+1)%len(p)]
+			if a.Y == b.Y {
+				continue
+			}
+			if a.Y < b.Y {
+				edges = append(edges, rasterEdge{a.X, a.Y, b.X, b.Y, 1})
+			} else {
+				edges = append(edges, rasterEdge{b.X, b.Y, a.X, a.Y, -1})
+			}
+		}
+	}
+	if len(edges) == 0 || math.IsNaN(minX+minY+maxX+maxY) {
+		return nil
+	}
+
+	box := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)),
+		int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(bounds)
+	if box.Empty() {
+		return nil
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].y0 < edges[j].y0
+	})
+
+	mask := image.NewAlpha(box)
+	width := box.Dx()
+	row := make([]float64, width)
+	active := []*rasterEdge{}
+	crossings := crossingList{}
+	nextEdge := 0
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+		for s := 0; s < rasterSubsamples; s++ {
+			sampleY := float64(y) + (float64(s)+0.5)/rasterSubsamples
+
+			// Update the active edge list for this sample row.
+			for nextEdge < len(edges) && edges[nextEdge].y0 <= sampleY {
+				active = append(active, &edges[nextEdge])
+				nextEdge++
+			}
+			crossings = crossings[:0]
+			for i := 0; i < len(active); i++ {
+				e := active[i]
+				if e.y1 <= sampleY {
+					active[i] = active[len(active)-1]
+					active = active[:len(active)-1]
+					i--
+					continue
+				}
+				crossings = append(crossings, rasterCrossing{e.xAt(sampleY),
+					e.dir})
+			}
+			sort.Sort(crossings)
+
+			// Fill the spans where the winding number is inside.
+			winding := 0
+			var start float64
+			for _, c := range crossings {
+				wasInside := rule.contains(winding)
+				winding += c.dir
+				if inside := rule.contains(winding); inside && !wasInside {
+					start = c.x
+				} else if !inside && wasInside {
+					addCoverageSpan(row, start-float64(box.Min.X),
+						c.x-float64(box.Min.X), 1.0/rasterSubsamples)
+				}
+			}
+		}
+		offset := mask.PixOffset(box.Min.X, y)
+		for i, c := range row {
+			mask.Pix[offset+i] = uint8(math.Min(c, 1)*0xff + 0.5)
+		}
+	}
+	return mask
+}
+
+// intersectMasks multiplies the coverage of two masks. The result only spans
+// the area where both masks are set, and it is nil if there is no such area.
+func intersectMasks(a, b *image.Alpha) *image.Alpha {
+	if a == nil || b == nil {
+		return nil
+	}
+	r := a.Rect.Intersect(b.Rect)
+	if r.Empty() {
+		return nil
+	}
+	res := image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		aIdx, bIdx := a.PixOffset(r.Min.X, y), b.PixOffset(r.Min.X, y)
+		resIdx := res.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			product := int(a.Pix[aIdx]) * int(b.Pix[bIdx])
+			res.Pix[resIdx] = uint8((product + 0x7f) / 0xff)
+			aIdx++
+			bIdx++
+			resIdx++
+		}
+	}
+	return res
+}
+
+// addCoverageSpan adds weighted coverage for the horizontal span from x0 to x1
+// to a row of pixels.
+func addCoverageSpan(row []float64, x0, x1, weight float64) {
+	x0 = math.Max(0, x0)
+	x1 = math.Min(float64(len(row)), x1)
+	if x1 <= x0 {
+		return
+	}
+	i0 := int(x0)
+	i1 := int(x1)
+	if i0 == i1 {
+		row[i0] += (x1 - x0) * weight
+		return
+	}
+	row[i0] += (float64(i0+1) - x0) * weight
+	for i := i0 + 1; i < i1; i++ {
+		row[i] += weight
+	}
+	if i1 < len(row) {
+		row[i1] += (x1 - float64(i1)) * weight
+	}
+}
+
+// ellipsePolygon approximates an ellipse with a polygon. The number of points
+// is chosen so that the approximation is accurate to a fraction of a pixel.
+func ellipsePolygon(cx, cy, rx, ry float64) polygon {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	count := ellipseSegments(math.Max(rx, ry))
+	res := make(polygon, count)
+	for i := range res {
+		angle := 2 * math.Pi * float64(i) / float64(count)
+		res[i] = point{cx + rx*math.Cos(angle), cy + ry*math.Sin(angle)}
+	}
+	return res
+}
+
+// ellipseSegments returns the number of points which ellipsePolygon uses for
+// an ellipse whose larger radius is r.
+func ellipseSegments(r float64) int {
+	count := int(math.Ceil(10 * math.Sqrt(r)))
+	if count < 12 {
+		count = 12
+	}
+	return count
+}
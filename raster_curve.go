@@ -0,0 +1,102 @@
+package gogui
+
+import (
+	"math"
+)
+
+// curveTolerance is the largest distance, in pixels, between a curve and the
+// line segments which approximate it.
+const curveTolerance = 0.1
+
+// flattenQuadratic approximates a quadratic Bezier curve with line segments.
+// It returns every point after p0.
+func flattenQuadratic(p0, p1, p2 point) []point {
+	dd := 2 * math.Hypot(p0.X-2*p1.X+p2.X, p0.Y-2*p1.Y+p2.Y)
+	count := curveSegments(dd)
+	res := make([]point, count)
+	for i := range res {
+		t := float64(i+1) / float64(count)
+		a, b, c := (1-t)*(1-t), 2*t*(1-t), t*t
+		res[i] = point{a*p0.X + b*p1.X + c*p2.X, a*p0.Y + b*p1.Y + c*p2.Y}
+	}
+	return res
+}
+
+// flattenCubic approximates a cubic Bezier curve with line segments.
+// It returns every point after p0.
+func flattenCubic(p0, p1, p2, p3 point) []point {
+	dd := 6 * math.Max(math.Hypot(p0.X-2*p1.X+p2.X, p0.Y-2*p1.Y+p2.Y),
+		math.Hypot(p1.X-2*p2.X+p3.X, p1.Y-2*p2.Y+p3.Y))
+	count := curveSegments(dd)
+	res := make([]point, count)
+	for i := range res {
+		t := float64(i+1) / float64(count)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*t*u*u, 3*t*t*u, t*t*t
+		res[i] = point{a*p0.X + b*p1.X + c*p2.X + d*p3.X,
+			a*p0.Y + b*p1.Y + c*p2.Y + d*p3.Y}
+	}
+	return res
+}
+
+// curveSegments returns the number of equal steps in t which keep a curve
+// within curveTolerance of its chords, given the largest magnitude of the
+// curve's second derivative.
+func curveSegments(dd float64) int {
+	count := math.Ceil(math.Sqrt(dd / (8 * curveTolerance)))
+	if math.IsNaN(count) || count < 1 {
+		return 1
+	} else if count > 1000 {
+		return 1000
+	}
+	return int(count)
+}
+
+// arcSweep returns the signed angle which an arc covers, following the rules
+// of the HTML5 canvas. Clockwise arcs have a positive sweep.
+func arcSweep(start, end float64, clockwise bool) float64 {
+	sweep := end - start
+	if !clockwise {
+		sweep = -sweep
+	}
+	if sweep >= 2*math.Pi {
+		sweep = 2 * math.Pi
+	} else {
+		sweep = math.Mod(sweep, 2*math.Pi)
+		if sweep < 0 {
+			sweep += 2 * math.Pi
+		}
+	}
+	if !clockwise {
+		sweep = -sweep
+	}
+	return sweep
+}
+
+// tangentArc finds the arc which ArcTo adds for a path whose current point is
+// p0. It returns false if ArcTo should add a line to p1 instead.
+func tangentArc(p0, p1, p2 point, radius float64) (center point, start,
+	end float64, clockwise, ok bool) {
+	dx0, dy0 := p0.X-p1.X, p0.Y-p1.Y
+	dx2, dy2 := p2.X-p1.X, p2.Y-p1.Y
+	len0, len2 := math.Hypot(dx0, dy0), math.Hypot(dx2, dy2)
+	cross := dx0*dy2 - dy0*dx2
+	if !(radius > 0) || len0 == 0 || len2 == 0 ||
+		math.Abs(cross) <= 1e-9*len0*len2 {
+		return
+	}
+	dx0, dy0 = dx0/len0, dy0/len0
+	dx2, dy2 = dx2/len2, dy2/len2
+
+	// The circle touches both lines, so its center is on the bisector of the
+	// corner.
+	halfAngle := math.Acos(math.Max(-1, math.Min(1, dx0*dx2+dy0*dy2))) / 2
+	tangent := radius / math.Tan(halfAngle)
+	bx, by := dx0+dx2, dy0+dy2
+	bLen := math.Hypot(bx, by)
+	centerDist := radius / math.Sin(halfAngle)
+	center = point{p1.X + bx/bLen*centerDist, p1.Y + by/bLen*centerDist}
+	start = math.Atan2(p1.Y+dy0*tangent-center.Y, p1.X+dx0*tangent-center.X)
+	end = math.Atan2(p1.Y+dy2*tangent-center.Y, p1.X+dx2*tangent-center.X)
+	return center, start, end, cross < 0, true
+}
@@ -0,0 +1,333 @@
+package gogui
+
+import (
+	"unicode"
+)
+
+// The software renderer uses a built-in 5x7 bitmap font. Glyphs are drawn as
+// filled rectangles, so they scale to any font size.
+//
+// Glyph coordinates are measured in font units, where one unit is a tenth of
+// the font size. Every glyph is fontAdvance units wide.
+const (
+	fontAscent     = 8
+	fontDescent    = 3
+	fontAdvance    = 6
+	fontCapRows    = 7
+	fontXHeight    = 5
+	fontUnitsPerEm = 10
+)
+
+// A fontRun is a horizontal run of filled cells in a glyph. Rows are numbered
+// from the top of the capitals, so rows 7 and 8 are below the baseline.
+type fontRun struct {
+	row   int
+	start int
+	end   int
+}
+
+var fontGlyphs = map[rune][]fontRun{}
+var fontMissingGlyph []fontRun
+
+func init() {
+	for r, rows := range fontBitmaps {
+		fontGlyphs[r] = parseFontBitmap(rows)
+	}
+	fontMissingGlyph = parseFontBitmap([]string{"#####", "#...#", "#...#",
+		"#...#", "#...#", "#...#", "#####"})
+}
+
+// fontGlyph returns the runs for a rune. Control characters and spaces have
+// no runs.
+func fontGlyph(r rune) []fontRun {
+	if g, ok := fontGlyphs[r]; ok {
+		return g
+	} else if unicode.IsSpace(r) || unicode.IsControl(r) {
+		return nil
+	}
+	return fontMissingGlyph
+}
+
+// fontRuneAdvance returns the number of font units the pen moves after drawing
+// a rune.
+func fontRuneAdvance(r rune) float64 {
+	if unicode.IsControl(r) || unicode.Is(unicode.Mn, r) ||
+		unicode.Is(unicode.Me, r) {
+		return 0
+	}
+	return fontAdvance
+}
+
+// fontTextPolygons generates the outline of a string whose top-left corner is
+// at the origin, in font units.
+func fontTextPolygons(text string) []polygon {
+	res := []polygon{}
+	var x float64
+	for _, r := range text {
+		for _, run := range fontGlyph(r) {
+			top := float64(fontAscent - fontCapRows + run.row)
+			left := x + float64(run.start)
+			right := x + float64(run.end)
+			res = append(res, polygon{{left, top}, {right, top},
+				{right, top + 1}, {left, top + 1}})
+		}
+		x += fontRuneAdvance(r)
+	}
+	return res
+}
+
+// fontTextOutlines generates the outlines of a string whose top-left corner is
+// at the origin, in font units. Cells which touch are part of the same outline,
+// so stroking the outlines only draws the edges of each glyph.
+func fontTextOutlines(text string) []polyline {
+	res := []polyline{}
+	var x float64
+	for _, r := range text {
+		res = append(res, fontGlyphOutlines(fontGlyph(r), x)...)
+		x += fontRuneAdvance(r)
+	}
+	return res
+}
+
+// fontGlyphOutlines traces the cells of a glyph which is moved right by x.
+// Each outline goes clockwise around filled cells and counter-clockwise
+// around holes.
+func fontGlyphOutlines(runs []fontRun, x float64) []polyline {
+	type corner struct {
+		x, y int
+	}
+	filled := map[corner]bool{}
+	for _, run := range runs {
+		for col := run.start; col < run.end; col++ {
+			filled[corner{col, run.row}] = true
+		}
+	}
+
+	// Every edge between a filled cell and an empty one keeps the filled cell
+	// on its right.
+	type edge struct {
+		from, to corner
+	}
+	var edges []edge
+	outgoing := map[corner][]int{}
+	addEdge := func(from, to corner) {
+		outgoing[from] = append(outgoing[from], len(edges))
+		edges = append(edges, edge{from, to})
+	}
+	for _, run := range runs {
+		for col := run.start; col < run.end; col++ {
+			c, r := col, run.row
+			if !filled[corner{c, r - 1}] {
+				addEdge(corner{c, r}, corner{c + 1, r})
+			}
+			if !filled[corner{c + 1, r}] {
+				addEdge(corner{c + 1, r}, corner{c + 1, r + 1})
+			}
+			if !filled[corner{c, r + 1}] {
+				addEdge(corner{c + 1, r + 1}, corner{c, r + 1})
+			}
+			if !filled[corner{c - 1, r}] {
+				addEdge(corner{c, r + 1}, corner{c, r})
+			}
+		}
+	}
+
+	top := float64(fontAscent - fontCapRows)
+	used := make([]bool, len(edges))
+	var res []polyline
+	for start := range edges {
+		var points []point
+		for e := start; e >= 0 && !used[e]; {
+			used[e] = true
+			from, to := edges[e].from, edges[e].to
+			points = append(points, point{x + float64(from.x),
+				top + float64(from.y)})
+
+			// Where two cells touch at a corner, turn right so that each
+			// cell gets its own corner.
+			right := corner{to.x - (to.y - from.y), to.y + (to.x - from.x)}
+			e = -1
+			for _, next := range outgoing[to] {
+				if !used[next] && (e < 0 || edges[next].to == right) {
+					e = next
+				}
+			}
+		}
+		if len(points) > 0 {
+			res = append(res, polyline{points: fontCorners(points), closed: true})
+		}
+	}
+	return res
+}
+
+// fontCorners removes the points of a closed outline where it goes straight.
+func fontCorners(points []point) []point {
+	res := make([]point, 0, len(points))
+	for i, p := range points {
+		prev := points[(i+len(points)-1)%len(points)]
+		next := points[(i+1)%len(points)]
+		if (p.X-prev.X)*(next.Y-p.Y) != (p.Y-prev.Y)*(next.X-p.X) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// fontTextWidth returns the width of a string in font units.
+func fontTextWidth(text string) float64 {
+	var res float64
+	for _, r := range text {
+		res += fontRuneAdvance(r)
+	}
+	return res
+}
+
+// softFontName is the name of the built-in font, which is the only font that
+// the software renderer has.
+const softFontName = "Builtin"
+
+// softMeasurer is a textMeasurer which uses the software renderer's built-in
+// font, for when no better measurements are available.
+type softMeasurer struct{}
+
+func (softMeasurer) fontMetrics(f Font) FontMetrics {
+	return softFontMetrics(f)
+}
+
+func (softMeasurer) textSize(text string, f Font) (float64, float64) {
+	return softTextSize(text, f)
+}
+
+// softFontMetrics returns the metrics of the built-in font at a font's size.
+func softFontMetrics(f Font) FontMetrics {
+	scale := f.Size / fontUnitsPerEm
+	return FontMetrics{
+		Ascent:             fontAscent * scale,
+		Descent:            fontDescent * scale,
+		XHeight:            fontXHeight * scale,
+		CapHeight:          fontCapRows * scale,
+		UnderlinePosition:  1.5 * scale,
+		UnderlineThickness: 1 * scale,
+	}
+}
+
+// softTextSize measures text with the built-in font at a font's size.
+func softTextSize(text string, f Font) (float64, float64) {
+	scale := f.Size / fontUnitsPerEm
+	return fontTextWidth(text) * scale, (fontAscent + fontDescent) * scale
+}
+
+func parseFontBitmap(rows []string) []fontRun {
+	res := []fontRun{}
+	for y, row := range rows {
+		start := -1
+		for x := 0; x <= len(row); x++ {
+			filled := x < len(row) && row[x] == '#'
+			if filled && start < 0 {
+				start = x
+			} else if !filled && start >= 0 {
+				res = append(res, fontRun{y, start, x})
+				start = -1
+			}
+		}
+	}
+	return res
+}
+
+var fontBitmaps = map[rune][]string{
+	'!':  {"..#..", "..#..", "..#..", "..#..", "..#..", ".....", "..#.."},
+	'"':  {".#.#.", ".#.#."},
+	'#':  {".#.#.", ".#.#.", "#####", ".#.#.", "#####", ".#.#.", ".#.#."},
+	'$':  {"..#..", ".####", "#.#..", ".###.", "..#.#", "####.", "..#.."},
+	'%':  {"##...", "##..#", "...#.", "..#..", ".#...", "#..##", "...##"},
+	'&':  {".##..", "#..#.", "#.#..", ".#...", "#.#.#", "#..#.", ".##.#"},
+	'\'': {"..#..", "..#.."},
+	'(':  {"...#.", "..#..", ".#...", ".#...", ".#...", "..#..", "...#."},
+	')':  {".#...", "..#..", "...#.", "...#.", "...#.", "..#..", ".#..."},
+	'*':  {".....", "..#..", "#.#.#", ".###.", "#.#.#", "..#.."},
+	'+':  {".....", "..#..", "..#..", "#####", "..#..", "..#.."},
+	',':  {".....", ".....", ".....", ".....", ".....", "..#..", "..#..", ".#..."},
+	'-':  {".....", ".....", ".....", "#####"},
+	'.':  {".....", ".....", ".....", ".....", ".....", ".....", "..#.."},
+	'/':  {".....", "....#", "...#.", "..#..", ".#...", "#...."},
+	'0':  {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1':  {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2':  {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3':  {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4':  {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5':  {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6':  {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7':  {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8':  {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9':  {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	':':  {".....", ".....", "..#..", ".....", ".....", "..#.."},
+	';':  {".....", ".....", "..#..", ".....", ".....", "..#..", "..#..", ".#..."},
+	'<':  {"...#.", "..#..", ".#...", "#....", ".#...", "..#..", "...#."},
+	'=':  {".....", ".....", "#####", ".....", "#####"},
+	'>':  {".#...", "..#..", "...#.", "....#", "...#.", "..#..", ".#..."},
+	'?':  {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+	'@':  {".###.", "#...#", "....#", ".##.#", "#.#.#", "#.#.#", ".###."},
+	'A':  {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B':  {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C':  {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D':  {"###..", "#..#.", "#...#", "#...#", "#...#", "#..#.", "###.."},
+	'E':  {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F':  {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G':  {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H':  {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I':  {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J':  {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K':  {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L':  {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M':  {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N':  {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O':  {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P':  {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q':  {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R':  {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S':  {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T':  {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U':  {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V':  {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W':  {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X':  {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y':  {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z':  {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'[':  {".###.", ".#...", ".#...", ".#...", ".#...", ".#...", ".###."},
+	'\\': {".....", "#....", ".#...", "..#..", "...#.", "....#"},
+	']':  {".###.", "...#.", "...#.", "...#.", "...#.", "...#.", ".###."},
+	'^':  {"..#..", ".#.#.", "#...#"},
+	'_':  {".....", ".....", ".....", ".....", ".....", ".....", "#####"},
+	'`':  {".#...", "..#.."},
+	'a':  {".....", ".....", ".###.", "....#", ".####", "#...#", ".####"},
+	'b':  {"#....", "#....", "#.##.", "##..#", "#...#", "#...#", "####."},
+	'c':  {".....", ".....", ".###.", "#....", "#....", "#...#", ".###."},
+	'd':  {"....#", "....#", ".##.#", "#..##", "#...#", "#...#", ".####"},
+	'e':  {".....", ".....", ".###.", "#...#", "#####", "#....", ".###."},
+	'f':  {"..##.", ".#..#", ".#...", "###..", ".#...", ".#...", ".#..."},
+	'g':  {".....", ".....", ".####", "#...#", "#...#", "#...#", ".####", "....#", ".###."},
+	'h':  {"#....", "#....", "#.##.", "##..#", "#...#", "#...#", "#...#"},
+	'i':  {"..#..", ".....", ".##..", "..#..", "..#..", "..#..", ".###."},
+	'j':  {"...#.", ".....", "..##.", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'k':  {"#....", "#....", "#..#.", "#.#..", "##...", "#.#..", "#..#."},
+	'l':  {".##..", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'm':  {".....", ".....", "##.#.", "#.#.#", "#.#.#", "#.#.#", "#.#.#"},
+	'n':  {".....", ".....", "#.##.", "##..#", "#...#", "#...#", "#...#"},
+	'o':  {".....", ".....", ".###.", "#...#", "#...#", "#...#", ".###."},
+	'p':  {".....", ".....", "####.", "#...#", "#...#", "#...#", "####.", "#....", "#...."},
+	'q':  {".....", ".....", ".####", "#...#", "#...#", "#...#", ".####", "....#", "....#"},
+	'r':  {".....", ".....", "#.##.", "##..#", "#....", "#....", "#...."},
+	's':  {".....", ".....", ".####", "#....", ".###.", "....#", "####."},
+	't':  {".#...", ".#...", "###..", ".#...", ".#...", ".#..#", "..##."},
+	'u':  {".....", ".....", "#...#", "#...#", "#...#", "#..##", ".##.#"},
+	'v':  {".....", ".....", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'w':  {".....", ".....", "#...#", "#...#", "#.#.#", "#.#.#", ".#.#."},
+	'x':  {".....", ".....", "#...#", ".#.#.", "..#..", ".#.#.", "#...#"},
+	'y':  {".....", ".....", "#...#", "#...#", "#...#", "#...#", ".####", "....#", ".###."},
+	'z':  {".....", ".....", "#####", "...#.", "..#..", ".#...", "#####"},
+	'{':  {"...#.", "..#..", "..#..", ".#...", "..#..", "..#..", "...#."},
+	'|':  {"..#..", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'}':  {".#...", "..#..", "..#..", "...#.", "..#..", "..#..", ".#..."},
+	'~':  {".....", ".....", ".#...", "#.#.#", "...#."},
+	'…':  {".....", ".....", ".....", ".....", ".....", ".....", "#.#.#"},
+}
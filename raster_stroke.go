@@ -0,0 +1,364 @@
+package gogui
+
+import (
+	"math"
+)
+
+// A polyline is a connected list of points which may or may not be closed.
+type polyline struct {
+	points []point
+	closed bool
+}
+
+// A lineStyle holds the settings which decide the outline of a stroke.
+type lineStyle struct {
+	thickness  float64
+	cap        LineCap
+	join       LineJoin
+	miterLimit float64
+
+	// dash is a normalized dash pattern, or nil for solid lines.
+	dash      []float64
+	dashPhase float64
+}
+
+// scaled returns the style for lines which have been scaled up by a factor.
+func (l lineStyle) scaled(scale float64) lineStyle {
+	l.thickness *= scale
+	l.dashPhase *= scale
+	if l.dash != nil {
+		dash := make([]float64, len(l.dash))
+		for i, x := range l.dash {
+			dash[i] = x * scale
+		}
+		l.dash = dash
+	}
+	return l
+}
+
+// strokePolylines generates polygons which cover the outline of every
+// polyline. Dashes are only generated where the outline may overlap bounds,
+// and pixel is the length of a device pixel.
+func strokePolylines(lines []polyline, style lineStyle, bounds Rect,
+	pixel float64) []polygon {
+	radius := style.thickness / 2
+	if radius <= 0 {
+		return nil
+	}
+	if style.dash != nil {
+		// Nothing reaches further from a line than a miter or the corner of
+		// a square cap.
+		reach := radius*math.Max(style.miterLimit, math.Sqrt2) + pixel
+		bounds = Rect{bounds.X - reach, bounds.Y - reach,
+			bounds.Width + reach*2, bounds.Height + reach*2}
+		lines = dashPolylines(lines, style.dash, style.dashPhase, bounds,
+			pixel)
+	}
+	res := []polygon{}
+	for _, line := range lines {
+		points := uniquePoints(line.points, line.closed)
+		if len(points) == 0 {
+			continue
+		} else if len(points) == 1 {
+			// Like CoreGraphics, draw a dot for an empty line with caps.
+			p := points[0]
+			switch style.cap {
+			case LineCapRound:
+				res = append(res, ellipsePolygon(p.X, p.Y, radius, radius))
+			case LineCapSquare:
+				res = append(res, polygon{
+					{p.X - radius, p.Y - radius},
+					{p.X + radius, p.Y - radius},
+					{p.X + radius, p.Y + radius},
+					{p.X - radius, p.Y + radius},
+				})
+			}
+			continue
+		}
+		segCount := len(points) - 1
+		if line.closed {
+			segCount++
+		}
+		for i := 0; i < segCount; i++ {
+			p1 := points[i]
+			p2 := points[(i+1)%len(points)]
+			res = append(res, segmentPolygon(p1, p2, radius))
+		}
+		for i, p := range points {
+			if !line.closed && (i == 0 || i == len(points)-1) {
+				continue
+			}
+			prev := points[(i+len(points)-1)%len(points)]
+			next := points[(i+1)%len(points)]
+			if join := joinPolygon(prev, p, next, radius, style); join != nil {
+				res = append(res, join)
+			}
+		}
+		if !line.closed {
+			n := len(points)
+			res = append(res, capPolygons(points[1], points[0], radius,
+				style.cap)...)
+			res = append(res, capPolygons(points[n-2], points[n-1], radius,
+				style.cap)...)
+		}
+	}
+	return res
+}
+
+// uniquePoints removes points which are the same as the point before them.
+// For closed lines, the last point is also removed if it is the first point.
+func uniquePoints(points []point, closed bool) []point {
+	res := make([]point, 0, len(points))
+	for _, p := range points {
+		if len(res) == 0 || p != res[len(res)-1] {
+			res = append(res, p)
+		}
+	}
+	if closed && len(res) > 1 && res[0] == res[len(res)-1] {
+		res = res[:len(res)-1]
+	}
+	return res
+}
+
+// joinPolygon returns the polygon which fills the outside of the corner at p,
+// or nil if the corner needs nothing.
+func joinPolygon(prev, p, next point, radius float64,
+	style lineStyle) polygon {
+	d1x, d1y := unitVector(p.X-prev.X, p.Y-prev.Y)
+	d2x, d2y := unitVector(next.X-p.X, next.Y-p.Y)
+	cross := d1x*d2y - d1y*d2x
+	cos := d1x*d2x + d1y*d2y
+	if cross == 0 && cos > 0 {
+		return nil
+	} else if style.join == LineJoinRound {
+		return ellipsePolygon(p.X, p.Y, radius, radius)
+	} else if cross == 0 {
+		// The line turns all the way around, so a bevel is flat.
+		return nil
+	}
+
+	// The outer edges are on the side which the line turns away from.
+	side := -radius
+	if cross < 0 {
+		side = radius
+	}
+	o1 := point{p.X - d1y*side, p.Y + d1x*side}
+	o2 := point{p.X - d2y*side, p.Y + d2x*side}
+	if style.join == LineJoinMiter {
+		// The ratio of the miter length to the thickness is
+		// 1/sqrt((1+cos)/2), where cos is the cosine of the angle between the
+		// normals of the edges.
+		if 2/(1+cos) <= style.miterLimit*style.miterLimit {
+			scale := 1 / (1 + cos)
+			tip := point{p.X + (o1.X+o2.X-2*p.X)*scale,
+				p.Y + (o1.Y+o2.Y-2*p.Y)*scale}
+			return polygon{p, o1, tip, o2}.oriented()
+		}
+	}
+	return polygon{p, o1, o2}.oriented()
+}
+
+// capPolygons returns the polygons which cap the end of a line which goes
+// from prev to p.
+func capPolygons(prev, p point, radius float64, cap LineCap) []polygon {
+	switch cap {
+	case LineCapRound:
+		return []polygon{ellipsePolygon(p.X, p.Y, radius, radius)}
+	case LineCapSquare:
+		dx, dy := unitVector(p.X-prev.X, p.Y-prev.Y)
+		end := point{p.X + dx*radius, p.Y + dy*radius}
+		return []polygon{segmentPolygon(p, end, radius)}
+	}
+	return nil
+}
+
+// maxPatternsPerPixel is the most times that dashPolylines repeats a dash
+// pattern in the length of a pixel. Finer patterns are stretched.
+const maxPatternsPerPixel = 16
+
+// dashPolylines splits lines into the dashes of a dash pattern. Every line
+// starts at the beginning of the pattern, offset by phase.
+//
+// Dashes are only generated for the parts of lines inside of bounds, so the
+// work does not grow with the length of lines which go off the screen.
+func dashPolylines(lines []polyline, pattern []float64, phase float64,
+	bounds Rect, pixel float64) []polyline {
+	var total float64
+	for _, x := range pattern {
+		total += x
+	}
+	if !(total > 0) {
+		// The pattern was scaled down to nothing.
+		return nil
+	}
+	phase = math.Mod(phase, total)
+	if phase < 0 {
+		phase += total
+	}
+	if minTotal := pixel / maxPatternsPerPixel; total < minTotal {
+		// A pattern this fine only shows as the fraction of each pixel that
+		// it covers, which stays the same when it is stretched.
+		scale := minTotal / total
+		stretched := make([]float64, len(pattern))
+		for i, x := range pattern {
+			stretched[i] = x * scale
+		}
+		pattern, phase, total = stretched, phase*scale, minTotal
+	}
+
+	var res []polyline
+	for _, line := range lines {
+		points := line.points
+		if len(points) == 0 {
+			continue
+		} else if line.closed {
+			points = append(append([]point{}, points...), points[0])
+		}
+		w := &dashWalker{pattern: pattern, total: total,
+			remaining: pattern[0]}
+		w.skip(phase)
+		if len(points) == 1 {
+			if w.on() {
+				res = append(res, polyline{points: points})
+			}
+			continue
+		}
+
+		var dash []point
+		for i := 0; i+1 < len(points); i++ {
+			a, b := points[i], points[i+1]
+			length := math.Hypot(b.X-a.X, b.Y-a.Y)
+			t0, t1 := clipSegment(a, b, bounds)
+			if !(t0 < t1) {
+				if dash != nil {
+					res = append(res, polyline{points: dash})
+					dash = nil
+				}
+				w.skip(length)
+				continue
+			}
+			w.skip(length * t0)
+			if w.on() && dash == nil {
+				dash = []point{lerpPoint(a, b, t0)}
+			}
+			pos, end := length*t0, length*t1
+			for end-pos > w.remaining {
+				pos += w.remaining
+				p := lerpPoint(a, b, pos/length)
+				if w.on() {
+					res = append(res, polyline{points: append(dash, p)})
+					dash = nil
+				} else {
+					dash = []point{p}
+				}
+				w.next()
+			}
+			w.remaining -= end - pos
+			if w.on() {
+				dash = append(dash, lerpPoint(a, b, t1))
+			}
+			if t1 < 1 {
+				// The line leaves the bounds, so the dash ends here.
+				if dash != nil {
+					res = append(res, polyline{points: dash})
+					dash = nil
+				}
+				w.skip(length * (1 - t1))
+			}
+		}
+		if dash != nil {
+			res = append(res, polyline{points: dash})
+		}
+	}
+	return res
+}
+
+// A dashWalker keeps track of where a line is in a dash pattern.
+type dashWalker struct {
+	pattern []float64
+	total   float64
+
+	// idx is the current entry of the pattern, and remaining is how much of
+	// it is left.
+	idx       int
+	remaining float64
+}
+
+// next moves to the start of the next entry of the pattern.
+func (d *dashWalker) next() {
+	d.idx = (d.idx + 1) % len(d.pattern)
+	d.remaining = d.pattern[d.idx]
+}
+
+// on returns true if the current entry is a dash rather than a gap.
+func (d *dashWalker) on() bool {
+	return d.idx%2 == 0
+}
+
+// skip moves along the pattern by a distance without making any dashes.
+func (d *dashWalker) skip(dist float64) {
+	if dist < d.remaining {
+		d.remaining -= dist
+		return
+	}
+	dist = math.Mod(dist-d.remaining, d.total)
+	d.next()
+	for i := 0; i < len(d.pattern) && dist >= d.remaining; i++ {
+		dist -= d.remaining
+		d.next()
+	}
+	d.remaining = math.Max(0, d.remaining-dist)
+}
+
+// clipSegment finds the part of the segment from a to b which is inside of a
+// rectangle, as the range [t0, t1] of the fraction of the way from a to b.
+// The range is empty if the segment misses the rectangle.
+func clipSegment(a, b point, r Rect) (t0, t1 float64) {
+	t0, t1 = 0, 1
+	dx, dy := b.X-a.X, b.Y-a.Y
+	for _, edge := range [][2]float64{{-dx, a.X - r.X},
+		{dx, r.X + r.Width - a.X}, {-dy, a.Y - r.Y},
+		{dy, r.Y + r.Height - a.Y}} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return 1, 0
+			}
+		} else if t := q / p; p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+	}
+	return t0, t1
+}
+
+// lerpPoint returns the point a fraction t of the way from a to b.
+func lerpPoint(a, b point, t float64) point {
+	return point{a.X + (b.X-a.X)*t, a.Y + (b.Y-a.Y)*t}
+}
+
+// segmentPolygon returns a rectangle which covers a line segment with a
+// given half-thickness.
+func segmentPolygon(p1, p2 point, radius float64) polygon {
+	dx, dy := p2.X-p1.X, p2.Y-p1.Y
+	length := math.Sqrt(dx*dx + dy*dy)
+	if length == 0 {
+		return nil
+	}
+	nx, ny := -dy*radius/length, dx*radius/length
+	return polygon{
+		{p1.X + nx, p1.Y + ny},
+		{p2.X + nx, p2.Y + ny},
+		{p2.X - nx, p2.Y - ny},
+		{p1.X - nx, p1.Y - ny},
+	}.oriented()
+}
+
+func unitVector(x, y float64) (float64, float64) {
+	length := math.Hypot(x, y)
+	if length == 0 {
+		return 0, 0
+	}
+	return x / length, y / length
+}
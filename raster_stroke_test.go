@@ -0,0 +1,61 @@
+package gogui
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestLineDash(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	ctx := NewImageContext(img)
+	ctx.SetLineCap(LineCapButt)
+	ctx.SetLineDash([]float64{4}, 2)
+	ctx.SetThickness(2)
+	ctx.BeginPath()
+	ctx.MoveTo(0, 10)
+	ctx.LineTo(40, 10)
+	ctx.StrokePath()
+
+	// With a phase of 2, the line is on for [0, 2), off for [2, 6), and so on.
+	want := []bool{true, true, false, false, false, false, true, true, true,
+		true, false}
+	for x, w := range want {
+		if on := img.RGBAAt(x, 10).A == 0xff; on != w {
+			t.Error(x, img.RGBAAt(x, 10))
+		}
+	}
+}
+
+func TestLineDashBounds(t *testing.T) {
+	lines := []polyline{{points: []point{{-1e6, 0}, {1e6, 0}}}}
+	bounds := Rect{0, -10, 100, 20}
+	res := dashPolylines(lines, []float64{1, 1}, 0, bounds, 1)
+	if len(res) != 50 {
+		t.Error("expected 50 dashes but got", len(res))
+	}
+	if p := res[0].points; p[0].X != 0 || math.Abs(p[1].X-1) > 1e-6 {
+		t.Error("the first dash is", p)
+	}
+	res = dashPolylines(lines, []float64{1e-300, 1e-300}, 0, bounds, 1)
+	if n := len(res); n < 100*maxPatternsPerPixel ||
+		n > 100*maxPatternsPerPixel+1 {
+		t.Error("expected fine dashes to be stretched but got", n)
+	}
+
+	// Fine dashes still only cover part of every pixel.
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	ctx := NewImageContext(img)
+	ctx.SetLineCap(LineCapButt)
+	ctx.SetLineDash([]float64{1e-9, 3e-9}, 0)
+	ctx.SetThickness(2)
+	ctx.BeginPath()
+	ctx.MoveTo(-1e9, 10)
+	ctx.LineTo(1e9, 10)
+	ctx.StrokePath()
+	for x := 0; x < 20; x++ {
+		if a := img.RGBAAt(x, 10).A; a < 0x3c || a > 0x44 {
+			t.Error("unexpected alpha at", x, a)
+		}
+	}
+}
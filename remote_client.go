@@ -0,0 +1,257 @@
+package gogui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// The remote driver sends the app's windows to a display server, such as
+// cmd/gogui-display, at the address in the GOGUI_DISPLAY environment variable.
+// It is available whenever GOGUI_DISPLAY is set.
+//
+// An address is "unix:PATH" for a Unix socket or "tcp:HOST:PORT" for a TCP
+// connection. Without a prefix, addresses which contain a slash are Unix
+// socket paths and other addresses are TCP addresses.
+//
+// Draw handlers run in the app and are replayed by the display server. Text is
+// measured with the built-in font metrics, so text drawn by a display which
+// uses native fonts may be a little wider or narrower than TextSize says.
+func init() {
+	RegisterDriver("remote", newSoftApp(newRemoteHost()))
+}
+
+// A remoteHost presents windows on a display server.
+type remoteHost struct {
+	app      *softApp
+	conn     net.Conn
+	outgoing chan []byte
+
+	windowIDs map[*softWindow]uint32
+	windows   map[uint32]*softWindow
+	nextID    uint32
+	front     *softWindow
+
+	// lost is set on the main goroutine once the connection fails.
+	lost bool
+}
+
+func newRemoteHost() *remoteHost {
+	return &remoteHost{
+		windowIDs: map[*softWindow]uint32{},
+		windows:   map[uint32]*softWindow{},
+	}
+}
+
+func (r *remoteHost) available() bool {
+	return os.Getenv("GOGUI_DISPLAY") != ""
+}
+
+func (r *remoteHost) start(a *softApp, info *AppInfo) error {
+	r.app = a
+	addr := os.Getenv("GOGUI_DISPLAY")
+	if addr == "" {
+		return errors.New("GOGUI_DISPLAY is not set")
+	}
+	conn, err := remoteDial(addr)
+	if err != nil {
+		return err
+	}
+	name := ""
+	if info != nil {
+		name = info.Name
+	}
+	if _, err := conn.Write(remoteHello(name)); err != nil {
+		conn.Close()
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	version, screen, err := readRemoteWelcome(reader)
+	if err != nil {
+		conn.Close()
+		return err
+	} else if version != remoteVersion {
+		conn.Close()
+		return errors.New("display does not support protocol version " +
+			fmt.Sprint(remoteVersion))
+	}
+	r.conn = conn
+	a.screen = screen
+
+	r.outgoing = make(chan []byte, 256)
+	go func() {
+		var failed bool
+		for msg := range r.outgoing {
+			if failed {
+				// Keep draining so that send never blocks on a dead
+				// connection before the main goroutine hears about it.
+				continue
+			}
+			if _, err := conn.Write(msg); err != nil {
+				failed = true
+				conn.Close()
+				a.RunOnMain(func() {
+					r.connectionLost(err)
+				})
+			}
+		}
+	}()
+	go r.readMessages(reader)
+	return nil
+}
+
+func (r *remoteHost) showWindow(w *softWindow) {
+	r.sendWindow(w)
+}
+
+func (r *remoteHost) hideWindow(w *softWindow) {
+	r.sendWindow(w)
+}
+
+func (r *remoteHost) updateWindow(w *softWindow) {
+	r.sendWindow(w)
+}
+
+func (r *remoteHost) drawWindow(w *softWindow) {
+}
+
+func (r *remoteHost) paintWindow(w *softWindow) {
+	msg := newRemoteWriter(remoteMsgDraw)
+	msg.putUint32(r.windowID(w))
+	msg.putUint32(uint32(len(w.widgets)))
+	for _, widget := range w.widgets {
+		c := widget.(*softCanvas)
+		rec := newDrawRecorder(softMeasurer{})
+		if c.handler != nil {
+			c.handler(rec)
+		}
+		msg.putRect(c.frame)
+		msg.putCommands(rec.commands)
+	}
+	r.send(msg)
+}
+
+// sendWindow sends the state of a window, and brings the front window to the
+// front on the display if it changed.
+func (r *remoteHost) sendWindow(w *softWindow) {
+	msg := newRemoteWriter(remoteMsgWindow)
+	msg.putUint32(r.windowID(w))
+	msg.putString(w.title)
+	msg.putRect(w.frame)
+	if w.showing {
+		msg.putUint8(1)
+	} else {
+		msg.putUint8(0)
+	}
+	r.send(msg)
+
+	var front *softWindow
+	if len(r.app.windows) > 0 {
+		front = r.app.windows[len(r.app.windows)-1]
+	}
+	if front != r.front && front != nil {
+		msg := newRemoteWriter(remoteMsgFocus)
+		msg.putUint32(r.windowID(front))
+		r.send(msg)
+	}
+	r.front = front
+}
+
+func (r *remoteHost) windowID(w *softWindow) uint32 {
+	if id, ok := r.windowIDs[w]; ok {
+		return id
+	}
+	r.nextID++
+	r.windowIDs[w] = r.nextID
+	r.windows[r.nextID] = w
+	return r.nextID
+}
+
+// send queues a message for the display. It blocks if the display falls too
+// far behind, and it drops the message if the connection was lost.
+func (r *remoteHost) send(msg *remoteWriter) {
+	if r.lost {
+		return
+	}
+	r.outgoing <- msg.bytes()
+}
+
+// readMessages reads events from the display until the connection fails.
+func (r *remoteHost) readMessages(reader *bufio.Reader) {
+	for {
+		msgType, body, err := readRemoteMessage(reader)
+		if err != nil {
+			r.app.RunOnMain(func() {
+				r.connectionLost(err)
+			})
+			return
+		}
+		id := body.uint32()
+		var handler func(w *softWindow)
+		switch msgType {
+		case remoteMsgMouse:
+			eventType := int(body.uint8())
+			x, y := body.float(), body.float()
+			if eventType > mouseEventUp {
+				continue
+			}
+			handler = func(w *softWindow) {
+				w.mouseEvent(eventType, x, y)
+			}
+		case remoteMsgKey:
+			kind := body.uint8()
+			keyCode := int(int32(body.uint32()))
+			charCode := int(int32(body.uint32()))
+			evt := makeKeyEvent(keyCode, charCode, int(body.uint8()))
+			handler = func(w *softWindow) {
+				var h KeyHandler
+				switch kind {
+				case remoteKeyDown:
+					h = w.KeyDownHandler()
+				case remoteKeyPress:
+					h = w.KeyPressHandler()
+				case remoteKeyUp:
+					h = w.KeyUpHandler()
+				}
+				if h != nil {
+					h(evt)
+				}
+			}
+		case remoteMsgClose:
+			handler = func(w *softWindow) {
+				w.userClosed()
+			}
+		case remoteMsgFrame:
+			frame := body.rect()
+			handler = func(w *softWindow) {
+				w.frame = frame
+			}
+		default:
+			continue
+		}
+		if body.failed {
+			continue
+		}
+		r.app.RunOnMain(func() {
+			if w := r.windows[id]; w != nil && w.showing {
+				handler(w)
+			}
+		})
+	}
+}
+
+// connectionLost closes every window as though the user had closed them,
+// since they can no longer be seen.
+func (r *remoteHost) connectionLost(err error) {
+	if r.lost {
+		return
+	}
+	r.lost = true
+	close(r.outgoing)
+	fmt.Fprintln(os.Stderr, "gogui: lost connection to display:", err)
+	for len(r.app.windows) > 0 {
+		r.app.windows[len(r.app.windows)-1].userClosed()
+	}
+}
@@ -0,0 +1,313 @@
+package gogui
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strings"
+)
+
+// The remote display protocol carries windows and drawing from a client
+// process to a display server, and carries input events back.
+//
+// Integers are big endian, numbers are IEEE 754 float64s, and strings are a
+// uint32 length followed by UTF-8 bytes. A rectangle is four numbers: X, Y,
+// width, and height.
+//
+// A connection starts with the client sending remoteMagic, a uint16 version,
+// and the app's name. The server answers with remoteMagic, the version which
+// both sides will speak (0 if there is none), and the rectangle of its screen.
+// Version 2 widened the argument count of drawing commands.
+//
+// After that, each side sends messages made of a uint32 length, a message
+// type byte, and length-1 bytes of body. Messages with an unknown type are
+// ignored, so a newer peer may send messages which an older one skips.
+const (
+	remoteMagic   = "GOGUIDSP"
+	remoteVersion = 2
+
+	// remoteMaxMessage bounds the size of a message, so that a bad length
+	// cannot make a peer allocate huge buffers.
+	remoteMaxMessage = 64 << 20
+)
+
+// Messages from the client to the server. The body of each message starts
+// with a uint32 window ID which the client chooses.
+const (
+	// remoteMsgWindow creates or updates a window. The window ID is followed
+	// by the title, the frame, and a byte which is 1 if the window is showing.
+	remoteMsgWindow = 1
+
+	// remoteMsgFocus brings a window to the front.
+	remoteMsgFocus = 2
+
+	// remoteMsgDraw sets the canvases of a window and what they draw. The
+	// window ID is followed by a uint32 canvas count. Each canvas is a frame,
+	// a uint32 command count, and the commands. A command is a drawOp byte,
+	// a uint32 argument count (a byte in version 1), the arguments, and a
+	// string which is empty unless the method takes one.
+	remoteMsgDraw = 3
+)
+
+// Messages from the server to the client. Like the messages from the client,
+// each body starts with a window ID.
+const (
+	// remoteMsgMouse reports a mouse event. The window ID is followed by an
+	// event type byte and the coordinates.
+	remoteMsgMouse = 16
+
+	// remoteMsgKey reports a key event. The window ID is followed by a byte
+	// which is remoteKeyDown, remoteKeyPress, or remoteKeyUp, an int32 key
+	// code, an int32 char code, and a byte of key flags.
+	remoteMsgKey = 17
+
+	// remoteMsgClose reports that the user closed a window.
+	remoteMsgClose = 18
+
+	// remoteMsgFrame reports that the user moved or resized a window. The
+	// window ID is followed by the new frame.
+	remoteMsgFrame = 19
+)
+
+const (
+	remoteKeyDown = iota
+	remoteKeyPress
+	remoteKeyUp
+)
+
+// remoteDial connects to a display address.
+func remoteDial(addr string) (net.Conn, error) {
+	network, address := remoteNetwork(addr)
+	return net.Dial(network, address)
+}
+
+// remoteNetwork splits a display address into a network and an address for
+// the net package. A display address is either "unix:PATH" or
+// "tcp:HOST:PORT". Addresses without either prefix are Unix socket paths if
+// they contain a slash and TCP addresses otherwise.
+func remoteNetwork(addr string) (network, address string) {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		return "unix", addr[5:]
+	case strings.HasPrefix(addr, "tcp:"):
+		return "tcp", addr[4:]
+	case strings.Contains(addr, "/"):
+		return "unix", addr
+	}
+	return "tcp", addr
+}
+
+// A remoteWriter builds a message.
+type remoteWriter struct {
+	data []byte
+}
+
+// newRemoteWriter starts a message of the given type. The length is filled in
+// by bytes.
+func newRemoteWriter(msgType byte) *remoteWriter {
+	return &remoteWriter{data: []byte{0, 0, 0, 0, msgType}}
+}
+
+func (w *remoteWriter) putUint8(x uint8) {
+	w.data = append(w.data, x)
+}
+
+func (w *remoteWriter) putUint16(x uint16) {
+	w.data = append(w.data, byte(x>>8), byte(x))
+}
+
+func (w *remoteWriter) putUint32(x uint32) {
+	w.data = append(w.data, byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+}
+
+func (w *remoteWriter) putFloat(x float64) {
+	bits := math.Float64bits(x)
+	w.putUint32(uint32(bits >> 32))
+	w.putUint32(uint32(bits))
+}
+
+func (w *remoteWriter) putString(s string) {
+	w.putUint32(uint32(len(s)))
+	w.data = append(w.data, s...)
+}
+
+func (w *remoteWriter) putRect(r Rect) {
+	w.putFloat(r.X)
+	w.putFloat(r.Y)
+	w.putFloat(r.Width)
+	w.putFloat(r.Height)
+}
+
+func (w *remoteWriter) putCommands(commands []drawCommand) {
+	w.putUint32(uint32(len(commands)))
+	for _, c := range commands {
+		w.putUint8(uint8(c.op))
+		w.putUint32(uint32(len(c.args)))
+		for _, x := range c.args {
+			w.putFloat(x)
+		}
+		w.putString(c.text)
+	}
+}
+
+// bytes returns the finished message.
+func (w *remoteWriter) bytes() []byte {
+	binary.BigEndian.PutUint32(w.data, uint32(len(w.data)-4))
+	return w.data
+}
+
+// A remoteReader decodes the body of a message. Reading past the end of the
+// body sets failed and returns zero values.
+type remoteReader struct {
+	data   []byte
+	failed bool
+}
+
+func (r *remoteReader) take(n int) []byte {
+	if r.failed || n > len(r.data) {
+		r.failed = true
+		return make([]byte, n)
+	}
+	res := r.data[:n]
+	r.data = r.data[n:]
+	return res
+}
+
+func (r *remoteReader) uint8() uint8 {
+	return r.take(1)[0]
+}
+
+func (r *remoteReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.take(4))
+}
+
+func (r *remoteReader) float() float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(r.take(8)))
+}
+
+func (r *remoteReader) string() string {
+	n := r.uint32()
+	if int(n) < 0 || int(n) > len(r.data) {
+		r.failed = true
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+func (r *remoteReader) rect() Rect {
+	return Rect{r.float(), r.float(), r.float(), r.float()}
+}
+
+func (r *remoteReader) commands(version uint16) []drawCommand {
+	count := r.uint32()
+	// Each command takes at least six bytes, which bounds the count.
+	if int(count) < 0 || int(count) > len(r.data)/6 {
+		r.failed = true
+		return nil
+	}
+	res := make([]drawCommand, 0, count)
+	for i := 0; i < int(count) && !r.failed; i++ {
+		op := drawOp(r.uint8())
+		var numArgs uint32
+		if version < 2 {
+			numArgs = uint32(r.uint8())
+		} else {
+			numArgs = r.uint32()
+		}
+		if int(numArgs) < 0 || int(numArgs) > len(r.data)/8 {
+			r.failed = true
+			return nil
+		}
+		args := make([]float64, numArgs)
+		for j := range args {
+			args[j] = r.float()
+		}
+		res = append(res, drawCommand{op, args, r.string()})
+	}
+	return res
+}
+
+// readRemoteMessage reads the next message from a connection.
+func readRemoteMessage(r *bufio.Reader) (msgType byte, body *remoteReader,
+	err error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size == 0 || size > remoteMaxMessage {
+		return 0, nil, errors.New("invalid message size")
+	}
+	data := make([]byte, size-1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[4], &remoteReader{data: data}, nil
+}
+
+// remoteHello is the handshake which the client sends.
+func remoteHello(name string) []byte {
+	w := &remoteWriter{}
+	w.data = append(w.data, remoteMagic...)
+	w.putUint16(remoteVersion)
+	w.putString(name)
+	return w.data
+}
+
+// remoteWelcome is the handshake which the server sends in reply.
+func remoteWelcome(version uint16, screen Rect) []byte {
+	w := &remoteWriter{}
+	w.data = append(w.data, remoteMagic...)
+	w.putUint16(version)
+	w.putRect(screen)
+	return w.data
+}
+
+// readRemoteHello reads the handshake which the client sends.
+func readRemoteHello(r io.Reader) (version uint16, name string, err error) {
+	version, err = readRemoteMagic(r)
+	if err != nil {
+		return 0, "", err
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return 0, "", err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > 1024 {
+		return 0, "", errors.New("app name is too long")
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, "", err
+	}
+	return version, string(data), nil
+}
+
+// readRemoteWelcome reads the handshake which the server sends in reply.
+func readRemoteWelcome(r io.Reader) (version uint16, screen Rect, err error) {
+	version, err = readRemoteMagic(r)
+	if err != nil {
+		return 0, Rect{}, err
+	}
+	data := make([]byte, 32)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, Rect{}, err
+	}
+	return version, (&remoteReader{data: data}).rect(), nil
+}
+
+// readRemoteMagic reads the magic and version which start either handshake.
+func readRemoteMagic(r io.Reader) (uint16, error) {
+	var header [len(remoteMagic) + 2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	if string(header[:len(remoteMagic)]) != remoteMagic {
+		return 0, errors.New("not a gogui display connection")
+	}
+	return binary.BigEndian.Uint16(header[len(remoteMagic):]), nil
+}
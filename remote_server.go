@@ -0,0 +1,357 @@
+package gogui
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+)
+
+// ServeDisplay makes this app a display server for apps which use the remote
+// driver. It listens on an address like those in GOGUI_DISPLAY, then accepts
+// connections in the background and shows each app's windows with the current
+// driver. It returns an error if it cannot listen on the address.
+//
+// ServeDisplay may be called before Main, but windows are only shown once the
+// main loop is running.
+func ServeDisplay(addr string) error {
+	listener, err := listenDisplay(addr)
+	if err != nil {
+		return err
+	}
+	go serveDisplay(listener, packageDriver{})
+	return nil
+}
+
+// listenDisplay listens on an address like those in GOGUI_DISPLAY.
+func listenDisplay(addr string) (net.Listener, error) {
+	network, address := remoteNetwork(addr)
+	if network == "unix" {
+		// Remove a socket which was left behind by an earlier server.
+		if info, err := os.Lstat(address); err == nil &&
+			info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// serveDisplay accepts connections until the listener is closed, and shows
+// the windows of each app with a driver.
+func serveDisplay(listener net.Listener, d displayDriver) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go serveDisplayConn(conn, d)
+	}
+}
+
+// A displayDriver is the part of a Driver which a display server uses.
+type displayDriver interface {
+	NewCanvas(r Rect) (Canvas, error)
+	NewWindow(r Rect) (Window, error)
+	RunOnMain(f func())
+}
+
+// packageDriver is a displayDriver which calls the package-level functions,
+// so that it uses whichever driver the app chooses.
+type packageDriver struct{}
+
+func (packageDriver) NewCanvas(r Rect) (Canvas, error) {
+	return NewCanvas(r)
+}
+
+func (packageDriver) NewWindow(r Rect) (Window, error) {
+	return NewWindow(r)
+}
+
+func (packageDriver) RunOnMain(f func()) {
+	RunOnMain(f)
+}
+
+func (packageDriver) screenBounds() Rect {
+	d, err := chooseDriver("")
+	if err != nil {
+		return displayScreen(nil)
+	}
+	return displayScreen(d)
+}
+
+// A screenDriver is a Driver which knows where its screen is.
+type screenDriver interface {
+	// screenBounds returns the rectangle of the screen which windows are
+	// shown on. It is called on the main goroutine.
+	screenBounds() Rect
+}
+
+// A displayClient is an app which is connected to a display server.
+type displayClient struct {
+	driver   displayDriver
+	conn     net.Conn
+	outgoing chan []byte
+	windows  map[uint32]*displayWindow
+	closed   bool
+}
+
+// A displayWindow is a window which a displayClient created.
+type displayWindow struct {
+	client   *displayClient
+	id       uint32
+	window   Window
+	frame    Rect
+	canvases []Canvas
+	commands [][]drawCommand
+}
+
+// serveDisplayConn performs the handshake with an app and then applies its
+// messages until it disconnects.
+func serveDisplayConn(conn net.Conn, d displayDriver) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	version, _, err := readRemoteHello(reader)
+	if err != nil {
+		return
+	}
+	if version < 1 {
+		conn.Write(remoteWelcome(0, Rect{}))
+		return
+	} else if version > remoteVersion {
+		version = remoteVersion
+	}
+	screenChan := make(chan Rect, 1)
+	d.RunOnMain(func() {
+		screenChan <- displayScreen(d)
+	})
+	if _, err := conn.Write(remoteWelcome(version, <-screenChan)); err != nil {
+		return
+	}
+
+	c := &displayClient{
+		driver:   d,
+		conn:     conn,
+		outgoing: make(chan []byte, 256),
+		windows:  map[uint32]*displayWindow{},
+	}
+	go func() {
+		var failed bool
+		for msg := range c.outgoing {
+			if failed {
+				// Keep draining until the reader notices that the
+				// connection is closed.
+				continue
+			}
+			if _, err := conn.Write(msg); err != nil {
+				failed = true
+				conn.Close()
+			}
+		}
+	}()
+	for {
+		msgType, body, err := readRemoteMessage(reader)
+		if err != nil {
+			break
+		}
+		id := body.uint32()
+		var apply func(w *displayWindow)
+		switch msgType {
+		case remoteMsgWindow:
+			title := body.string()
+			frame := body.rect()
+			showing := body.uint8() != 0
+			apply = func(w *displayWindow) {
+				w.update(title, frame, showing)
+			}
+		case remoteMsgFocus:
+			apply = func(w *displayWindow) {
+				w.window.Focus()
+			}
+		case remoteMsgDraw:
+			count := body.uint32()
+			if int(count) < 0 || int(count) > len(body.data)/36 {
+				continue
+			}
+			frames := make([]Rect, count)
+			commands := make([][]drawCommand, count)
+			for i := range frames {
+				frames[i] = body.rect()
+				commands[i] = body.commands(version)
+			}
+			apply = func(w *displayWindow) {
+				w.draw(frames, commands)
+			}
+		default:
+			continue
+		}
+		if body.failed {
+			continue
+		}
+		d.RunOnMain(func() {
+			if c.closed {
+				return
+			}
+			if w := c.window(id); w != nil {
+				apply(w)
+			}
+		})
+	}
+	d.RunOnMain(func() {
+		c.closed = true
+		close(c.outgoing)
+		for _, w := range c.windows {
+			w.window.Hide()
+		}
+	})
+}
+
+// displayScreen finds the screen rectangle which is sent to apps. Drivers
+// which cannot say are assumed to have a 1280x800 screen.
+func displayScreen(d displayDriver) Rect {
+	if s, ok := d.(screenDriver); ok {
+		return s.screenBounds()
+	}
+	return Rect{0, 0, 1280, 800}
+}
+
+// drop disconnects the app after an error. Its windows are hidden once the
+// reader notices that the connection is closed.
+func (c *displayClient) drop(err error) {
+	if c.closed {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "gogui: disconnecting app from display:", err)
+	c.closed = true
+	c.conn.Close()
+}
+
+// window returns the window with an ID, creating it if necessary.
+func (c *displayClient) window(id uint32) *displayWindow {
+	if w, ok := c.windows[id]; ok {
+		return w
+	}
+	window, err := c.driver.NewWindow(Rect{0, 0, 0, 0})
+	if err != nil {
+		c.drop(err)
+		return nil
+	}
+	w := &displayWindow{client: c, id: id, window: window}
+	c.windows[id] = w
+
+	window.SetMouseDownHandler(c.mouseHandler(w, mouseEventDown))
+	window.SetMouseDragHandler(c.mouseHandler(w, mouseEventDrag))
+	window.SetMouseMoveHandler(c.mouseHandler(w, mouseEventMove))
+	window.SetMouseUpHandler(c.mouseHandler(w, mouseEventUp))
+	window.SetKeyDownHandler(c.keyHandler(w, remoteKeyDown))
+	window.SetKeyPressHandler(c.keyHandler(w, remoteKeyPress))
+	window.SetKeyUpHandler(c.keyHandler(w, remoteKeyUp))
+	window.SetCloseHandler(func() {
+		msg := newRemoteWriter(remoteMsgClose)
+		msg.putUint32(w.id)
+		c.send(w, msg)
+	})
+	return w
+}
+
+func (c *displayClient) mouseHandler(w *displayWindow,
+	eventType int) MouseHandler {
+	return func(e MouseEvent) {
+		msg := newRemoteWriter(remoteMsgMouse)
+		msg.putUint32(w.id)
+		msg.putUint8(uint8(eventType))
+		msg.putFloat(e.X)
+		msg.putFloat(e.Y)
+		c.send(w, msg)
+	}
+}
+
+func (c *displayClient) keyHandler(w *displayWindow, kind int) KeyHandler {
+	return func(e KeyEvent) {
+		flags := 0
+		if e.AltKey {
+			flags |= keyFlagAlt
+		}
+		if e.CtrlKey {
+			flags |= keyFlagCtrl
+		}
+		if e.MetaKey {
+			flags |= keyFlagMeta
+		}
+		if e.ShiftKey {
+			flags |= keyFlagShift
+		}
+		msg := newRemoteWriter(remoteMsgKey)
+		msg.putUint32(w.id)
+		msg.putUint8(uint8(kind))
+		msg.putUint32(uint32(int32(e.KeyCode)))
+		msg.putUint32(uint32(int32(e.CharCode)))
+		msg.putUint8(uint8(flags))
+		c.send(w, msg)
+	}
+}
+
+// send sends an event for a window. If the user moved the window since the app
+// last heard about it, the new frame is sent first.
+func (c *displayClient) send(w *displayWindow, msg *remoteWriter) {
+	if c.closed {
+		return
+	}
+	if f := w.window.Frame(); f != w.frame {
+		w.frame = f
+		frameMsg := newRemoteWriter(remoteMsgFrame)
+		frameMsg.putUint32(w.id)
+		frameMsg.putRect(f)
+		c.queue(frameMsg.bytes())
+	}
+	c.queue(msg.bytes())
+}
+
+func (c *displayClient) queue(data []byte) {
+	select {
+	case c.outgoing <- data:
+	default:
+		// The app is not keeping up; disconnect it.
+		c.conn.Close()
+	}
+}
+
+func (w *displayWindow) update(title string, frame Rect, showing bool) {
+	if frame != w.frame {
+		w.frame = frame
+		w.window.SetFrame(frame)
+	}
+	w.window.SetTitle(title)
+	if showing && !w.window.Showing() {
+		w.window.Show()
+	} else if !showing && w.window.Showing() {
+		w.window.Hide()
+	}
+}
+
+func (w *displayWindow) draw(frames []Rect, commands [][]drawCommand) {
+	for len(w.canvases) > len(frames) {
+		w.canvases[len(w.canvases)-1].Remove()
+		w.canvases = w.canvases[:len(w.canvases)-1]
+	}
+	w.commands = commands
+	for i, frame := range frames {
+		if i == len(w.canvases) {
+			c, err := w.client.driver.NewCanvas(frame)
+			if err != nil {
+				w.client.drop(err)
+				return
+			}
+			index := i
+			c.SetDrawHandler(func(ctx DrawContext) {
+				if index < len(w.commands) {
+					replayDrawCommands(ctx, w.commands[index])
+				}
+			})
+			w.window.Add(c)
+			w.canvases = append(w.canvases, c)
+		} else if w.canvases[i].Frame() != frame {
+			w.canvases[i].SetFrame(frame)
+		}
+		w.canvases[i].NeedsUpdate()
+	}
+}
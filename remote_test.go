@@ -0,0 +1,133 @@
+package gogui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRemoteRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gogui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := "unix:" + filepath.Join(dir, "display.sock")
+
+	// The display and the app each have a headless driver of their own, and
+	// both main loops are stopped when the test ends.
+	display := newSoftApp(nil)
+	go display.Main(&AppInfo{})
+	defer display.RunOnMain(runtime.Goexit)
+	listener, err := listenDisplay(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveDisplay(listener, display)
+
+	os.Setenv("GOGUI_DISPLAY", addr)
+	defer os.Unsetenv("GOGUI_DISPLAY")
+	host := newRemoteHost()
+	app := newSoftApp(host)
+	w, _ := app.NewWindow(Rect{10, 20, 40, 30})
+	c, _ := app.NewCanvas(Rect{0, 0, 40, 30})
+	c.SetDrawHandler(func(ctx DrawContext) {
+		ctx.SetFill(Color{1, 0, 0, 1})
+		ctx.FillRect(Rect{5, 5, 10, 10})
+	})
+	w.Add(c)
+	clicks := make(chan MouseEvent, 1)
+	w.SetMouseDownHandler(func(e MouseEvent) {
+		clicks <- e
+	})
+	w.Show()
+	go app.Main(&AppInfo{Name: "test"})
+	defer app.RunOnMain(runtime.Goexit)
+
+	// Wait for the display to show the window and draw its canvas.
+	var shown *softWindow
+	waitOnMain(t, display, func() bool {
+		for _, win := range display.windows {
+			if win.image != nil && win.image.RGBAAt(10, 10).R == 0xff {
+				shown = win
+				return true
+			}
+		}
+		return false
+	}, "the display did not draw the window")
+	if f := shown.Frame(); f != (Rect{10, 20, 40, 30}) {
+		t.Error("unexpected frame:", f)
+	}
+
+	// Input on the display goes back to the app.
+	display.RunOnMain(func() {
+		shown.mouseEvent(mouseEventDown, 3, 4)
+	})
+	select {
+	case e := <-clicks:
+		if e.X != 3 || e.Y != 4 {
+			t.Error("unexpected click:", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the app did not get the click")
+	}
+
+	// Once the app disconnects, both sides close its windows.
+	app.RunOnMain(func() {
+		host.conn.Close()
+	})
+	waitOnMain(t, app, func() bool {
+		return len(app.windows) == 0
+	}, "the app did not notice that it was disconnected")
+	waitOnMain(t, display, func() bool {
+		return len(display.windows) == 0
+	}, "the display did not hide the windows of the app")
+}
+
+func TestRemoteCommands(t *testing.T) {
+	rec := newDrawRecorder(softMeasurer{})
+	rec.SetLineDash(make([]float64, 300), 1)
+	rec.SetFillPaint(LinearGradient{Stops: make([]GradientStop, 100)})
+	w := newRemoteWriter(remoteMsgDraw)
+	w.putCommands(rec.commands)
+	r := &remoteReader{data: w.bytes()[5:]}
+	commands := r.commands(remoteVersion)
+	if r.failed || len(commands) != 2 {
+		t.Fatal("could not read the commands back")
+	}
+	if n := len(commands[0].args); n != 301 {
+		t.Error("expected 301 arguments but got", n)
+	}
+	if n := len(commands[1].args); n != 8+5*100 {
+		t.Error("expected", 8+5*100, "arguments but got", n)
+	}
+
+	// Version 1 commands count their arguments with a byte.
+	r = &remoteReader{data: []byte{0, 0, 0, 1, byte(drawOpSetLineDash), 1,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}}
+	commands = r.commands(1)
+	if r.failed || len(commands) != 1 || len(commands[0].args) != 1 {
+		t.Error("could not read a version 1 command")
+	}
+}
+
+// waitOnMain checks a condition on the main loop of an app until it is true,
+// failing the test if that takes too long.
+func waitOnMain(t *testing.T, a *softApp, cond func() bool, msg string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		res := make(chan bool, 1)
+		a.RunOnMain(func() {
+			res <- cond()
+		})
+		if <-res {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(msg)
+}
@@ -0,0 +1,150 @@
+package gogui
+
+import (
+	"math"
+)
+
+// maxShadowSigma is the largest standard deviation, in device pixels, that a
+// shadow is blurred by. Browsers limit the blur of shadows in the same way, and
+// it keeps a huge blur from needing a huge buffer.
+const maxShadowSigma = 128
+
+// boxBlurSigma is the standard deviation above which a Gaussian blur is
+// approximated by box blurs, which take the same time for any radius.
+const boxBlurSigma = 8
+
+// A shadowStyle holds the settings from SetShadow.
+type shadowStyle struct {
+	offsetX float64
+	offsetY float64
+	blur    float64
+	color   Color
+}
+
+// visible returns true if shapes cast a shadow. Like on an HTML5 canvas, a
+// shadow right under a shape is not drawn unless it is blurred.
+func (s shadowStyle) visible() bool {
+	return s.color.A > 0 && (s.blur > 0 || s.offsetX != 0 || s.offsetY != 0)
+}
+
+// validShadow returns true if the arguments to SetShadow can be used.
+func validShadow(offsetX, offsetY, blur float64) bool {
+	for _, x := range []float64{offsetX, offsetY, blur} {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
+		}
+	}
+	return blur >= 0
+}
+
+// shadowSigma returns the standard deviation of the Gaussian blur for a
+// shadow's blur setting, as HTML5 canvases define it.
+func shadowSigma(blur float64) float64 {
+	return blur / 2
+}
+
+// blurRadius returns how many pixels a Gaussian blur spreads each pixel in
+// every direction.
+func blurRadius(sigma float64) int {
+	return int(math.Ceil(sigma * 3))
+}
+
+// gaussianBlur blurs a grid of values in place, first along rows and then
+// along columns. Values outside of the grid are treated as zero.
+func gaussianBlur(values []float64, width, height int, sigma float64) {
+	if !(sigma > 0) || width == 0 || height == 0 {
+		return
+	}
+	if sigma > boxBlurSigma {
+		for _, radius := range boxRadii(sigma, 3) {
+			boxBlur(values, width, height, radius)
+		}
+		return
+	}
+	radius := blurRadius(sigma)
+	kernel := make([]float64, radius*2+1)
+	var total float64
+	for i := range kernel {
+		x := float64(i - radius)
+		kernel[i] = math.Exp(-x * x / (2 * sigma * sigma))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+
+	buf := make([]float64, width+height)
+	blurLine := func(start, stride, count int) {
+		line := buf[:count]
+		for i := range line {
+			line[i] = values[start+i*stride]
+		}
+		for i := range line {
+			var sum float64
+			for j := i - radius; j <= i+radius; j++ {
+				if j >= 0 && j < count {
+					sum += line[j] * kernel[j-i+radius]
+				}
+			}
+			values[start+i*stride] = sum
+		}
+	}
+	for y := 0; y < height; y++ {
+		blurLine(y*width, 1, width)
+	}
+	for x := 0; x < width; x++ {
+		blurLine(x, width, height)
+	}
+}
+
+// boxBlur blurs a grid of values in place with a box of a radius, first along
+// rows and then along columns. Values outside of the grid are treated as zero.
+func boxBlur(values []float64, width, height, radius int) {
+	scale := 1 / float64(radius*2+1)
+	sums := make([]float64, width+height+1)
+	blurLine := func(start, stride, count int) {
+		for i := 0; i < count; i++ {
+			sums[i+1] = sums[i] + values[start+i*stride]
+		}
+		for i := 0; i < count; i++ {
+			lo, hi := i-radius, i+radius+1
+			if lo < 0 {
+				lo = 0
+			}
+			if hi > count {
+				hi = count
+			}
+			values[start+i*stride] = (sums[hi] - sums[lo]) * scale
+		}
+	}
+	for y := 0; y < height; y++ {
+		blurLine(y*width, 1, width)
+	}
+	for x := 0; x < width; x++ {
+		blurLine(x, width, height)
+	}
+}
+
+// boxRadii returns the radii of n box blurs which, one after another, come
+// close to a Gaussian blur with a standard deviation.
+//
+// See http://www.peterkovesi.com/papers/FastGaussianSmoothing.pdf.
+func boxRadii(sigma float64, n int) []int {
+	variance := 12 * sigma * sigma
+	lower := int(math.Sqrt(variance/float64(n) + 1))
+	if lower%2 == 0 {
+		lower--
+	}
+	upper := lower + 2
+	l := float64(lower)
+	m := int(math.Floor((variance-float64(n)*(l*l+4*l+3))/(-4*l-4) + 0.5))
+	res := make([]int, n)
+	for i := range res {
+		if i < m {
+			res[i] = (lower - 1) / 2
+		} else {
+			res[i] = (upper - 1) / 2
+		}
+	}
+	return res
+}
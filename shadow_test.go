@@ -0,0 +1,56 @@
+package gogui
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestGaussianBlur(t *testing.T) {
+	for _, sigma := range []float64{3, 20} {
+		w := blurRadius(sigma)*2 + 1
+		values := make([]float64, w*w)
+		center := w/2*w + w/2
+		values[center] = 1
+		gaussianBlur(values, w, w, sigma)
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		if math.Abs(sum-1) > 0.01 {
+			t.Error("sum", sigma, sum)
+		}
+		peak := 1 / (2 * math.Pi * sigma * sigma)
+		if math.Abs(values[center]-peak) > peak/5 {
+			t.Error("peak", sigma, values[center], peak)
+		}
+	}
+}
+
+func TestShadow(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	ctx := NewImageContext(img)
+	ctx.SetShadow(10, 20, 0, Color{0, 0, 0, 1})
+	ctx.SetFill(Color{1, 0, 0, 1})
+	ctx.FillRect(Rect{5, 5, 10, 10})
+	if p := img.RGBAAt(10, 10); p.R != 255 {
+		t.Error("shape", p)
+	}
+	if p := img.RGBAAt(20, 30); p.R != 0 || p.A != 255 {
+		t.Error("shadow", p)
+	}
+	if p := img.RGBAAt(12, 20); p.A != 0 {
+		t.Error("between", p)
+	}
+}
+
+func TestShadowHugeBlur(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	ctx := NewImageContext(img)
+	ctx.SetShadow(0, 0, 1e6, Color{0, 0, 0, 1})
+	ctx.SetFill(Color{1, 0, 0, 1})
+	ctx.FillRect(Rect{-1e7, -1e7, 2e7, 2e7})
+	if p := img.RGBAAt(50, 50); p.R != 255 {
+		t.Error(p)
+	}
+}
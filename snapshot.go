@@ -0,0 +1,21 @@
+package gogui
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// snapshotBounds returns the bounds of a snapshot of a frame with scale pixels
+// per unit.
+func snapshotBounds(frame Rect, scale float64) (image.Rectangle, error) {
+	if !(scale > 0) || math.IsInf(scale, 1) {
+		return image.Rectangle{}, errors.New("invalid snapshot scale")
+	}
+	width := math.Ceil(math.Max(0, frame.Width) * scale)
+	height := math.Ceil(math.Max(0, frame.Height) * scale)
+	if width*height > math.MaxInt32/4 {
+		return image.Rectangle{}, errors.New("snapshot is too large")
+	}
+	return image.Rect(0, 0, int(width), int(height)), nil
+}
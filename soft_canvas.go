@@ -0,0 +1,84 @@
+package gogui
+
+import (
+	"image"
+	"math"
+)
+
+type softCanvas struct {
+	frame   Rect
+	handler DrawHandler
+	parent  *softWindow
+}
+
+func (c *softCanvas) DrawHandler() DrawHandler {
+	return c.handler
+}
+
+func (c *softCanvas) Frame() Rect {
+	return c.frame
+}
+
+func (c *softCanvas) NeedsUpdate() {
+	if c.parent != nil {
+		c.parent.invalidate()
+	}
+}
+
+func (c *softCanvas) Parent() Widget {
+	if c.parent == nil {
+		return nil
+	}
+	return c.parent
+}
+
+func (c *softCanvas) Remove() {
+	if c.parent == nil {
+		return
+	}
+	c.parent.removeWidget(c)
+	c.parent = nil
+}
+
+func (c *softCanvas) Snapshot() (image.Image, error) {
+	return c.SnapshotScale(1)
+}
+
+func (c *softCanvas) SnapshotScale(scale float64) (image.Image, error) {
+	bounds, err := snapshotBounds(c.frame, scale)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(bounds)
+	if c.handler != nil {
+		c.handler(newImageContext(img, ScaleMatrix(scale, scale)))
+	}
+	return img, nil
+}
+
+func (c *softCanvas) SetDrawHandler(h DrawHandler) {
+	c.handler = h
+}
+
+func (c *softCanvas) SetFrame(r Rect) {
+	c.frame = r
+	c.NeedsUpdate()
+}
+
+// draw runs the draw handler on the part of an image which the canvas covers.
+// The image has scale pixels per unit of the window.
+func (c *softCanvas) draw(img *image.RGBA, scale float64) {
+	if c.handler == nil {
+		return
+	}
+	f := c.frame
+	r := image.Rect(int(math.Floor(f.X*scale)), int(math.Floor(f.Y*scale)),
+		int(math.Ceil((f.X+f.Width)*scale)),
+		int(math.Ceil((f.Y+f.Height)*scale)))
+	sub, ok := img.SubImage(r).(*image.RGBA)
+	if !ok || sub.Rect.Empty() {
+		return
+	}
+	base := TranslationMatrix(f.X, f.Y).Concat(ScaleMatrix(scale, scale))
+	c.handler(newImageContext(sub, base))
+}
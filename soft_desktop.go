@@ -0,0 +1,215 @@
+package gogui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// softDesktopBackground is the color behind all of the windows on a desktop.
+var softDesktopBackground = color.RGBA{0x5c, 0x6a, 0x7a, 0xff}
+
+const (
+	softTitleFontSize  = 13
+	softCloseBoxInset  = 5
+	softCloseBoxLength = 12
+)
+
+// A softDesktop acts as the window server for hosts which own an entire
+// screen. It composites the showing windows of a softApp into one image and
+// routes input events to the windows beneath them.
+//
+// A window's frame is the content rectangle in screen coordinates. If the
+// desktop has title bars, they are drawn above the frame.
+type softDesktop struct {
+	app         *softApp
+	present     func(img *image.RGBA)
+	titleHeight float64
+
+	image          *image.RGBA
+	needsComposite bool
+
+	mouseWindow *softWindow
+	dragWindow  *softWindow
+	dragStart   point
+	dragFrame   point
+}
+
+// newSoftDesktop creates a desktop which calls present with the composited
+// screen after windows change. If titleHeight is 0, windows have no title
+// bars, so they can only be moved and closed by the program.
+func newSoftDesktop(app *softApp, titleHeight float64,
+	present func(img *image.RGBA)) *softDesktop {
+	return &softDesktop{app: app, present: present, titleHeight: titleHeight}
+}
+
+// invalidate schedules the screen to be composited and presented on the main
+// loop. Hosts call this whenever a window is shown, hidden, updated, or drawn.
+func (d *softDesktop) invalidate() {
+	if d.needsComposite {
+		return
+	}
+	d.needsComposite = true
+	d.app.RunOnMain(func() {
+		d.needsComposite = false
+		d.present(d.composite())
+	})
+}
+
+// composite draws every showing window, from back to front, into an image the
+// size of the screen.
+func (d *softDesktop) composite() *image.RGBA {
+	s := d.app.screen
+	bounds := image.Rect(0, 0, int(math.Ceil(s.Width)), int(math.Ceil(s.Height)))
+	if d.image == nil || d.image.Rect != bounds {
+		d.image = image.NewRGBA(bounds)
+	}
+	draw.Draw(d.image, bounds, image.NewUniform(softDesktopBackground),
+		image.ZP, draw.Src)
+	for _, w := range d.app.windows {
+		d.drawWindow(w)
+	}
+	return d.image
+}
+
+// frontWindow returns the window which receives key events, or nil if no
+// windows are showing.
+func (d *softDesktop) frontWindow() *softWindow {
+	if len(d.app.windows) == 0 {
+		return nil
+	}
+	return d.app.windows[len(d.app.windows)-1]
+}
+
+// mouse handles a mouse event at a point on the screen.
+func (d *softDesktop) mouse(eventType int, x, y float64) {
+	switch eventType {
+	case mouseEventDown:
+		w := d.windowAt(x, y)
+		if w == nil {
+			return
+		}
+		if w != d.frontWindow() {
+			w.Focus()
+		}
+		if y >= w.frame.Y {
+			d.mouseWindow = w
+			w.mouseEvent(mouseEventDown, x-w.frame.X, y-w.frame.Y)
+		} else if rectContains(d.closeBox(w), x, y) {
+			w.userClosed()
+		} else {
+			d.dragWindow = w
+			d.dragStart = point{x, y}
+			d.dragFrame = point{w.frame.X, w.frame.Y}
+		}
+	case mouseEventDrag, mouseEventUp:
+		if w := d.dragWindow; w != nil {
+			w.frame.X = d.dragFrame.X + x - d.dragStart.X
+			w.frame.Y = d.dragFrame.Y + y - d.dragStart.Y
+			d.app.updateWindow(w)
+		} else if w := d.mouseWindow; w != nil {
+			w.mouseEvent(eventType, x-w.frame.X, y-w.frame.Y)
+		}
+		if eventType == mouseEventUp {
+			d.dragWindow = nil
+			d.mouseWindow = nil
+		}
+	case mouseEventMove:
+		if w := d.windowAt(x, y); w != nil && y >= w.frame.Y {
+			w.mouseEvent(mouseEventMove, x-w.frame.X, y-w.frame.Y)
+		}
+	}
+}
+
+// windowHidden forgets about a window which is no longer showing. Hosts call
+// this from hideWindow.
+func (d *softDesktop) windowHidden(w *softWindow) {
+	if d.mouseWindow == w {
+		d.mouseWindow = nil
+	}
+	if d.dragWindow == w {
+		d.dragWindow = nil
+	}
+	d.invalidate()
+}
+
+// windowAt returns the front-most window whose content or title bar contains a
+// point.
+func (d *softDesktop) windowAt(x, y float64) *softWindow {
+	for i := len(d.app.windows) - 1; i >= 0; i-- {
+		w := d.app.windows[i]
+		if rectContains(d.outerFrame(w), x, y) {
+			return w
+		}
+	}
+	return nil
+}
+
+// outerFrame returns the frame of a window including its title bar.
+func (d *softDesktop) outerFrame(w *softWindow) Rect {
+	f := w.frame
+	return Rect{f.X, f.Y - d.titleHeight, f.Width, f.Height + d.titleHeight}
+}
+
+func (d *softDesktop) closeBox(w *softWindow) Rect {
+	return Rect{w.frame.X + softCloseBoxInset,
+		w.frame.Y - d.titleHeight + (d.titleHeight-softCloseBoxLength)/2,
+		softCloseBoxLength, softCloseBoxLength}
+}
+
+func (d *softDesktop) drawWindow(w *softWindow) {
+	if d.titleHeight > 0 {
+		ctx := newImageContext(d.image, IdentityMatrix())
+		outer := d.outerFrame(w)
+		ctx.SetFill(Color{0xdc / 255.0, 0xdc / 255.0, 0xdc / 255.0, 1})
+		ctx.FillRect(Rect{outer.X, outer.Y, outer.Width, d.titleHeight})
+		ctx.SetFill(Color{0xfc / 255.0, 0x60 / 255.0, 0x5c / 255.0, 1})
+		ctx.FillEllipse(d.closeBox(w))
+		ctx.SetFont(softTitleFontSize, "Helvetica")
+		width, height := ctx.TextSize(w.title)
+		ctx.SetFill(Color{0.2, 0.2, 0.2, 1})
+		ctx.FillText(w.title, outer.X+(outer.Width-width)/2,
+			outer.Y+(d.titleHeight-height)/2)
+	}
+
+	dest := image.Rect(0, 0, int(math.Ceil(w.frame.Width)),
+		int(math.Ceil(w.frame.Height)))
+	dest = dest.Add(image.Pt(int(math.Floor(w.frame.X+0.5)),
+		int(math.Floor(w.frame.Y+0.5))))
+	if w.image == nil {
+		draw.Draw(d.image, dest, image.NewUniform(softWindowBackground),
+			image.ZP, draw.Src)
+	} else {
+		draw.Draw(d.image, dest, w.image, w.image.Rect.Min, draw.Src)
+	}
+}
+
+// softCursorArrow is the outline of the arrow cursor, with its hot spot at the
+// origin.
+var softCursorArrow = []point{{0, 0}, {0, 16}, {4, 12}, {7, 19}, {10, 18},
+	{7, 11}, {12, 11}}
+
+// drawSoftCursor draws an arrow cursor with its tip at a point, for screens
+// which have no cursor of their own.
+func drawSoftCursor(img *image.RGBA, x, y float64) {
+	ctx := newImageContext(img, IdentityMatrix())
+	for i := 0; i < 2; i++ {
+		ctx.BeginPath()
+		for _, p := range softCursorArrow {
+			ctx.LineTo(x+p.X, y+p.Y)
+		}
+		ctx.ClosePath()
+		if i == 0 {
+			ctx.SetFill(Color{0, 0, 0, 1})
+			ctx.FillPath()
+		} else {
+			ctx.SetStroke(Color{1, 1, 1, 1})
+			ctx.StrokePath()
+		}
+	}
+}
+
+func rectContains(r Rect, x, y float64) bool {
+	return x >= r.X && y >= r.Y && x < r.X+r.Width && y < r.Y+r.Height
+}
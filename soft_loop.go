@@ -0,0 +1,53 @@
+package gogui
+
+import (
+	"sync"
+)
+
+// A softLoop runs functions on whichever goroutine calls its run method.
+// It is the software counterpart of the dispatch queue used on OS X.
+type softLoop struct {
+	lock  sync.Mutex
+	queue []func()
+	wake  chan struct{}
+}
+
+func newSoftLoop() *softLoop {
+	return &softLoop{wake: make(chan struct{}, 1)}
+}
+
+// push adds a function to the queue. It is safe to call from any goroutine.
+func (l *softLoop) push(f func()) {
+	l.lock.Lock()
+	l.queue = append(l.queue, f)
+	l.lock.Unlock()
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run runs queued functions forever.
+func (l *softLoop) run() {
+	for {
+		<-l.wake
+		for l.runNext() {
+		}
+	}
+}
+
+// runNext runs the next function in the queue, returning false if the queue
+// was empty.
+func (l *softLoop) runNext() bool {
+	l.lock.Lock()
+	if len(l.queue) == 0 {
+		l.lock.Unlock()
+		return false
+	}
+	f := l.queue[0]
+	l.queue[0] = nil
+	l.queue = l.queue[1:]
+	l.lock.Unlock()
+	f()
+	return true
+}
@@ -0,0 +1,158 @@
+package gogui
+
+// A softHost presents windows which are drawn by the software renderer.
+//
+// Every method is called on the main goroutine.
+type softHost interface {
+	// available reports whether the host can run in the current environment.
+	// Unlike the other methods, it may be called before Main.
+	available() bool
+
+	// start is called by Main before the run loop begins.
+	start(a *softApp, info *AppInfo) error
+
+	// showWindow is called when a window becomes visible.
+	showWindow(w *softWindow)
+
+	// hideWindow is called when a window stops being visible.
+	hideWindow(w *softWindow)
+
+	// updateWindow is called when the frame, title, or stacking order of a
+	// showing window changes.
+	updateWindow(w *softWindow)
+
+	// drawWindow is called after a showing window has redrawn its image.
+	drawWindow(w *softWindow)
+}
+
+// A softPainter is a softHost which draws canvases itself instead of having
+// windows rendered into images.
+type softPainter interface {
+	softHost
+
+	// paintWindow is called instead of drawWindow when a showing window needs
+	// to be redrawn.
+	paintWindow(w *softWindow)
+}
+
+// A softApp is a Driver which draws every window with the software renderer.
+// A softApp with a nil host keeps its windows in memory without showing them.
+type softApp struct {
+	host    softHost
+	loop    *softLoop
+	screen  Rect
+	started bool
+	windows []*softWindow
+}
+
+func newSoftApp(host softHost) *softApp {
+	return &softApp{
+		host:   host,
+		loop:   newSoftLoop(),
+		screen: Rect{0, 0, 1280, 800},
+	}
+}
+
+// Available reports whether the host can run in the current environment.
+func (a *softApp) Available() bool {
+	return a.host == nil || a.host.available()
+}
+
+// FontFamilies returns the family of the built-in font, unless the host
+// draws its canvases itself.
+func (a *softApp) FontFamilies() ([]string, error) {
+	return a.Fonts()
+}
+
+// Fonts returns the name of the built-in font, unless the host draws its
+// canvases itself.
+func (a *softApp) Fonts() ([]string, error) {
+	if _, ok := a.host.(softPainter); ok {
+		return nil, errCannotListFonts
+	}
+	return []string{softFontName}, nil
+}
+
+// Main starts the host and runs the main loop on the calling goroutine.
+func (a *softApp) Main(info *AppInfo) {
+	if a.host != nil {
+		if err := a.host.start(a, info); err != nil {
+			panic(err)
+		}
+	}
+	a.started = true
+	for _, w := range a.windows {
+		if a.host != nil {
+			a.host.showWindow(w)
+		}
+		w.invalidate()
+	}
+	a.loop.run()
+}
+
+// NewCanvas creates a canvas which is not in any window.
+func (a *softApp) NewCanvas(r Rect) (Canvas, error) {
+	return &softCanvas{frame: r}, nil
+}
+
+// NewWindow creates a window which is not showing.
+func (a *softApp) NewWindow(r Rect) (Window, error) {
+	return &softWindow{app: a, frame: r, widgets: []Widget{}}, nil
+}
+
+// RunOnMain pushes a function to the main loop.
+func (a *softApp) RunOnMain(f func()) {
+	a.loop.push(f)
+}
+
+// ShowingWindows returns the showing windows, ordered from back to front.
+func (a *softApp) ShowingWindows() []Window {
+	res := make([]Window, len(a.windows))
+	for i, w := range a.windows {
+		res[i] = w
+	}
+	return res
+}
+
+func (a *softApp) addWindow(w *softWindow) {
+	a.windows = append(a.windows, w)
+	if a.started && a.host != nil {
+		a.host.showWindow(w)
+	}
+}
+
+func (a *softApp) raiseWindow(w *softWindow) {
+	for i, x := range a.windows {
+		if x == w {
+			copy(a.windows[i:], a.windows[i+1:])
+			a.windows[len(a.windows)-1] = w
+			break
+		}
+	}
+	a.updateWindow(w)
+}
+
+func (a *softApp) removeWindow(w *softWindow) {
+	for i, x := range a.windows {
+		if x == w {
+			copy(a.windows[i:], a.windows[i+1:])
+			a.windows[len(a.windows)-1] = nil
+			a.windows = a.windows[:len(a.windows)-1]
+			break
+		}
+	}
+	if a.started && a.host != nil {
+		a.host.hideWindow(w)
+	}
+}
+
+// screenBounds returns the screen which the host shows windows on.
+func (a *softApp) screenBounds() Rect {
+	return a.screen
+}
+
+func (a *softApp) updateWindow(w *softWindow) {
+	if a.started && a.host != nil && w.showing {
+		a.host.updateWindow(w)
+	}
+}
@@ -0,0 +1,177 @@
+package gogui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// softWindowBackground matches the default window background on OS X.
+var softWindowBackground = color.RGBA{0xec, 0xec, 0xec, 0xff}
+
+type softWindow struct {
+	windowEvents
+
+	app       *softApp
+	frame     Rect
+	image     *image.RGBA
+	needsDraw bool
+	showing   bool
+	title     string
+	widgets   []Widget
+}
+
+func (w *softWindow) Add(widget Widget) {
+	c, ok := widget.(*softCanvas)
+	if !ok {
+		panic("Widget is not a software canvas")
+	} else if c.parent != nil {
+		panic("Widget already has a parent")
+	}
+	w.widgets = append(w.widgets, c)
+	c.parent = w
+	w.invalidate()
+}
+
+func (w *softWindow) Center() {
+	s := w.app.screen
+	w.SetFrame(Rect{s.X + (s.Width-w.frame.Width)/2,
+		s.Y + (s.Height-w.frame.Height)/2, w.frame.Width, w.frame.Height})
+}
+
+func (w *softWindow) Children() []Widget {
+	cpy := make([]Widget, len(w.widgets))
+	copy(cpy, w.widgets)
+	return cpy
+}
+
+func (w *softWindow) Focus() {
+	w.Show()
+	w.app.raiseWindow(w)
+}
+
+func (w *softWindow) Frame() Rect {
+	return w.frame
+}
+
+func (w *softWindow) Hide() {
+	if !w.showing {
+		return
+	}
+	w.showing = false
+	w.app.removeWindow(w)
+}
+
+func (w *softWindow) Parent() Widget {
+	return nil
+}
+
+func (w *softWindow) Remove() {
+}
+
+func (w *softWindow) SetFrame(r Rect) {
+	w.frame = r
+	w.app.updateWindow(w)
+	w.invalidate()
+}
+
+func (w *softWindow) SetTitle(t string) {
+	w.title = t
+	w.app.updateWindow(w)
+}
+
+func (w *softWindow) Show() {
+	if w.showing {
+		return
+	}
+	w.showing = true
+	w.app.addWindow(w)
+	w.invalidate()
+}
+
+func (w *softWindow) Showing() bool {
+	return w.showing
+}
+
+func (w *softWindow) Snapshot() (image.Image, error) {
+	return w.SnapshotScale(1)
+}
+
+func (w *softWindow) SnapshotScale(scale float64) (image.Image, error) {
+	bounds, err := snapshotBounds(w.frame, scale)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(bounds)
+	w.render(img, scale)
+	return img, nil
+}
+
+// draw renders the window's canvases into its image and hands the image to
+// the host.
+func (w *softWindow) draw() {
+	if !w.showing {
+		return
+	}
+	if p, ok := w.app.host.(softPainter); ok && w.app.started {
+		p.paintWindow(w)
+		return
+	}
+	bounds := image.Rect(0, 0, int(math.Ceil(w.frame.Width)),
+		int(math.Ceil(w.frame.Height)))
+	if w.image == nil || w.image.Rect != bounds {
+		w.image = image.NewRGBA(bounds)
+	}
+	w.render(w.image, 1)
+	if w.app.started && w.app.host != nil {
+		w.app.host.drawWindow(w)
+	}
+}
+
+// render draws the background and every canvas into an image with scale
+// pixels per unit.
+func (w *softWindow) render(img *image.RGBA, scale float64) {
+	draw.Draw(img, img.Bounds(), image.NewUniform(softWindowBackground),
+		image.ZP, draw.Src)
+	for _, widget := range w.widgets {
+		widget.(*softCanvas).draw(img, scale)
+	}
+}
+
+// invalidate schedules the window to be redrawn on the main loop. Like
+// -setNeedsDisplay:, several calls before the redraw only cause one redraw.
+func (w *softWindow) invalidate() {
+	if w.needsDraw || !w.showing {
+		return
+	}
+	w.needsDraw = true
+	w.app.RunOnMain(func() {
+		w.needsDraw = false
+		w.draw()
+	})
+}
+
+func (w *softWindow) removeWidget(c *softCanvas) {
+	for i, x := range w.widgets {
+		if x == c {
+			copy(w.widgets[i:], w.widgets[i+1:])
+			w.widgets[len(w.widgets)-1] = nil
+			w.widgets = w.widgets[:len(w.widgets)-1]
+			break
+		}
+	}
+	w.invalidate()
+}
+
+// userClosed hides the window and calls its close handler. Hosts call this
+// when the user closes a window.
+func (w *softWindow) userClosed() {
+	if !w.showing {
+		return
+	}
+	w.Hide()
+	if h := w.CloseHandler(); h != nil {
+		h()
+	}
+}
@@ -0,0 +1,46 @@
+package gogui
+
+// keyEvent delivers a key event to the window's handlers. For key down events,
+// a key press event with modCode is delivered as well unless modCode is
+// negative.
+func (w *softWindow) keyEvent(eventType, keyCode, rawCode, modCode,
+	flags int) {
+	evt := makeKeyEvent(keyCode, rawCode, flags)
+	if eventType == keyEventUp {
+		if h := w.KeyUpHandler(); h != nil {
+			h(evt)
+		}
+		return
+	}
+	if h := w.KeyDownHandler(); h != nil {
+		h(evt)
+	}
+	if modCode < 0 {
+		return
+	}
+	evt.CharCode = modCode
+	if h := w.KeyPressHandler(); h != nil {
+		h(evt)
+	}
+}
+
+// mouseEvent delivers a mouse event to the window's handlers. The coordinates
+// are relative to the window's content rectangle.
+func (w *softWindow) mouseEvent(eventType int, x, y float64) {
+	var handler MouseHandler
+	switch eventType {
+	case mouseEventDown:
+		handler = w.MouseDownHandler()
+	case mouseEventDrag:
+		handler = w.MouseDragHandler()
+	case mouseEventMove:
+		handler = w.MouseMoveHandler()
+	case mouseEventUp:
+		handler = w.MouseUpHandler()
+	default:
+		panic("Unknown mouse event.")
+	}
+	if handler != nil {
+		handler(MouseEvent{x, y})
+	}
+}
@@ -0,0 +1,61 @@
+package gogui
+
+import (
+	"math"
+)
+
+// A LineCap is the shape at the ends of stroked lines.
+type LineCap int
+
+const (
+	// LineCapButt ends lines exactly at their end points.
+	LineCapButt LineCap = iota
+
+	// LineCapRound ends lines with half circles. This is the default.
+	LineCapRound
+
+	// LineCapSquare ends lines with half squares, so that they stick out past
+	// their end points by half of the thickness.
+	LineCapSquare
+)
+
+// A LineJoin is the shape at the corners of stroked lines.
+type LineJoin int
+
+const (
+	// LineJoinMiter extends the outer edges of lines until they meet in a
+	// point. Corners whose point would be too far away are beveled instead;
+	// see DrawContext.SetMiterLimit.
+	LineJoinMiter LineJoin = iota
+
+	// LineJoinRound rounds off corners. This is the default.
+	LineJoinRound
+
+	// LineJoinBevel cuts off corners with a straight line.
+	LineJoinBevel
+)
+
+// defaultMiterLimit is the miter limit which every backend starts with.
+const defaultMiterLimit = 10
+
+// normalizeLineDash checks a dash pattern the way an HTML5 canvas does. It
+// returns false if the pattern has an entry which is negative or not finite.
+// Patterns with an odd number of entries are repeated, and patterns which are
+// all zero become nil, meaning that lines are solid.
+func normalizeLineDash(pattern []float64) ([]float64, bool) {
+	var sum float64
+	for _, x := range pattern {
+		if x < 0 || math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, false
+		}
+		sum += x
+	}
+	if sum == 0 || math.IsInf(sum, 0) {
+		return nil, true
+	}
+	res := append([]float64{}, pattern...)
+	if len(res)%2 == 1 {
+		res = append(res, pattern...)
+	}
+	return res, true
+}
@@ -0,0 +1,242 @@
+// +build term
+// +build !js
+
+package gogui
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A termEvent is a key or mouse event decoded from terminal input.
+//
+// Key events carry the X keysym of the key, which lets the terminal backend
+// share its key codes with the X11 backend. Mouse events carry the 1-based
+// cell which the mouse is over.
+type termEvent struct {
+	mouse     bool
+	eventType int
+	col, row  int
+
+	sym   uint32
+	flags int
+}
+
+// termCSIKeys maps the final bytes of CSI and SS3 sequences to keysyms.
+var termCSIKeys = map[byte]uint32{
+	'A': 0xff52, // Up
+	'B': 0xff54, // Down
+	'C': 0xff53, // Right
+	'D': 0xff51, // Left
+	'F': 0xff57, // End
+	'H': 0xff50, // Home
+	'P': 0xffbe, // F1
+	'Q': 0xffbf, // F2
+	'R': 0xffc0, // F3
+	'S': 0xffc1, // F4
+}
+
+// termTildeKeys maps the numbers in "CSI n ~" sequences to keysyms.
+var termTildeKeys = map[int]uint32{
+	1:  0xff50, // Home
+	2:  0xff63, // Insert
+	3:  0xffff, // Delete
+	4:  0xff57, // End
+	5:  0xff55, // Page_Up
+	6:  0xff56, // Page_Down
+	7:  0xff50, // Home
+	8:  0xff57, // End
+	11: 0xffbe, // F1
+	12: 0xffbf, // F2
+	13: 0xffc0, // F3
+	14: 0xffc1, // F4
+	15: 0xffc2, // F5
+	17: 0xffc3, // F6
+	18: 0xffc4, // F7
+	19: 0xffc5, // F8
+	20: 0xffc6, // F9
+	21: 0xffc7, // F10
+	23: 0xffc8, // F11
+	24: 0xffc9, // F12
+}
+
+// termDecode decodes as many events as possible from terminal input. It
+// returns the bytes at the end of data which form an incomplete sequence.
+//
+// An escape byte at the very end of data is taken to be the escape key, since
+// terminals send escape sequences all at once.
+func termDecode(data []byte) (events []termEvent, rest []byte) {
+	for len(data) > 0 {
+		evt, n := termDecodeOne(data)
+		if n == 0 {
+			return events, data
+		}
+		if evt != nil {
+			events = append(events, *evt)
+		}
+		data = data[n:]
+	}
+	return events, nil
+}
+
+// termDecodeOne decodes the event at the start of data. It returns the number
+// of bytes used, or 0 if data starts with an incomplete sequence. The event
+// is nil if the bytes were not understood.
+func termDecodeOne(data []byte) (*termEvent, int) {
+	if data[0] != 0x1b {
+		return termDecodeChar(data, 0)
+	}
+	if len(data) == 1 {
+		return &termEvent{sym: 0xff1b}, 1
+	}
+	switch data[1] {
+	case '[':
+		return termDecodeCSI(data)
+	case 'O':
+		if len(data) < 3 {
+			return nil, 0
+		}
+		if sym, ok := termCSIKeys[data[2]]; ok {
+			return &termEvent{sym: sym}, 3
+		}
+		return nil, 3
+	case 0x1b:
+		return &termEvent{sym: 0xff1b}, 1
+	}
+
+	// An escape before any other key means that alt was held.
+	evt, n := termDecodeChar(data[1:], keyFlagAlt)
+	if n == 0 {
+		return nil, 0
+	}
+	return evt, n + 1
+}
+
+// termDecodeChar decodes a character or control character.
+func termDecodeChar(data []byte, flags int) (*termEvent, int) {
+	b := data[0]
+	switch {
+	case b == '\r' || b == '\n':
+		return &termEvent{sym: 0xff0d, flags: flags}, 1
+	case b == '\t':
+		return &termEvent{sym: 0xff09, flags: flags}, 1
+	case b == 0x7f || b == 0x08:
+		return &termEvent{sym: 0xff08, flags: flags}, 1
+	case b == 0:
+		return &termEvent{sym: ' ', flags: flags | keyFlagCtrl}, 1
+	case b < 0x1b:
+		return &termEvent{sym: uint32('a' + b - 1), flags: flags | keyFlagCtrl}, 1
+	case b < 0x20:
+		return &termEvent{sym: uint32('\\' + b - 0x1c),
+			flags: flags | keyFlagCtrl}, 1
+	}
+	if !utf8.FullRune(data) {
+		return nil, 0
+	}
+	r, n := utf8.DecodeRune(data)
+	if r == utf8.RuneError {
+		return nil, n
+	}
+	if unicode.IsUpper(r) {
+		flags |= keyFlagShift
+	}
+	sym := uint32(r)
+	if r >= 0x100 {
+		sym += 0x1000000
+	}
+	return &termEvent{sym: sym, flags: flags}, n
+}
+
+// termDecodeCSI decodes a control sequence which starts with "ESC [".
+func termDecodeCSI(data []byte) (*termEvent, int) {
+	end := 2
+	for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+		end++
+	}
+	if end == len(data) {
+		return nil, 0
+	}
+	final := data[end]
+	params := string(data[2:end])
+	n := end + 1
+
+	if strings.HasPrefix(params, "<") {
+		return termDecodeMouse(params[1:], final), n
+	}
+
+	var nums []int
+	for _, field := range strings.Split(params, ";") {
+		num, _ := strconv.Atoi(field)
+		nums = append(nums, num)
+	}
+	flags := 0
+	if len(nums) > 1 && nums[1] > 1 {
+		// The modifier parameter is one more than a bit field of the
+		// modifiers which were held.
+		mods := nums[1] - 1
+		if (mods & 1) != 0 {
+			flags |= keyFlagShift
+		}
+		if (mods & 2) != 0 {
+			flags |= keyFlagAlt
+		}
+		if (mods & 4) != 0 {
+			flags |= keyFlagCtrl
+		}
+		if (mods & 8) != 0 {
+			flags |= keyFlagMeta
+		}
+	}
+	switch final {
+	case '~':
+		if sym, ok := termTildeKeys[nums[0]]; ok {
+			return &termEvent{sym: sym, flags: flags}, n
+		}
+	case 'Z':
+		return &termEvent{sym: 0xff09, flags: keyFlagShift}, n
+	default:
+		if sym, ok := termCSIKeys[final]; ok {
+			return &termEvent{sym: sym, flags: flags}, n
+		}
+	}
+	return nil, n
+}
+
+// termDecodeMouse decodes the parameters of an xterm SGR mouse report.
+// Only the left button is reported, like on other platforms.
+func termDecodeMouse(params string, final byte) *termEvent {
+	fields := strings.Split(params, ";")
+	if len(fields) != 3 {
+		return nil
+	}
+	var nums [3]int
+	for i, field := range fields {
+		num, err := strconv.Atoi(field)
+		if err != nil {
+			return nil
+		}
+		nums[i] = num
+	}
+	button := nums[0] & 3
+	motion := (nums[0] & 32) != 0
+	if (nums[0] & 64) != 0 {
+		// Scroll wheel.
+		return nil
+	}
+	evt := &termEvent{mouse: true, col: nums[1], row: nums[2]}
+	switch {
+	case motion && button == 0:
+		evt.eventType = mouseEventDrag
+	case motion && button == 3:
+		evt.eventType = mouseEventMove
+	case motion, button != 0:
+		return nil
+	case final == 'M':
+		evt.eventType = mouseEventDown
+	default:
+		evt.eventType = mouseEventUp
+	}
+	return evt
+}
@@ -0,0 +1,11 @@
+// +build term
+// +build darwin freebsd netbsd openbsd
+
+package gogui
+
+import "syscall"
+
+const (
+	termIoctlGet = syscall.TIOCGETA
+	termIoctlSet = syscall.TIOCSETA
+)
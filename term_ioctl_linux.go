@@ -0,0 +1,10 @@
+// +build term
+
+package gogui
+
+import "syscall"
+
+const (
+	termIoctlGet = syscall.TCGETS
+	termIoctlSet = syscall.TCSETS
+)
@@ -0,0 +1,233 @@
+// +build term
+// +build !js
+
+package gogui
+
+import (
+	"errors"
+	"image"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// The term driver shows windows in the terminal. It is available when standard
+// input is a terminal.
+//
+// Windows are drawn with colored half blocks, or with braille patterns if the
+// GOGUI_TERM_MODE environment variable is "braille". Each character cell shows
+// an area of 8x16 points. The terminal is only taken over while windows are
+// showing, and pressing Control-C closes the front window.
+func init() {
+	RegisterDriver("term", newSoftApp(newTermHost()))
+}
+
+// A termHost shows windows in a terminal which supports 24-bit color and
+// xterm mouse reporting.
+type termHost struct {
+	app     *softApp
+	desktop *softDesktop
+	input   *os.File
+	output  *os.File
+	screen  termScreen
+
+	saved  syscall.Termios
+	active bool
+	title  string
+}
+
+func newTermHost() *termHost {
+	return &termHost{input: os.Stdin, output: os.Stdout}
+}
+
+func (t *termHost) available() bool {
+	var state syscall.Termios
+	return termIoctl(t.input.Fd(), termIoctlGet, unsafe.Pointer(&state)) == nil
+}
+
+func (t *termHost) start(a *softApp, info *AppInfo) error {
+	t.app = a
+	t.desktop = newSoftDesktop(a, 0, t.present)
+	if err := termIoctl(t.input.Fd(), termIoctlGet,
+		unsafe.Pointer(&t.saved)); err != nil {
+		return errors.New("standard input is not a terminal")
+	}
+	t.screen.braille = os.Getenv("GOGUI_TERM_MODE") == "braille"
+	t.resize()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH, syscall.SIGHUP, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGWINCH {
+				a.RunOnMain(t.resize)
+			} else {
+				a.RunOnMain(func() {
+					t.leave()
+					os.Exit(1)
+				})
+			}
+		}
+	}()
+	go t.readInput()
+	return nil
+}
+
+func (t *termHost) showWindow(w *softWindow) {
+	t.enter()
+	t.desktop.invalidate()
+}
+
+func (t *termHost) hideWindow(w *softWindow) {
+	t.desktop.windowHidden(w)
+	if len(t.app.windows) == 0 {
+		// Leave the terminal before the close handler runs, since it might
+		// exit the program.
+		t.leave()
+	}
+}
+
+func (t *termHost) updateWindow(w *softWindow) {
+	t.desktop.invalidate()
+}
+
+func (t *termHost) drawWindow(w *softWindow) {
+	t.desktop.invalidate()
+}
+
+// enter puts the terminal in raw mode and switches to the alternate screen.
+func (t *termHost) enter() {
+	if t.active {
+		return
+	}
+	raw := t.saved
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK |
+		syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL |
+		syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON |
+		syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	termIoctl(t.input.Fd(), termIoctlSet, unsafe.Pointer(&raw))
+
+	// Save the title, switch to the alternate screen, hide the cursor, and
+	// turn on SGR mouse reporting for all motion.
+	t.output.WriteString("\x1b[22;0t\x1b[?1049h\x1b[?25l\x1b[?1003h" +
+		"\x1b[?1006h\x1b[2J")
+	t.active = true
+	t.title = ""
+	t.screen.cells = nil
+}
+
+// leave undoes enter.
+func (t *termHost) leave() {
+	if !t.active {
+		return
+	}
+	t.output.WriteString("\x1b[?1006l\x1b[?1003l\x1b[0m\x1b[?25h" +
+		"\x1b[?1049l\x1b[23;0t")
+	termIoctl(t.input.Fd(), termIoctlSet, unsafe.Pointer(&t.saved))
+	t.active = false
+}
+
+func (t *termHost) present(img *image.RGBA) {
+	if !t.active {
+		return
+	}
+	if w := t.desktop.frontWindow(); w != nil && w.title != t.title {
+		t.title = w.title
+		clean := strings.Map(func(r rune) rune {
+			if r < 0x20 || r == 0x7f {
+				return -1
+			}
+			return r
+		}, t.title)
+		t.output.WriteString("\x1b]2;" + clean + "\x07")
+	}
+	t.output.Write(t.screen.update(img))
+}
+
+// resize updates the screen to match the size of the terminal.
+func (t *termHost) resize() {
+	var size struct {
+		rows, cols, xPixels, yPixels uint16
+	}
+	if termIoctl(t.output.Fd(), syscall.TIOCGWINSZ,
+		unsafe.Pointer(&size)) != nil || size.cols == 0 || size.rows == 0 {
+		size.cols, size.rows = 80, 24
+	}
+	t.screen.cols = int(size.cols)
+	t.screen.rows = int(size.rows)
+	t.screen.cells = nil
+	t.app.screen = Rect{0, 0, float64(size.cols) * termCellWidth,
+		float64(size.rows) * termCellHeight}
+	if t.active {
+		t.output.WriteString("\x1b[2J")
+		t.desktop.invalidate()
+	}
+}
+
+// readInput decodes terminal input and handles it on the main loop.
+func (t *termHost) readInput() {
+	buf := make([]byte, 256)
+	var pending []byte
+	for {
+		n, err := t.input.Read(buf)
+		if err != nil {
+			return
+		}
+		events, rest := termDecode(append(pending, buf[:n]...))
+		pending = append([]byte{}, rest...)
+		if len(events) > 0 {
+			t.app.RunOnMain(func() {
+				for _, evt := range events {
+					t.handleEvent(evt)
+				}
+			})
+		}
+	}
+}
+
+func (t *termHost) handleEvent(evt termEvent) {
+	if !t.active {
+		return
+	}
+	if evt.mouse {
+		x := (float64(evt.col) - 0.5) * termCellWidth
+		y := (float64(evt.row) - 0.5) * termCellHeight
+		t.desktop.mouse(evt.eventType, x, y)
+		return
+	}
+	w := t.desktop.frontWindow()
+	if w == nil {
+		return
+	}
+	if evt.sym == 'c' && evt.flags == keyFlagCtrl {
+		w.userClosed()
+		return
+	}
+	rawCode := keysymCharCode(evt.sym)
+	modCode := rawCode
+	if r := keysymRune(evt.sym); r >= 0 {
+		modCode = int(r)
+	}
+
+	// Terminals do not report key releases, so every key is released as soon
+	// as it is pressed.
+	w.keyEvent(keyEventDown, rawCode, rawCode, modCode, evt.flags)
+	w.keyEvent(keyEventUp, rawCode, rawCode, -1, evt.flags)
+}
+
+func termIoctl(fd, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request,
+		uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// +build term
+// +build !js
+
+package gogui
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strconv"
+)
+
+// termCellWidth and termCellHeight are the size, in points, of the part of
+// the screen which one character cell shows.
+const (
+	termCellWidth  = 8
+	termCellHeight = 16
+)
+
+// A termCell is a character cell with a foreground and background color.
+type termCell struct {
+	ch rune
+	fg color.RGBA
+	bg color.RGBA
+}
+
+// termHalfBlockCell shows a cell as two pixels stacked on top of each other.
+// The upper half block is drawn with the top pixel's color on a background of
+// the bottom pixel's color.
+func termHalfBlockCell(img *image.RGBA, r image.Rectangle) termCell {
+	mid := (r.Min.Y + r.Max.Y) / 2
+	top := termAverage(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, mid))
+	bottom := termAverage(img, image.Rect(r.Min.X, mid, r.Max.X, r.Max.Y))
+	if top == bottom {
+		return termCell{' ', top, bottom}
+	}
+	return termCell{'▀', top, bottom}
+}
+
+// termBrailleCell shows a cell as a braille pattern of 2x4 dots. The dots
+// which are brighter than average are raised and drawn with the foreground
+// color, while the rest become the background.
+func termBrailleCell(img *image.RGBA, r image.Rectangle) termCell {
+	// dotBits maps each dot, in row-major order, to its bit in the pattern.
+	dotBits := [8]rune{0x01, 0x08, 0x02, 0x10, 0x04, 0x20, 0x40, 0x80}
+
+	var dots [8]color.RGBA
+	var lums [8]int
+	mean := 0
+	for i := range dots {
+		x0 := r.Min.X + (i%2)*r.Dx()/2
+		x1 := r.Min.X + (i%2+1)*r.Dx()/2
+		y0 := r.Min.Y + (i/2)*r.Dy()/4
+		y1 := r.Min.Y + (i/2+1)*r.Dy()/4
+		dots[i] = termAverage(img, image.Rect(x0, y0, x1, y1))
+		lums[i] = 299*int(dots[i].R) + 587*int(dots[i].G) + 114*int(dots[i].B)
+		mean += lums[i]
+	}
+	mean /= len(dots)
+
+	var on, off []color.RGBA
+	pattern := rune(0x2800)
+	for i, c := range dots {
+		if lums[i] > mean {
+			on = append(on, c)
+			pattern |= dotBits[i]
+		} else {
+			off = append(off, c)
+		}
+	}
+	bg := termMix(off)
+	if len(on) == 0 {
+		return termCell{' ', bg, bg}
+	}
+	return termCell{pattern, termMix(on), bg}
+}
+
+// termAverage computes the average color of a rectangle in an image.
+func termAverage(img *image.RGBA, r image.Rectangle) color.RGBA {
+	r = r.Intersect(img.Rect)
+	if r.Empty() {
+		return softDesktopBackground
+	}
+	var sums [3]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		idx := img.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sums[0] += int(img.Pix[idx])
+			sums[1] += int(img.Pix[idx+1])
+			sums[2] += int(img.Pix[idx+2])
+			idx += 4
+		}
+	}
+	count := r.Dx() * r.Dy()
+	return color.RGBA{uint8(sums[0] / count), uint8(sums[1] / count),
+		uint8(sums[2] / count), 0xff}
+}
+
+func termMix(colors []color.RGBA) color.RGBA {
+	var sums [3]int
+	for _, c := range colors {
+		sums[0] += int(c.R)
+		sums[1] += int(c.G)
+		sums[2] += int(c.B)
+	}
+	n := len(colors)
+	return color.RGBA{uint8(sums[0] / n), uint8(sums[1] / n),
+		uint8(sums[2] / n), 0xff}
+}
+
+// A termScreen converts images into character cells and produces the output
+// needed to update the terminal.
+type termScreen struct {
+	cols, rows int
+	braille    bool
+
+	// cells holds what the terminal currently shows, or nil if the terminal
+	// must be redrawn from scratch.
+	cells []termCell
+}
+
+// update returns the output which changes the terminal's cells to show an
+// image. Only the cells which have changed are redrawn.
+func (t *termScreen) update(img *image.RGBA) []byte {
+	var out bytes.Buffer
+	fresh := t.cells == nil
+	if fresh {
+		t.cells = make([]termCell, t.cols*t.rows)
+	}
+
+	var fg, bg color.RGBA
+	colorsSet := false
+	cursorCol, cursorRow := -1, -1
+	for row := 0; row < t.rows; row++ {
+		for col := 0; col < t.cols; col++ {
+			r := image.Rect(col*termCellWidth, row*termCellHeight,
+				(col+1)*termCellWidth, (row+1)*termCellHeight)
+			var cell termCell
+			if t.braille {
+				cell = termBrailleCell(img, r)
+			} else {
+				cell = termHalfBlockCell(img, r)
+			}
+			idx := row*t.cols + col
+			if !fresh && t.cells[idx] == cell {
+				continue
+			}
+			t.cells[idx] = cell
+
+			if cursorCol != col || cursorRow != row {
+				out.WriteString("\x1b[" + strconv.Itoa(row+1) + ";" +
+					strconv.Itoa(col+1) + "H")
+			}
+			if !colorsSet || cell.fg != fg {
+				termWriteColor(&out, 38, cell.fg)
+			}
+			if !colorsSet || cell.bg != bg {
+				termWriteColor(&out, 48, cell.bg)
+			}
+			fg, bg = cell.fg, cell.bg
+			colorsSet = true
+			out.WriteRune(cell.ch)
+			cursorCol, cursorRow = col+1, row
+		}
+	}
+	if out.Len() > 0 {
+		out.WriteString("\x1b[0m")
+	}
+	return out.Bytes()
+}
+
+// termWriteColor writes an SGR sequence which sets a 24-bit color. The
+// selector is 38 for the foreground or 48 for the background.
+func termWriteColor(out *bytes.Buffer, selector int, c color.RGBA) {
+	out.WriteString("\x1b[" + strconv.Itoa(selector) + ";2;" +
+		strconv.Itoa(int(c.R)) + ";" + strconv.Itoa(int(c.G)) + ";" +
+		strconv.Itoa(int(c.B)) + "m")
+}
@@ -0,0 +1,68 @@
+package gogui
+
+// A TextAlign says which part of a string FillText and StrokeText put at the
+// x coordinate they are given.
+type TextAlign int
+
+const (
+	// TextAlignLeft puts the left edge of the text at the point. This is the
+	// default.
+	TextAlignLeft TextAlign = iota
+
+	// TextAlignCenter puts the center of the text at the point.
+	TextAlignCenter
+
+	// TextAlignRight puts the right edge of the text at the point.
+	TextAlignRight
+
+	// TextAlignStart puts the start of the text at the point. Text is always
+	// laid out from left to right, so this is the same as TextAlignLeft.
+	TextAlignStart
+
+	// TextAlignEnd puts the end of the text at the point. Text is always laid
+	// out from left to right, so this is the same as TextAlignRight.
+	TextAlignEnd
+)
+
+// A TextBaseline says which part of a string FillText and StrokeText put at
+// the y coordinate they are given. Every baseline is relative to the box that
+// TextSize measures, which goes from the ascent of the font to its descent.
+type TextBaseline int
+
+const (
+	// TextBaselineTop puts the top of the text's box at the point. This is
+	// the default.
+	TextBaselineTop TextBaseline = iota
+
+	// TextBaselineMiddle puts the middle of the text's box at the point.
+	TextBaselineMiddle
+
+	// TextBaselineAlphabetic puts the baseline which letters sit on at the
+	// point.
+	TextBaselineAlphabetic
+
+	// TextBaselineBottom puts the bottom of the text's box at the point.
+	TextBaselineBottom
+)
+
+// textOrigin returns the top-left corner of the box which TextSize measures
+// for text that is drawn at (x, y). The ascent is the distance from the top of
+// the box to the alphabetic baseline.
+func textOrigin(x, y, width, height, ascent float64, align TextAlign,
+	baseline TextBaseline) (float64, float64) {
+	switch align {
+	case TextAlignCenter:
+		x -= width / 2
+	case TextAlignRight, TextAlignEnd:
+		x -= width
+	}
+	switch baseline {
+	case TextBaselineMiddle:
+		y -= height / 2
+	case TextBaselineAlphabetic:
+		y -= ascent
+	case TextBaselineBottom:
+		y -= height
+	}
+	return x, y
+}
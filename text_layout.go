@@ -0,0 +1,444 @@
+package gogui
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textEllipsis is added to the end of lines which are cut short.
+const textEllipsis = "…"
+
+// A TextWrap says where a TextLayout may break lines which are too wide.
+type TextWrap int
+
+const (
+	// TextWrapWord breaks lines between words. A word which is wider than a
+	// whole line is broken between characters. This is the default.
+	TextWrapWord TextWrap = iota
+
+	// TextWrapCharacter breaks lines between any two characters.
+	TextWrapCharacter
+
+	// TextWrapNone only starts new lines at newlines. Lines which are too
+	// wide are cut short with an ellipsis.
+	TextWrapNone
+)
+
+// A TextLayout arranges a string in lines which fit in a maximum width.
+//
+// The fields may be changed at any time. A layout is measured again whenever
+// it is drawn or asked for its lines, so it is always up to date.
+type TextLayout struct {
+	// Text is the string to lay out. Newlines always start new lines.
+	Text string
+
+	// Font is the font to draw the text with.
+	Font Font
+
+	// MaxWidth is the width to fit each line in. If it is zero, lines are
+	// only broken at newlines.
+	MaxWidth float64
+
+	// Wrap says where lines may be broken.
+	Wrap TextWrap
+
+	// Align says how lines are placed in the width of the layout, which is
+	// MaxWidth if it is set, or else the width of the widest line.
+	Align TextAlign
+
+	// LineSpacing multiplies the distance between the baselines of the lines.
+	// Zero means 1, which uses the line height of the font.
+	LineSpacing float64
+
+	// MaxLines is the number of lines to show. If the text needs more, the
+	// last line is cut short with an ellipsis. Zero means no limit.
+	MaxLines int
+}
+
+// A TextLine is one line of a TextLayout.
+type TextLine struct {
+	// Text is the text which the line shows, including an ellipsis if it
+	// has one.
+	Text string
+
+	// Start and End are the byte offsets of the part of the layout's Text
+	// which the line shows. Newlines, and the spaces which lines are wrapped
+	// at, are not part of any line.
+	Start int
+	End   int
+
+	// Bounds is the box around the line, from the ascent of the font to its
+	// descent, relative to the top-left corner of the layout.
+	Bounds Rect
+
+	// Baseline is the y coordinate of the line's baseline, relative to the
+	// top of the layout.
+	Baseline float64
+
+	// Ellipsis is true if the line was cut short.
+	Ellipsis bool
+}
+
+// NewTextLayout creates a TextLayout with the default settings.
+func NewTextLayout(text string, f Font, maxWidth float64) *TextLayout {
+	return &TextLayout{Text: text, Font: f, MaxWidth: maxWidth}
+}
+
+// Bounds returns the smallest rectangle around every line of the layout, as
+// it would be drawn by DrawTextLayout at (0, 0).
+func (t *TextLayout) Bounds(ctx DrawContext) Rect {
+	lines := t.Lines(ctx)
+	res := lines[0].Bounds
+	for _, line := range lines[1:] {
+		b := line.Bounds
+		minX := math.Min(res.X, b.X)
+		maxX := math.Max(res.X+res.Width, b.X+b.Width)
+		res = Rect{minX, res.Y, maxX - minX, b.Y + b.Height - res.Y}
+	}
+	return res
+}
+
+// Lines breaks the layout into lines, measuring text like ctx does. There is
+// always at least one line, even if the text is empty.
+func (t *TextLayout) Lines(ctx DrawContext) []TextLine {
+	w := &layoutWidths{measure: layoutMeasurer(ctx), font: t.Font,
+		cache: map[string]float64{}}
+	metrics := w.measure.fontMetrics(t.Font)
+	height := metrics.Ascent + metrics.Descent
+	spacing := t.LineSpacing
+	if spacing <= 0 {
+		spacing = 1
+	}
+	advance := (height + metrics.Leading) * spacing
+
+	var ranges [][2]int
+	start := 0
+	for {
+		end := strings.IndexByte(t.Text[start:], '\n')
+		if end < 0 {
+			ranges = append(ranges, t.breakParagraph(w, start, len(t.Text))...)
+			break
+		}
+		end += start
+		paragraphEnd := end
+		if paragraphEnd > start && t.Text[paragraphEnd-1] == '\r' {
+			paragraphEnd--
+		}
+		ranges = append(ranges, t.breakParagraph(w, start, paragraphEnd)...)
+		start = end + 1
+	}
+	truncated := false
+	if t.MaxLines > 0 && len(ranges) > t.MaxLines {
+		ranges = ranges[:t.MaxLines]
+		truncated = true
+	}
+
+	lines := make([]TextLine, len(ranges))
+	texts := make([]string, len(ranges))
+	for i, r := range ranges {
+		texts[i] = t.Text[r[0]:r[1]]
+	}
+	w.prefetch(texts)
+	for i, r := range ranges {
+		line := &lines[i]
+		line.Start, line.End = r[0], r[1]
+		line.Text = texts[i]
+		line.Ellipsis = truncated && i == len(ranges)-1
+		if !line.Ellipsis && t.Wrap == TextWrapNone && t.MaxWidth > 0 {
+			line.Ellipsis = w.width(line.Text) > t.MaxWidth
+		}
+		if line.Ellipsis {
+			line.Text, line.End = t.ellipsize(w, r[0], r[1])
+		}
+	}
+
+	var widest float64
+	for i := range lines {
+		line := &lines[i]
+		width := w.width(line.Text)
+		top := float64(i) * advance
+		line.Bounds = Rect{0, top, width, height}
+		line.Baseline = top + metrics.Ascent
+		widest = math.Max(widest, width)
+	}
+
+	boxWidth := t.MaxWidth
+	if boxWidth <= 0 {
+		boxWidth = widest
+	}
+	for i := range lines {
+		b := &lines[i].Bounds
+		switch t.Align {
+		case TextAlignCenter:
+			b.X = (boxWidth - b.Width) / 2
+		case TextAlignRight, TextAlignEnd:
+			b.X = boxWidth - b.Width
+		}
+	}
+	return lines
+}
+
+// breakParagraph splits the text from start to end, which has no newlines,
+// into the byte ranges of lines.
+func (t *TextLayout) breakParagraph(w *layoutWidths, start,
+	end int) [][2]int {
+	if t.MaxWidth <= 0 || t.Wrap == TextWrapNone {
+		return [][2]int{{start, end}}
+	}
+	b := &lineBreaker{text: t.Text, widths: w, maxWidth: t.MaxWidth,
+		lineStart: start, lineEnd: start}
+	if t.Wrap == TextWrapCharacter {
+		b.addClusters(start, end)
+		return append(b.lines, [2]int{b.lineStart, b.lineEnd})
+	}
+
+	// Every word and the spaces before it are measured up front.
+	var words [][3]int
+	var pieces []string
+	for i := start; i < end; {
+		wordStart := skipSpaces(t.Text, i, end)
+		wordEnd := wordStart
+		for wordEnd < end {
+			r, size := utf8.DecodeRuneInString(t.Text[wordEnd:])
+			if isBreakingSpace(r) {
+				break
+			}
+			wordEnd += size
+		}
+		if wordStart < wordEnd {
+			words = append(words, [3]int{i, wordStart, wordEnd})
+			pieces = append(pieces, t.Text[i:wordStart],
+				t.Text[wordStart:wordEnd])
+		}
+		i = wordEnd
+	}
+	w.prefetch(pieces)
+	for _, word := range words {
+		b.addWord(word[0], word[1], word[2])
+	}
+	return append(b.lines, [2]int{b.lineStart, b.lineEnd})
+}
+
+// ellipsize shortens the text from start to end so that it fits in MaxWidth
+// with an ellipsis after it. It returns the text to show and the offset where
+// the shortened text ends.
+func (t *TextLayout) ellipsize(w *layoutWidths, start, end int) (string,
+	int) {
+	trimmed := func(end int) int {
+		return start + len(strings.TrimRightFunc(t.Text[start:end],
+			isBreakingSpace))
+	}
+	end = trimmed(end)
+	if t.MaxWidth <= 0 ||
+		w.width(t.Text[start:end]+textEllipsis) <= t.MaxWidth {
+		return t.Text[start:end] + textEllipsis, end
+	}
+
+	// Find the most clusters which fit. The width only grows as clusters are
+	// added, so a binary search works.
+	ends := []int{start}
+	for i := start; i < end; {
+		i = nextCluster(t.Text, i, end)
+		ends = append(ends, i)
+	}
+	lo, hi := 0, len(ends)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		e := trimmed(ends[mid])
+		if w.width(t.Text[start:e]+textEllipsis) <= t.MaxWidth {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	end = trimmed(ends[lo])
+	return t.Text[start:end] + textEllipsis, end
+}
+
+// drawTextLayout draws a TextLayout with FillText calls, which is how every
+// DrawContext implements DrawTextLayout.
+func drawTextLayout(ctx DrawContext, t *TextLayout, x, y float64) {
+	lines := t.Lines(ctx)
+	ctx.Save()
+	ctx.SetFontDescriptor(t.Font)
+	ctx.SetTextAlign(TextAlignLeft)
+	ctx.SetTextBaseline(TextBaselineAlphabetic)
+	for _, line := range lines {
+		ctx.FillText(line.Text, x+line.Bounds.X, y+line.Baseline)
+	}
+	ctx.Restore()
+}
+
+// A lineBreaker fills lines greedily with words and characters.
+type lineBreaker struct {
+	text     string
+	widths   *layoutWidths
+	maxWidth float64
+
+	// lines holds the ranges of the lines which are finished.
+	lines [][2]int
+
+	// lineStart and lineEnd are the range of the current line, without
+	// the spaces after it, and lineWidth is its width.
+	lineStart int
+	lineEnd   int
+	lineWidth float64
+}
+
+// addWord adds the spaces from start to wordStart and then the word from
+// wordStart to wordEnd, starting a new line before the word if it does not
+// fit.
+func (b *lineBreaker) addWord(start, wordStart, wordEnd int) {
+	spaces := b.widths.width(b.text[start:wordStart])
+	word := b.widths.width(b.text[wordStart:wordEnd])
+	if b.lineWidth+spaces+word <= b.maxWidth {
+		b.lineEnd = wordEnd
+		b.lineWidth += spaces + word
+		return
+	}
+	if b.lineEnd > b.lineStart {
+		b.newLine(wordStart)
+		spaces = 0
+	}
+	if word <= b.maxWidth-b.lineWidth-spaces {
+		b.lineEnd = wordEnd
+		b.lineWidth += spaces + word
+	} else {
+		b.lineWidth += spaces
+		b.addClusters(wordStart, wordEnd)
+	}
+}
+
+// addClusters adds text one character at a time, starting a new line
+// whenever a character does not fit. Spaces at the end of a line are dropped.
+// Every line gets at least one character.
+func (b *lineBreaker) addClusters(start, end int) {
+	var clusters []string
+	for i := start; i < end; {
+		next := nextCluster(b.text, i, end)
+		clusters = append(clusters, b.text[i:next])
+		i = next
+	}
+	b.widths.prefetch(clusters)
+	for i := start; i < end; {
+		next := nextCluster(b.text, i, end)
+		r, _ := utf8.DecodeRuneInString(b.text[i:])
+		width := b.widths.width(b.text[i:next])
+		if b.lineWidth+width > b.maxWidth && b.lineEnd > b.lineStart {
+			if isBreakingSpace(r) {
+				b.newLine(next)
+				i = next
+				continue
+			}
+			b.newLine(i)
+		}
+		b.lineEnd = next
+		b.lineWidth += width
+		i = next
+	}
+}
+
+// newLine finishes the current line and starts the next one at start.
+func (b *lineBreaker) newLine(start int) {
+	end := b.lineStart + len(strings.TrimRightFunc(
+		b.text[b.lineStart:b.lineEnd], isBreakingSpace))
+	b.lines = append(b.lines, [2]int{b.lineStart, end})
+	b.lineStart, b.lineEnd, b.lineWidth = start, start, 0
+}
+
+// layoutWidths measures the widths of strings in a font, remembering them so
+// that drivers which measure slowly are asked as little as possible.
+type layoutWidths struct {
+	measure textMeasurer
+	font    Font
+	cache   map[string]float64
+}
+
+// prefetch measures every string which is not cached yet in one go, so that
+// width does not have to measure them one at a time.
+func (l *layoutWidths) prefetch(texts []string) {
+	var missing []string
+	for _, text := range texts {
+		if _, ok := l.cache[text]; !ok {
+			l.cache[text] = 0
+			missing = append(missing, text)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	for i, w := range measureWidths(l.measure, missing, l.font) {
+		l.cache[missing[i]] = w
+	}
+}
+
+func (l *layoutWidths) width(text string) float64 {
+	if w, ok := l.cache[text]; ok {
+		return w
+	}
+	w, _ := l.measure.textSize(text, l.font)
+	l.cache[text] = w
+	return w
+}
+
+// A contextMeasurer is a textMeasurer which measures text by switching the
+// font of a DrawContext.
+type contextMeasurer struct {
+	ctx DrawContext
+}
+
+func (c contextMeasurer) fontMetrics(f Font) FontMetrics {
+	c.ctx.Save()
+	defer c.ctx.Restore()
+	c.ctx.SetFontDescriptor(f)
+	return c.ctx.FontMetrics()
+}
+
+func (c contextMeasurer) textSize(text string, f Font) (float64, float64) {
+	c.ctx.Save()
+	defer c.ctx.Restore()
+	c.ctx.SetFontDescriptor(f)
+	return c.ctx.TextSize(text)
+}
+
+// layoutMeasurer returns a textMeasurer which measures text like ctx. The
+// context is used directly if it can measure any font.
+func layoutMeasurer(ctx DrawContext) textMeasurer {
+	if m, ok := ctx.(textMeasurer); ok {
+		return m
+	}
+	return contextMeasurer{ctx}
+}
+
+// measureWidths returns the width of each string in a font. The strings are
+// measured all at once if the measurer can do that.
+func measureWidths(m textMeasurer, texts []string, f Font) []float64 {
+	if b, ok := m.(batchMeasurer); ok {
+		return b.textWidths(texts, f)
+	}
+	res := make([]float64, len(texts))
+	for i, text := range texts {
+		res[i], _ = m.textSize(text, f)
+	}
+	return res
+}
+
+// isBreakingSpace returns true for the spaces which lines may be broken at.
+func isBreakingSpace(r rune) bool {
+	return unicode.IsSpace(r) && r != '\u00a0' && r != '\u202f'
+}
+
+// skipSpaces returns the offset of the first character from i to end which is
+// not a breaking space, or end if there is none.
+func skipSpaces(text string, i, end int) int {
+	for i < end {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isBreakingSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
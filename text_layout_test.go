@@ -0,0 +1,91 @@
+package gogui
+
+import (
+	"image"
+	"testing"
+)
+
+func TestTextLayoutLines(t *testing.T) {
+	// Each character of the built-in font is 6 units wide at size 10, and
+	// each line is 11 units tall.
+	ctx := newImageContext(image.NewRGBA(image.Rect(0, 0, 1, 1)),
+		IdentityMatrix())
+	for _, test := range []struct {
+		text     string
+		wrap     TextWrap
+		maxWidth float64
+		maxLines int
+		lines    []string
+	}{
+		{"hello big world\nx", TextWrapWord, 60, 0,
+			[]string{"hello big", "world", "x"}},
+		{"abcdefghijklmno pq", TextWrapWord, 60, 0,
+			[]string{"abcdefghij", "klmno pq"}},
+		{"abcdefghi jklm", TextWrapCharacter, 60, 0,
+			[]string{"abcdefghi", "jklm"}},
+		{"a\r\n\nb", TextWrapWord, 0, 0, []string{"a", "", "b"}},
+		{"aa bb cc dd ee ff", TextWrapWord, 30, 2,
+			[]string{"aa bb", "cc d" + textEllipsis}},
+		{"abcdefgh\nab", TextWrapNone, 30, 0,
+			[]string{"abcd" + textEllipsis, "ab"}},
+		{"abc", TextWrapNone, 30, 0, []string{"abc"}},
+	} {
+		l := NewTextLayout(test.text, Font{Size: 10}, test.maxWidth)
+		l.Wrap = test.wrap
+		l.MaxLines = test.maxLines
+		lines := l.Lines(ctx)
+		if len(lines) != len(test.lines) {
+			t.Errorf("%q: got %d lines", test.text, len(lines))
+			continue
+		}
+		for i, line := range lines {
+			if line.Text != test.lines[i] {
+				t.Errorf("%q: line %d is %q", test.text, i, line.Text)
+			}
+		}
+	}
+}
+
+func TestTextLayoutBounds(t *testing.T) {
+	ctx := newImageContext(image.NewRGBA(image.Rect(0, 0, 1, 1)),
+		IdentityMatrix())
+	l := NewTextLayout("hello big world", Font{Size: 10}, 60)
+	line := l.Lines(ctx)[1]
+	if line.Start != 10 || line.End != 15 || line.Bounds.Y != 11 ||
+		line.Baseline != 19 || line.Bounds.Width != 30 {
+		t.Error(line)
+	}
+
+	l = NewTextLayout("aa bb cc dd ee", Font{Size: 10}, 30)
+	l.MaxLines = 2
+	line = l.Lines(ctx)[1]
+	if !line.Ellipsis || line.End != 10 {
+		t.Error("ellipsis:", line)
+	}
+
+	l = NewTextLayout("abcd\nab", Font{Size: 10}, 30)
+	l.Align = TextAlignRight
+	if line = l.Lines(ctx)[1]; line.Bounds.X != 18 {
+		t.Error("alignment:", line)
+	}
+
+	l = NewTextLayout("", Font{Size: 10}, 0)
+	if b := l.Bounds(ctx); b.Height != 11 {
+		t.Error("empty:", b)
+	}
+	l.Text = "a\nb"
+	l.LineSpacing = 2
+	if b := l.Bounds(ctx); b.Height != 33 {
+		t.Error("line spacing:", b)
+	}
+}
+
+func TestTextLayoutBatch(t *testing.T) {
+	m := &batchCounter{}
+	l := NewTextLayout("the quick brown fox jumps over the lazy dog",
+		Font{Size: 10}, 60)
+	l.Lines(newDrawRecorder(m))
+	if m.calls > 2 {
+		t.Error("expected at most 2 batches but got", m.calls)
+	}
+}
@@ -0,0 +1,188 @@
+package gogui
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Grapheme is one character of a string as a user sees it, such as a letter
+// with its accents or an emoji made of several code points.
+type Grapheme struct {
+	// Start and End are the byte offsets of the grapheme in the string.
+	Start int
+	End   int
+
+	// X is the distance from the left edge of the string to the left edge
+	// of the grapheme.
+	X float64
+
+	// Advance is how far the grapheme moves the pen, including the kerning
+	// between it and the grapheme before it.
+	Advance float64
+}
+
+// TextPositions tells where each grapheme of a line of text is drawn, for
+// placing a caret and for hit testing.
+//
+// Every x coordinate is relative to the left edge of the text, as TextSize
+// measures it. That is the point given to FillText if the text alignment is
+// TextAlignLeft.
+type TextPositions struct {
+	Text      string
+	Graphemes []Grapheme
+}
+
+// CaretX returns the x coordinate of a caret before a byte offset of the text.
+// An offset inside of a grapheme is moved to the start of that grapheme, and
+// offsets past the end of the text are moved to the end. To use a rune index,
+// convert it with len(string([]rune(text)[:index])).
+func (t *TextPositions) CaretX(index int) float64 {
+	for _, g := range t.Graphemes {
+		if index < g.End {
+			return g.X
+		}
+	}
+	return t.Width()
+}
+
+// IndexAt returns the byte offset of the caret position which is closest to
+// an x coordinate.
+func (t *TextPositions) IndexAt(x float64) int {
+	for _, g := range t.Graphemes {
+		if x < g.X+g.Advance/2 {
+			return g.Start
+		}
+	}
+	return len(t.Text)
+}
+
+// Width returns the width of the whole text.
+func (t *TextPositions) Width() float64 {
+	if len(t.Graphemes) == 0 {
+		return 0
+	}
+	last := t.Graphemes[len(t.Graphemes)-1]
+	return last.X + last.Advance
+}
+
+// Caret returns the position of a caret before a byte offset of the layout's
+// Text, as a rectangle with no width which goes from the top to the bottom of
+// its line. Offsets which are not shown, such as those cut off by an ellipsis,
+// are moved to the end of the closest line before them.
+func (t *TextLayout) Caret(ctx DrawContext, index int) Rect {
+	m := layoutMeasurer(ctx)
+	lines := t.Lines(ctx)
+	line := lines[0]
+	for _, l := range lines[1:] {
+		if l.Start > index {
+			break
+		}
+		line = l
+	}
+	if index > line.End {
+		index = line.End
+	}
+	p := textPositions(m, t.Font, line.Text)
+	x := line.Bounds.X + p.CaretX(index-line.Start)
+	return Rect{x, line.Bounds.Y, 0, line.Bounds.Height}
+}
+
+// IndexAt returns the byte offset of the layout's Text where a caret would go
+// for a click at a point. The point is relative to the top-left corner of the
+// layout. Points above or below every line hit the first or last line.
+func (t *TextLayout) IndexAt(ctx DrawContext, x, y float64) int {
+	m := layoutMeasurer(ctx)
+	lines := t.Lines(ctx)
+	line := lines[len(lines)-1]
+	for i, l := range lines[:len(lines)-1] {
+		// Points in the space between two lines hit the closer one.
+		bottom := l.Bounds.Y + l.Bounds.Height
+		if y < (bottom+lines[i+1].Bounds.Y)/2 {
+			line = l
+			break
+		}
+	}
+	p := textPositions(m, t.Font, line.Text)
+	index := line.Start + p.IndexAt(x-line.Bounds.X)
+	if index > line.End {
+		// The ellipsis is not part of the text.
+		index = line.End
+	}
+	return index
+}
+
+// textPositions finds the graphemes of text in a font. Each grapheme is placed
+// by measuring the text before it, so kerning and ligatures are the same as
+// when the whole text is drawn.
+func textPositions(m textMeasurer, f Font, text string) *TextPositions {
+	res := &TextPositions{Text: text}
+	var ends []int
+	for i := 0; i < len(text); {
+		i = nextCluster(text, i, len(text))
+		ends = append(ends, i)
+	}
+	widths := measurePrefixes(m, text, ends, f)
+	var start int
+	var x float64
+	for i, end := range ends {
+		advance := math.Max(0, widths[i]-x)
+		res.Graphemes = append(res.Graphemes, Grapheme{start, end, x, advance})
+		x += advance
+		start = end
+	}
+	return res
+}
+
+// measurePrefixes returns the widths of text[:end] for each of the ends. The
+// prefixes are measured all at once if the measurer can do that.
+func measurePrefixes(m textMeasurer, text string, ends []int,
+	f Font) []float64 {
+	if p, ok := m.(prefixMeasurer); ok {
+		return p.prefixWidths(text, ends, f)
+	}
+	prefixes := make([]string, len(ends))
+	for i, end := range ends {
+		prefixes[i] = text[:end]
+	}
+	return measureWidths(m, prefixes, f)
+}
+
+// nextCluster returns the offset after the grapheme which starts at i, not
+// going past end. Combining marks, variation selectors, emoji modifiers and
+// zero width joiners stay with the character before them, and pairs of
+// regional indicators make up flags.
+func nextCluster(text string, i, end int) int {
+	r, size := utf8.DecodeRuneInString(text[i:end])
+	i += size
+	if r == '\r' && i < end && text[i] == '\n' {
+		return i + 1
+	}
+	indicators := 0
+	if isRegionalIndicator(r) {
+		indicators = 1
+	}
+	for i < end {
+		prev := r
+		r, size = utf8.DecodeRuneInString(text[i:end])
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc,
+			unicode.Variation_Selector), r == '\u200d', isEmojiModifier(r):
+		case prev == '\u200d' && !unicode.IsSpace(r):
+		case indicators == 1 && isRegionalIndicator(r):
+			indicators = 2
+		default:
+			return i
+		}
+		i += size
+	}
+	return i
+}
+
+func isEmojiModifier(r rune) bool {
+	return r >= 0x1f3fb && r <= 0x1f3ff
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
@@ -0,0 +1,89 @@
+package gogui
+
+import (
+	"image"
+	"testing"
+)
+
+type kernMeasurer struct {
+	softMeasurer
+}
+
+// textSize pretends that "AV" is kerned by 2 units.
+func (kernMeasurer) textSize(text string, f Font) (float64, float64) {
+	w, h := softTextSize(text, f)
+	for i := 0; i+1 < len(text); i++ {
+		if text[i:i+2] == "AV" {
+			w -= 2
+		}
+	}
+	return w, h
+}
+
+func TestTextPositions(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	ctx := newImageContext(img, IdentityMatrix())
+	ctx.SetFont(10, "x")
+	p := ctx.TextPositions("ae\u0301b\U0001f1eb\U0001f1f7x\U0001f44d\U0001f3fd")
+	if len(p.Graphemes) != 6 {
+		t.Fatal(p.Graphemes)
+	}
+	g := p.Graphemes[1]
+	if g.Start != 1 || g.End != 4 || g.X != 6 || g.Advance != 6 {
+		t.Error(g)
+	}
+	if g := p.Graphemes[4]; g.Start != 13 || g.End != 14 {
+		t.Error(g)
+	}
+	if x := p.CaretX(2); x != 6 {
+		t.Error(x)
+	}
+	if x := p.CaretX(100); x != p.Width() {
+		t.Error(x)
+	}
+	if i := p.IndexAt(8.9); i != 1 {
+		t.Error(i)
+	}
+	if i := p.IndexAt(9.1); i != 4 {
+		t.Error(i)
+	}
+	if i := p.IndexAt(1000); i != len(p.Text) {
+		t.Error(i)
+	}
+	k := textPositions(kernMeasurer{}, Font{Size: 10}, "AVA")
+	if k.Graphemes[1].Advance != 4 || k.Graphemes[2].X != 10 {
+		t.Error(k.Graphemes)
+	}
+}
+
+// A batchCounter is a batchMeasurer which counts how often it is asked to
+// measure text.
+type batchCounter struct {
+	softMeasurer
+	calls int
+}
+
+func (b *batchCounter) textSize(text string, f Font) (float64, float64) {
+	b.calls++
+	return softTextSize(text, f)
+}
+
+func (b *batchCounter) textWidths(texts []string, f Font) []float64 {
+	b.calls++
+	res := make([]float64, len(texts))
+	for i, text := range texts {
+		res[i], _ = softTextSize(text, f)
+	}
+	return res
+}
+
+func TestTextPositionsBatch(t *testing.T) {
+	m := &batchCounter{}
+	p := textPositions(m, Font{Size: 10}, "hello, world")
+	if m.calls != 1 {
+		t.Error("expected one batch but got", m.calls)
+	}
+	if len(p.Graphemes) != 12 || p.Width() != 72 {
+		t.Error(p.Graphemes)
+	}
+}
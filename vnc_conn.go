@@ -0,0 +1,418 @@
+// +build vnc
+// +build !js
+
+package gogui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"net"
+)
+
+// Client to server message types.
+const (
+	vncMsgSetPixelFormat = 0
+	vncMsgSetEncodings   = 2
+	vncMsgUpdateRequest  = 3
+	vncMsgKeyEvent       = 4
+	vncMsgPointerEvent   = 5
+	vncMsgClientCutText  = 6
+)
+
+// vncMaxCutText limits the size of clipboard text from clients, which is
+// read and ignored.
+const vncMaxCutText = 1 << 20
+
+// A vncClient is a connected VNC viewer.
+//
+// The handshake and incoming messages are read on the connection's goroutine,
+// and messages are written by a goroutine of their own. Everything else is
+// done on the main goroutine.
+type vncClient struct {
+	host     *vncHost
+	conn     net.Conn
+	reader   *bufio.Reader
+	outgoing chan []byte
+
+	format    vncPixelFormat
+	encodings map[int32]bool
+	zrle      *zrleEncoder
+
+	// requested is true if the client is waiting for a FramebufferUpdate.
+	requested bool
+
+	// shadow holds what the client has been sent, or is nil if the client
+	// needs the whole screen. frames holds the window frames as of shadow.
+	shadow *image.RGBA
+	frames map[*softWindow]image.Rectangle
+
+	cursorSent bool
+	buttons    uint8
+
+	// closed is set once the client has been removed from its host.
+	closed bool
+}
+
+// newVNCClient performs the RFB handshake. It supports versions 3.3, 3.7,
+// and 3.8 of the protocol, without authentication.
+func newVNCClient(v *vncHost, conn net.Conn) (*vncClient, error) {
+	c := &vncClient{
+		host:      v,
+		conn:      conn,
+		reader:    bufio.NewReader(conn),
+		outgoing:  make(chan []byte, 16),
+		format:    vncDefaultFormat,
+		encodings: map[int32]bool{},
+		zrle:      newZRLEEncoder(),
+	}
+	if _, err := conn.Write([]byte("RFB 003.008\n")); err != nil {
+		return nil, err
+	}
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(c.reader, version); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(version, []byte("RFB 003.")) {
+		return nil, errors.New("unknown protocol version")
+	}
+	minor := int(version[9]-'0')*10 + int(version[10]-'0')
+	if minor < 7 {
+		// Version 3.3 lets the server choose the security type.
+		if _, err := conn.Write([]byte{0, 0, 0, 1}); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write([]byte{1, 1}); err != nil {
+			return nil, err
+		}
+		choice, err := c.reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if choice != 1 {
+			return nil, errors.New("unsupported security type")
+		}
+		if minor >= 8 {
+			if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// ClientInit holds a shared flag, which does not matter since every
+	// client shares the desktop.
+	if _, err := c.reader.ReadByte(); err != nil {
+		return nil, err
+	}
+	init := make([]byte, 4, 24+len(v.name))
+	binary.BigEndian.PutUint16(init[0:], uint16(v.width))
+	binary.BigEndian.PutUint16(init[2:], uint16(v.height))
+	init = append(init, c.format.encode()...)
+	var nameLength [4]byte
+	binary.BigEndian.PutUint32(nameLength[:], uint32(len(v.name)))
+	init = append(init, nameLength[:]...)
+	init = append(init, v.name...)
+	if _, err := conn.Write(init); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for msg := range c.outgoing {
+			if _, err := conn.Write(msg); err != nil {
+				conn.Close()
+			}
+		}
+	}()
+	return c, nil
+}
+
+// readMessages reads client messages until the connection fails, handling
+// each one on the main goroutine.
+func (c *vncClient) readMessages() {
+	a := c.host.app
+	for {
+		msgType, err := c.reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch msgType {
+		case vncMsgSetPixelFormat:
+			data, err := c.read(19)
+			if err != nil {
+				return
+			}
+			format, ok := parseVNCPixelFormat(data[3:])
+			if !ok {
+				return
+			}
+			a.RunOnMain(func() {
+				c.format = format
+				c.shadow = nil
+			})
+		case vncMsgSetEncodings:
+			header, err := c.read(3)
+			if err != nil {
+				return
+			}
+			data, err := c.read(4 * int(binary.BigEndian.Uint16(header[1:])))
+			if err != nil {
+				return
+			}
+			encodings := map[int32]bool{}
+			for i := 0; i < len(data); i += 4 {
+				encodings[int32(binary.BigEndian.Uint32(data[i:]))] = true
+			}
+			a.RunOnMain(func() {
+				c.encodings = encodings
+				c.cursorSent = false
+			})
+		case vncMsgUpdateRequest:
+			data, err := c.read(9)
+			if err != nil {
+				return
+			}
+			incremental := data[0] != 0
+			a.RunOnMain(func() {
+				if !incremental {
+					c.shadow = nil
+				}
+				c.requested = true
+				c.sendUpdate()
+			})
+		case vncMsgKeyEvent:
+			data, err := c.read(7)
+			if err != nil {
+				return
+			}
+			down := data[0] != 0
+			sym := binary.BigEndian.Uint32(data[3:])
+			a.RunOnMain(func() {
+				c.host.handleKey(down, sym)
+			})
+		case vncMsgPointerEvent:
+			data, err := c.read(5)
+			if err != nil {
+				return
+			}
+			buttons := data[0]
+			x := float64(binary.BigEndian.Uint16(data[1:]))
+			y := float64(binary.BigEndian.Uint16(data[3:]))
+			a.RunOnMain(func() {
+				c.handlePointer(buttons, x, y)
+			})
+		case vncMsgClientCutText:
+			data, err := c.read(7)
+			if err != nil {
+				return
+			}
+			length := binary.BigEndian.Uint32(data[3:])
+			if length > vncMaxCutText {
+				return
+			}
+			if _, err := c.read(int(length)); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (c *vncClient) read(n int) ([]byte, error) {
+	data := make([]byte, n)
+	_, err := io.ReadFull(c.reader, data)
+	return data, err
+}
+
+// handlePointer turns a PointerEvent into mouse events. Like on other
+// platforms, only the left button is reported.
+func (c *vncClient) handlePointer(buttons uint8, x, y float64) {
+	wasDown := (c.buttons & 1) != 0
+	isDown := (buttons & 1) != 0
+	c.buttons = buttons
+	switch {
+	case isDown && !wasDown:
+		c.host.desktop.mouse(mouseEventDown, x, y)
+	case !isDown && wasDown:
+		c.host.desktop.mouse(mouseEventUp, x, y)
+	case isDown:
+		c.host.desktop.mouse(mouseEventDrag, x, y)
+	default:
+		c.host.desktop.mouse(mouseEventMove, x, y)
+	}
+}
+
+// sendUpdate sends a FramebufferUpdate if the client has asked for one and
+// the screen has changed since the last one.
+func (c *vncClient) sendUpdate() {
+	screen := c.host.screen
+	if !c.requested || screen == nil || c.closed {
+		return
+	}
+	if c.shadow != nil && c.shadow.Rect != screen.Rect {
+		c.shadow = nil
+	}
+
+	var msg []byte
+	count := 0
+	if c.encodings[vncEncodingCursor] && !c.cursorSent {
+		msg = c.appendCursor(msg)
+		count++
+		c.cursorSent = true
+	}
+	if c.shadow == nil {
+		c.shadow = image.NewRGBA(screen.Rect)
+		msg = c.appendPixels(msg, screen.Rect)
+		count++
+	} else {
+		if c.encodings[vncEncodingCopyRect] {
+			for w, dst := range c.host.frames {
+				src, ok := c.frames[w]
+				if ok && src != dst && src.Size() == dst.Size() &&
+					c.copyRect(src, dst) {
+					msg = appendRectHeader(msg, dst.Intersect(screen.Rect),
+						vncEncodingCopyRect)
+					msg = append(msg, byte(src.Min.X>>8), byte(src.Min.X),
+						byte(src.Min.Y>>8), byte(src.Min.Y))
+					count++
+				}
+			}
+		}
+		for _, r := range c.changedRects() {
+			msg = c.appendPixels(msg, r)
+			count++
+		}
+	}
+	c.frames = c.host.frames
+	if count == 0 {
+		return
+	}
+	header := []byte{0, 0, byte(count >> 8), byte(count)}
+	c.requested = false
+	select {
+	case c.outgoing <- append(header, msg...):
+	default:
+		c.conn.Close()
+	}
+}
+
+// copyRect checks if a window's pixels on screen can be produced by copying
+// what the client shows at its old position. If so, the copy is applied to
+// the shadow image and copyRect returns true.
+func (c *vncClient) copyRect(src, dst image.Rectangle) bool {
+	screen := c.host.screen
+	offset := src.Min.Sub(dst.Min)
+	clipped := dst.Intersect(screen.Rect).Intersect(
+		screen.Rect.Sub(offset))
+	if clipped.Empty() || clipped != dst {
+		// Only whole windows are copied, so that the source of the copy
+		// never depends on the part of the window which was offscreen.
+		return false
+	}
+	for y := dst.Min.Y; y < dst.Max.Y; y++ {
+		a := screen.Pix[screen.PixOffset(dst.Min.X, y):screen.PixOffset(
+			dst.Max.X, y)]
+		b := c.shadow.Pix[c.shadow.PixOffset(src.Min.X, y+offset.Y):c.shadow.
+			PixOffset(src.Max.X, y+offset.Y)]
+		if !bytes.Equal(a, b) {
+			return false
+		}
+	}
+	rows := make([][]byte, dst.Dy())
+	for y := range rows {
+		start := c.shadow.PixOffset(src.Min.X, src.Min.Y+y)
+		rows[y] = append([]byte{}, c.shadow.Pix[start:start+src.Dx()*4]...)
+	}
+	for y, row := range rows {
+		copy(c.shadow.Pix[c.shadow.PixOffset(dst.Min.X, dst.Min.Y+y):], row)
+	}
+	return true
+}
+
+// changedRects finds the tiles in which the screen differs from the shadow
+// image and merges them into rows of rectangles.
+func (c *vncClient) changedRects() []image.Rectangle {
+	screen := c.host.screen
+	bounds := screen.Rect
+	var res []image.Rectangle
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += vncTileSize {
+		var run image.Rectangle
+		for x := bounds.Min.X; x < bounds.Max.X; x += vncTileSize {
+			tile := image.Rect(x, y, x+vncTileSize,
+				y+vncTileSize).Intersect(bounds)
+			if !c.tileChanged(tile) {
+				if !run.Empty() {
+					res = append(res, run)
+					run = image.Rectangle{}
+				}
+				continue
+			}
+			if run.Empty() {
+				run = tile
+			} else {
+				run.Max.X = tile.Max.X
+			}
+		}
+		if !run.Empty() {
+			res = append(res, run)
+		}
+	}
+	return res
+}
+
+func (c *vncClient) tileChanged(tile image.Rectangle) bool {
+	screen := c.host.screen
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		start := screen.PixOffset(tile.Min.X, y)
+		end := screen.PixOffset(tile.Max.X, y)
+		if !bytes.Equal(screen.Pix[start:end], c.shadow.Pix[start:end]) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendPixels appends a rectangle of the screen in the best encoding which
+// the client supports, and copies the rectangle into the shadow image.
+func (c *vncClient) appendPixels(msg []byte, r image.Rectangle) []byte {
+	screen := c.host.screen
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		start := screen.PixOffset(r.Min.X, y)
+		end := screen.PixOffset(r.Max.X, y)
+		copy(c.shadow.Pix[start:end], screen.Pix[start:end])
+	}
+	if c.encodings[vncEncodingZRLE] {
+		msg = appendRectHeader(msg, r, vncEncodingZRLE)
+		return c.zrle.appendRect(msg, screen, r, c.format)
+	}
+	msg = appendRectHeader(msg, r, vncEncodingRaw)
+	return appendRaw(msg, screen, r, c.format)
+}
+
+// appendCursor appends a Cursor pseudo-encoding rectangle with an arrow, so
+// that the viewer can draw the cursor itself.
+func (c *vncClient) appendCursor(msg []byte) []byte {
+	const width, height = 14, 21
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	drawSoftCursor(img, 1, 1)
+
+	// The position of a cursor rectangle is its hot spot.
+	msg = appendRectHeader(msg, image.Rect(1, 1, width+1, height+1),
+		vncEncodingCursor)
+	msg = appendRaw(msg, img, img.Rect, c.format)
+	rowSize := (width + 7) / 8
+	for y := 0; y < height; y++ {
+		row := make([]byte, rowSize)
+		for x := 0; x < width; x++ {
+			if img.Pix[img.PixOffset(x, y)+3] >= 0x80 {
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+		msg = append(msg, row...)
+	}
+	return msg
+}
@@ -0,0 +1,312 @@
+// +build vnc
+// +build !js
+
+package gogui
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+)
+
+// Encodings from RFC 6143.
+const (
+	vncEncodingRaw      = 0
+	vncEncodingCopyRect = 1
+	vncEncodingZRLE     = 16
+	vncEncodingCursor   = -239
+)
+
+// vncTileSize is the size of the tiles which are compared to find the parts
+// of the screen which changed. ZRLE uses tiles of the same size.
+const vncTileSize = 64
+
+// A vncPixelFormat is the PIXEL_FORMAT structure which describes how a client
+// wants pixels to be encoded. Only true color formats are supported.
+type vncPixelFormat struct {
+	bitsPerPixel uint8
+	depth        uint8
+	bigEndian    bool
+	redMax       uint16
+	greenMax     uint16
+	blueMax      uint16
+	redShift     uint8
+	greenShift   uint8
+	blueShift    uint8
+}
+
+// vncDefaultFormat is the format which the server announces.
+var vncDefaultFormat = vncPixelFormat{
+	bitsPerPixel: 32,
+	depth:        24,
+	redMax:       255,
+	greenMax:     255,
+	blueMax:      255,
+	redShift:     16,
+	greenShift:   8,
+	blueShift:    0,
+}
+
+// parseVNCPixelFormat decodes a PIXEL_FORMAT structure. It returns false if
+// the format is not supported.
+func parseVNCPixelFormat(data []byte) (vncPixelFormat, bool) {
+	f := vncPixelFormat{
+		bitsPerPixel: data[0],
+		depth:        data[1],
+		bigEndian:    data[2] != 0,
+		redMax:       binary.BigEndian.Uint16(data[4:]),
+		greenMax:     binary.BigEndian.Uint16(data[6:]),
+		blueMax:      binary.BigEndian.Uint16(data[8:]),
+		redShift:     data[10],
+		greenShift:   data[11],
+		blueShift:    data[12],
+	}
+	trueColor := data[3] != 0
+	switch f.bitsPerPixel {
+	case 8, 16, 32:
+	default:
+		return f, false
+	}
+	return f, trueColor
+}
+
+func (f vncPixelFormat) encode() []byte {
+	res := make([]byte, 16)
+	res[0] = f.bitsPerPixel
+	res[1] = f.depth
+	if f.bigEndian {
+		res[2] = 1
+	}
+	res[3] = 1
+	binary.BigEndian.PutUint16(res[4:], f.redMax)
+	binary.BigEndian.PutUint16(res[6:], f.greenMax)
+	binary.BigEndian.PutUint16(res[8:], f.blueMax)
+	res[10] = f.redShift
+	res[11] = f.greenShift
+	res[12] = f.blueShift
+	return res
+}
+
+func (f vncPixelFormat) bytesPerPixel() int {
+	return int(f.bitsPerPixel) / 8
+}
+
+// value returns the value of a pixel in this format.
+func (f vncPixelFormat) value(r, g, b uint8) uint32 {
+	scale := func(x uint8, max uint16) uint32 {
+		return (uint32(x)*uint32(max) + 127) / 255
+	}
+	return scale(r, f.redMax)<<f.redShift |
+		scale(g, f.greenMax)<<f.greenShift |
+		scale(b, f.blueMax)<<f.blueShift
+}
+
+// appendValue appends the bytes of a pixel value. Only the bytes from start
+// to end are appended, which lets ZRLE send CPIXELs.
+func (f vncPixelFormat) appendValue(dest []byte, value uint32,
+	start, end int) []byte {
+	size := f.bytesPerPixel()
+	for i := start; i < end; i++ {
+		shift := uint(8 * i)
+		if f.bigEndian {
+			shift = uint(8 * (size - 1 - i))
+		}
+		dest = append(dest, byte(value>>shift))
+	}
+	return dest
+}
+
+// compactRange returns the range of bytes in each pixel which ZRLE sends. A
+// CPIXEL drops the unused byte of 32-bit pixels when the colors fit in three
+// bytes.
+func (f vncPixelFormat) compactRange() (start, end int) {
+	size := f.bytesPerPixel()
+	if size != 4 || f.depth > 24 {
+		return 0, size
+	}
+	highest := func(max uint16, shift uint8) uint {
+		bits := uint(0)
+		for max>>bits != 0 {
+			bits++
+		}
+		return uint(shift) + bits
+	}
+	top := highest(f.redMax, f.redShift)
+	if x := highest(f.greenMax, f.greenShift); x > top {
+		top = x
+	}
+	if x := highest(f.blueMax, f.blueShift); x > top {
+		top = x
+	}
+	lowest := f.redShift
+	if f.greenShift < lowest {
+		lowest = f.greenShift
+	}
+	if f.blueShift < lowest {
+		lowest = f.blueShift
+	}
+	switch {
+	case top <= 24:
+		// The colors are in the least significant three bytes.
+		if f.bigEndian {
+			return 1, 4
+		}
+		return 0, 3
+	case lowest >= 8:
+		// The colors are in the most significant three bytes.
+		if f.bigEndian {
+			return 0, 3
+		}
+		return 1, 4
+	}
+	return 0, 4
+}
+
+// appendRectHeader appends the header of a rectangle in a FramebufferUpdate.
+func appendRectHeader(dest []byte, r image.Rectangle, encoding int32) []byte {
+	var header [12]byte
+	binary.BigEndian.PutUint16(header[0:], uint16(r.Min.X))
+	binary.BigEndian.PutUint16(header[2:], uint16(r.Min.Y))
+	binary.BigEndian.PutUint16(header[4:], uint16(r.Dx()))
+	binary.BigEndian.PutUint16(header[6:], uint16(r.Dy()))
+	binary.BigEndian.PutUint32(header[8:], uint32(encoding))
+	return append(dest, header[:]...)
+}
+
+// appendRaw appends the pixels of a rectangle with the raw encoding.
+func appendRaw(dest []byte, img *image.RGBA, r image.Rectangle,
+	f vncPixelFormat) []byte {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		idx := img.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			value := f.value(img.Pix[idx], img.Pix[idx+1], img.Pix[idx+2])
+			dest = f.appendValue(dest, value, 0, f.bytesPerPixel())
+			idx += 4
+		}
+	}
+	return dest
+}
+
+// A zrleEncoder encodes rectangles with ZRLE. Every rectangle sent on a
+// connection shares one zlib stream, so a connection needs its own encoder.
+type zrleEncoder struct {
+	buffer bytes.Buffer
+	writer *zlib.Writer
+}
+
+func newZRLEEncoder() *zrleEncoder {
+	z := &zrleEncoder{}
+	z.writer = zlib.NewWriter(&z.buffer)
+	return z
+}
+
+// appendRect appends the ZRLE data for a rectangle, including its length.
+func (z *zrleEncoder) appendRect(dest []byte, img *image.RGBA,
+	r image.Rectangle, f vncPixelFormat) []byte {
+	start, end := f.compactRange()
+	var tile []byte
+	for y := r.Min.Y; y < r.Max.Y; y += vncTileSize {
+		for x := r.Min.X; x < r.Max.X; x += vncTileSize {
+			t := image.Rect(x, y, x+vncTileSize, y+vncTileSize).Intersect(r)
+			tile = zrleTile(tile[:0], img, t, f, start, end)
+			z.writer.Write(tile)
+		}
+	}
+	z.writer.Flush()
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(z.buffer.Len()))
+	dest = append(dest, length[:]...)
+	dest = append(dest, z.buffer.Bytes()...)
+	z.buffer.Reset()
+	return dest
+}
+
+// zrleTile encodes one tile as a solid color, a packed palette, plain RLE, or
+// raw pixels, whichever applies and is smallest.
+func zrleTile(dest []byte, img *image.RGBA, r image.Rectangle,
+	f vncPixelFormat, start, end int) []byte {
+	size := end - start
+	pixels := make([]uint32, 0, r.Dx()*r.Dy())
+	var palette []uint32
+	paletteIndex := map[uint32]int{}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		idx := img.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := f.value(img.Pix[idx], img.Pix[idx+1], img.Pix[idx+2])
+			pixels = append(pixels, p)
+			if _, ok := paletteIndex[p]; !ok && len(palette) <= 16 {
+				paletteIndex[p] = len(palette)
+				palette = append(palette, p)
+			}
+			idx += 4
+		}
+	}
+
+	if len(palette) == 1 {
+		dest = append(dest, 1)
+		return f.appendValue(dest, palette[0], start, end)
+	}
+
+	// Plain RLE encodes each run as a pixel followed by its length minus one,
+	// written in base 255 digits.
+	var rle []byte
+	for i := 0; i < len(pixels); {
+		j := i + 1
+		for j < len(pixels) && pixels[j] == pixels[i] {
+			j++
+		}
+		rle = f.appendValue(rle, pixels[i], start, end)
+		n := j - i - 1
+		for n >= 255 {
+			rle = append(rle, 255)
+			n -= 255
+		}
+		rle = append(rle, byte(n))
+		i = j
+	}
+	rawSize := len(pixels) * size
+
+	if len(palette) <= 16 {
+		bits := 4
+		if len(palette) == 2 {
+			bits = 1
+		} else if len(palette) <= 4 {
+			bits = 2
+		}
+		packedSize := len(palette)*size + (r.Dx()*bits+7)/8*r.Dy()
+		if packedSize <= len(rle) && packedSize <= rawSize {
+			dest = append(dest, byte(len(palette)))
+			for _, p := range palette {
+				dest = f.appendValue(dest, p, start, end)
+			}
+			for y := 0; y < r.Dy(); y++ {
+				var cur byte
+				used := 0
+				for x := 0; x < r.Dx(); x++ {
+					index := paletteIndex[pixels[y*r.Dx()+x]]
+					cur |= byte(index) << uint(8-bits-used)
+					used += bits
+					if used == 8 {
+						dest = append(dest, cur)
+						cur, used = 0, 0
+					}
+				}
+				if used > 0 {
+					dest = append(dest, cur)
+				}
+			}
+			return dest
+		}
+	}
+
+	if len(rle) < rawSize {
+		dest = append(dest, 128)
+		return append(dest, rle...)
+	}
+	dest = append(dest, 0)
+	for _, p := range pixels {
+		dest = f.appendValue(dest, p, start, end)
+	}
+	return dest
+}
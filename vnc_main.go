@@ -0,0 +1,206 @@
+// +build vnc
+// +build !js
+
+package gogui
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// vncDefaultAddr is the address the server listens on when the GOGUI_ADDR
+// environment variable is not set.
+const vncDefaultAddr = "127.0.0.1:5900"
+
+// vncTitleHeight is the height of the title bars on the virtual desktop.
+const vncTitleHeight = 22
+
+// The vnc driver starts a VNC server which shows the app's windows on a
+// virtual desktop.
+//
+// The server listens on the address in the GOGUI_ADDR environment variable,
+// or on 127.0.0.1:5900 if it is not set. It does not ask viewers for a
+// password. The desktop is 1280x800 unless the GOGUI_VNC_SIZE environment
+// variable gives another size, like "1024x768".
+func init() {
+	RegisterDriver("vnc", newSoftApp(newVNCHost()))
+}
+
+// A vncHost serves a virtual desktop to VNC viewers.
+type vncHost struct {
+	app     *softApp
+	desktop *softDesktop
+	clients []*vncClient
+
+	// name, width, and height are set before the server starts, after which
+	// they may be read by any goroutine.
+	name   string
+	width  int
+	height int
+
+	// screen is the most recently composited desktop.
+	screen *image.RGBA
+
+	// frames holds the pixel rectangle of each window on screen.
+	frames map[*softWindow]image.Rectangle
+
+	modifiers int
+}
+
+func newVNCHost() *vncHost {
+	return &vncHost{}
+}
+
+func (v *vncHost) available() bool {
+	return true
+}
+
+func (v *vncHost) start(a *softApp, info *AppInfo) error {
+	v.app = a
+	v.desktop = newSoftDesktop(a, vncTitleHeight, v.present)
+	v.name = "gogui"
+	if info != nil && info.Name != "" {
+		v.name = info.Name
+	}
+	v.width, v.height = 1280, 800
+	if size := os.Getenv("GOGUI_VNC_SIZE"); size != "" {
+		parts := strings.Split(size, "x")
+		if len(parts) != 2 {
+			return errors.New("invalid GOGUI_VNC_SIZE: " + size)
+		}
+		w, err1 := strconv.Atoi(parts[0])
+		h, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil || w <= 0 || h <= 0 ||
+			w > 0xffff || h > 0xffff {
+			return errors.New("invalid GOGUI_VNC_SIZE: " + size)
+		}
+		v.width, v.height = w, h
+	}
+	a.screen = Rect{0, 0, float64(v.width), float64(v.height)}
+
+	addr := os.Getenv("GOGUI_ADDR")
+	if addr == "" {
+		addr = vncDefaultAddr
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go v.serve(conn)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "gogui: VNC server listening on %s\n",
+		listener.Addr())
+	v.desktop.invalidate()
+	return nil
+}
+
+func (v *vncHost) showWindow(w *softWindow) {
+	v.desktop.invalidate()
+}
+
+func (v *vncHost) hideWindow(w *softWindow) {
+	v.desktop.windowHidden(w)
+}
+
+func (v *vncHost) updateWindow(w *softWindow) {
+	v.desktop.invalidate()
+}
+
+func (v *vncHost) drawWindow(w *softWindow) {
+	v.desktop.invalidate()
+}
+
+// present records a newly composited desktop and sends updates to the clients
+// which are waiting for them.
+func (v *vncHost) present(img *image.RGBA) {
+	v.screen = img
+	v.frames = map[*softWindow]image.Rectangle{}
+	for _, w := range v.app.windows {
+		f := v.desktop.outerFrame(w)
+		v.frames[w] = image.Rect(0, 0, int(f.Width+0.5),
+			int(f.Height+0.5)).Add(image.Pt(int(f.X+0.5), int(f.Y+0.5)))
+	}
+	for _, c := range v.clients {
+		c.sendUpdate()
+	}
+}
+
+// serve performs the handshake with a new connection and then reads its
+// messages until it closes.
+func (v *vncHost) serve(conn net.Conn) {
+	defer conn.Close()
+	c, err := newVNCClient(v, conn)
+	if err != nil {
+		return
+	}
+	v.app.RunOnMain(func() {
+		v.clients = append(v.clients, c)
+	})
+	c.readMessages()
+	v.app.RunOnMain(func() {
+		for i, x := range v.clients {
+			if x == c {
+				copy(v.clients[i:], v.clients[i+1:])
+				v.clients[len(v.clients)-1] = nil
+				v.clients = v.clients[:len(v.clients)-1]
+				c.closed = true
+				close(c.outgoing)
+				break
+			}
+		}
+	})
+}
+
+// handleKey handles a KeyEvent message from any client.
+func (v *vncHost) handleKey(down bool, sym uint32) {
+	flag := 0
+	switch sym {
+	case 0xffe1, 0xffe2:
+		flag = keyFlagShift
+	case 0xffe3, 0xffe4:
+		flag = keyFlagCtrl
+	case 0xffe9, 0xffea:
+		flag = keyFlagAlt
+	case 0xffe7, 0xffe8, 0xffeb, 0xffec:
+		flag = keyFlagMeta
+	}
+	if down {
+		v.modifiers |= flag
+	} else {
+		v.modifiers &^= flag
+	}
+
+	w := v.desktop.frontWindow()
+	if w == nil {
+		return
+	}
+
+	// Viewers send the keysym of the character which was typed, so the
+	// shifted keysym doubles as the key's identity.
+	rawCode := keysymCharCode(sym)
+	if !down {
+		w.keyEvent(keyEventUp, rawCode, rawCode, 0, v.modifiers)
+		return
+	}
+	modCode := -1
+	if !keysymIsModifier(sym) {
+		if r := keysymRune(sym); r >= 0 {
+			modCode = int(r)
+		} else {
+			modCode = rawCode
+		}
+	}
+	w.keyEvent(keyEventDown, rawCode, rawCode, modCode, v.modifiers)
+}
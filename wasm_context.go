@@ -0,0 +1,521 @@
+// +build js,wasm
+
+package gogui
+
+import (
+	"image"
+	"math"
+	"strconv"
+	"syscall/js"
+)
+
+var wasmLineCaps = map[LineCap]string{
+	LineCapButt:   "butt",
+	LineCapRound:  "round",
+	LineCapSquare: "square",
+}
+
+var wasmCompositeModes = map[CompositeMode]string{
+	CompositeSourceOver:      "source-over",
+	CompositeSourceIn:        "source-in",
+	CompositeSourceOut:       "source-out",
+	CompositeSourceAtop:      "source-atop",
+	CompositeDestinationOver: "destination-over",
+	CompositeDestinationIn:   "destination-in",
+	CompositeDestinationOut:  "destination-out",
+	CompositeDestinationAtop: "destination-atop",
+	CompositeCopy:            "copy",
+	CompositeXor:             "xor",
+	CompositeLighter:         "lighter",
+	CompositeMultiply:        "multiply",
+	CompositeScreen:          "screen",
+	CompositeOverlay:         "overlay",
+	CompositeDarken:          "darken",
+	CompositeLighten:         "lighten",
+}
+
+var wasmTextAligns = map[TextAlign]string{
+	TextAlignLeft:   "left",
+	TextAlignCenter: "center",
+	TextAlignRight:  "right",
+	TextAlignStart:  "start",
+	TextAlignEnd:    "end",
+}
+
+var wasmLineJoins = map[LineJoin]string{
+	LineJoinMiter: "miter",
+	LineJoinRound: "round",
+	LineJoinBevel: "bevel",
+}
+
+// A wasmContext is a DrawContext which forwards every call to a
+// CanvasRenderingContext2D.
+type wasmContext struct {
+	ctx js.Value
+
+	// base is the transform which the context started with. SetTransform
+	// is relative to it.
+	base js.Value
+
+	// The rendering context does not keep track of a wasmState, so saved
+	// holds it at each call to Save.
+	wasmState
+	saved []wasmState
+}
+
+type wasmState struct {
+	font Font
+
+	// Baselines are relative to the box which TextSize measures, so text is
+	// drawn on the alphabetic baseline at an offset from the point.
+	textBaseline TextBaseline
+
+	// textAlign is kept for FillAttributedText, which places runs itself.
+	textAlign TextAlign
+
+	// Gradients are created right before they are used, since their
+	// coordinates are relative to the transform at that time.
+	fill   *gradient
+	stroke *gradient
+
+	// fillRule is passed to fill and clip.
+	fillRule string
+}
+
+// newWasmContext wraps a rendering context and gives it the same defaults as
+// the drawing context on OS X.
+func newWasmContext(ctx js.Value) *wasmContext {
+	ctx.Set("lineCap", "round")
+	ctx.Set("lineJoin", "round")
+	ctx.Set("textBaseline", "alphabetic")
+	font := Font{Family: "Helvetica", Size: 18}
+	ctx.Set("font", cssFont(font))
+	ctx.Set("imageSmoothingEnabled", true)
+	ctx.Set("imageSmoothingQuality", "low")
+	return &wasmContext{
+		ctx:       ctx,
+		base:      ctx.Call("getTransform"),
+		wasmState: wasmState{font: font, fillRule: "nonzero"},
+	}
+}
+
+func (w *wasmContext) Arc(x, y, radius, startAngle, endAngle float64,
+	clockwise bool) {
+	w.ctx.Call("arc", x, y, math.Max(0, radius), startAngle, endAngle,
+		!clockwise)
+}
+
+func (w *wasmContext) ArcTo(x1, y1, x2, y2, radius float64) {
+	w.ctx.Call("arcTo", x1, y1, x2, y2, math.Max(0, radius))
+}
+
+func (w *wasmContext) AttributedTextSize(s *AttributedString) (float64, float64) {
+	width, height, _ := attributedTextSize(layoutMeasurer(w), s)
+	return width, height
+}
+
+func (w *wasmContext) BeginPath() {
+	w.ctx.Call("beginPath")
+}
+
+func (w *wasmContext) ClearShadow() {
+	w.ctx.Set("shadowColor", "rgba(0,0,0,0)")
+}
+
+func (w *wasmContext) ClipPath() {
+	w.ctx.Call("clip", w.fillRule)
+	w.ctx.Call("beginPath")
+}
+
+func (w *wasmContext) ClipRect(r Rect) {
+	p := js.Global().Get("Path2D").New()
+	p.Call("rect", r.X, r.Y, r.Width, r.Height)
+	w.ctx.Call("clip", p)
+}
+
+func (w *wasmContext) ClosePath() {
+	w.ctx.Call("closePath")
+}
+
+func (w *wasmContext) CubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	w.ctx.Call("bezierCurveTo", c1x, c1y, c2x, c2y, x, y)
+}
+
+func (w *wasmContext) DrawImage(img image.Image, dst Rect) {
+	w.DrawSubImage(img, imageBoundsRect(img), dst)
+}
+
+func (w *wasmContext) DrawSubImage(img image.Image, src Rect, dst Rect) {
+	pixels := imageSourceRect(img, src)
+	if pixels.Empty() || dst.Width == 0 || dst.Height == 0 {
+		return
+	}
+	var canvas js.Value
+	if i, ok := img.(*Image); ok {
+		// An Image keeps a canvas with all of its pixels.
+		canvas = i.nativeImage(func() interface{} {
+			return wasmImage(i.rgba, i.rgba.Rect)
+		}).(js.Value)
+		pixels = i.rgba.Rect
+	} else {
+		canvas = wasmImage(imageRGBA(img), pixels)
+	}
+
+	// drawImage does not flip images for negative sizes.
+	w.ctx.Call("save")
+	w.ctx.Call("translate", dst.X, dst.Y)
+	w.ctx.Call("scale", math.Copysign(1, dst.Width), math.Copysign(1, dst.Height))
+	w.ctx.Call("drawImage", canvas, src.X-float64(pixels.Min.X),
+		src.Y-float64(pixels.Min.Y), src.Width, src.Height, 0, 0,
+		math.Abs(dst.Width), math.Abs(dst.Height))
+	w.ctx.Call("restore")
+}
+
+func (w *wasmContext) DrawTextLayout(t *TextLayout, x, y float64) {
+	drawTextLayout(w, t, x, y)
+}
+
+func (w *wasmContext) FillAttributedText(s *AttributedString, x, y float64) {
+	drawAttributedText(w, s, x, y, w.textAlign, w.textBaseline)
+}
+
+func (w *wasmContext) FillEllipse(r Rect) {
+	w.useGradients()
+	w.ctx.Call("fill", wasmEllipse(r))
+}
+
+func (w *wasmContext) FillPath() {
+	w.useGradients()
+	w.ctx.Call("fill", w.fillRule)
+	w.ctx.Call("beginPath")
+}
+
+func (w *wasmContext) FillPathObject(p *Path) {
+	w.BeginPath()
+	p.addTo(w)
+	w.FillPath()
+}
+
+func (w *wasmContext) FillRect(r Rect) {
+	w.useGradients()
+	w.ctx.Call("fillRect", r.X, r.Y, r.Width, r.Height)
+}
+
+func (w *wasmContext) FillText(text string, x, y float64) {
+	w.useGradients()
+	w.ctx.Call("fillText", text, x, y+w.baselineOffset(text))
+}
+
+func (w *wasmContext) FontMetrics() FontMetrics {
+	_, ascent, descent := w.measureText("")
+	m := FontMetrics{
+		Ascent:    ascent,
+		Descent:   descent,
+		XHeight:   w.font.Size * 0.5,
+		CapHeight: w.font.Size * 0.7,
+	}
+	x := w.ctx.Call("measureText", "x").Get("actualBoundingBoxAscent")
+	if !x.IsUndefined() {
+		m.XHeight = x.Float()
+		h := w.ctx.Call("measureText", "H")
+		m.CapHeight = h.Get("actualBoundingBoxAscent").Float()
+	}
+	estimateUnderline(&m, w.font.Size)
+	return m
+}
+
+func (w *wasmContext) LineTo(x, y float64) {
+	w.ctx.Call("lineTo", x, y)
+}
+
+func (w *wasmContext) MoveTo(x, y float64) {
+	w.ctx.Call("moveTo", x, y)
+}
+
+func (w *wasmContext) QuadraticTo(cx, cy, x, y float64) {
+	w.ctx.Call("quadraticCurveTo", cx, cy, x, y)
+}
+
+func (w *wasmContext) Restore() {
+	if len(w.saved) > 0 {
+		w.wasmState = w.saved[len(w.saved)-1]
+		w.saved = w.saved[:len(w.saved)-1]
+	}
+	w.ctx.Call("restore")
+}
+
+func (w *wasmContext) Rotate(angle float64) {
+	w.ctx.Call("rotate", angle)
+}
+
+func (w *wasmContext) Save() {
+	w.saved = append(w.saved, w.wasmState)
+	w.ctx.Call("save")
+}
+
+func (w *wasmContext) Scale(x, y float64) {
+	w.ctx.Call("scale", x, y)
+}
+
+func (w *wasmContext) SetCompositeMode(m CompositeMode) {
+	if name, ok := wasmCompositeModes[m]; ok {
+		w.ctx.Set("globalCompositeOperation", name)
+	}
+}
+
+func (w *wasmContext) SetFill(c Color) {
+	w.fill = nil
+	w.ctx.Set("fillStyle", wasmColor(c))
+}
+
+func (w *wasmContext) SetFillPaint(p Paint) {
+	if c, ok := paintColor(p); ok {
+		w.SetFill(c)
+	} else if g, ok := paintGradient(p); ok {
+		w.fill = g
+	}
+}
+
+func (w *wasmContext) SetFillRule(rule FillRule) {
+	if rule == EvenOdd {
+		w.fillRule = "evenodd"
+	} else {
+		w.fillRule = "nonzero"
+	}
+}
+
+func (w *wasmContext) SetFont(size float64, name string) {
+	w.SetFontDescriptor(Font{Family: name, Size: size})
+}
+
+func (w *wasmContext) SetFontDescriptor(f Font) {
+	w.font = f
+	w.ctx.Set("font", cssFont(f))
+}
+
+func (w *wasmContext) SetGlobalAlpha(alpha float64) {
+	if alpha >= 0 && alpha <= 1 {
+		w.ctx.Set("globalAlpha", alpha)
+	}
+}
+
+func (w *wasmContext) SetInterpolation(q Interpolation) {
+	w.ctx.Set("imageSmoothingEnabled", q != InterpolationNearest)
+	if q == InterpolationHigh {
+		w.ctx.Set("imageSmoothingQuality", "high")
+	} else {
+		w.ctx.Set("imageSmoothingQuality", "low")
+	}
+}
+
+func (w *wasmContext) SetLineCap(c LineCap) {
+	if name, ok := wasmLineCaps[c]; ok {
+		w.ctx.Set("lineCap", name)
+	}
+}
+
+func (w *wasmContext) SetLineDash(pattern []float64, phase float64) {
+	dash, ok := normalizeLineDash(pattern)
+	if !ok {
+		return
+	}
+	array := js.Global().Get("Array").New()
+	for _, x := range dash {
+		array.Call("push", x)
+	}
+	w.ctx.Call("setLineDash", array)
+	w.ctx.Set("lineDashOffset", phase)
+}
+
+func (w *wasmContext) SetLineJoin(j LineJoin) {
+	if name, ok := wasmLineJoins[j]; ok {
+		w.ctx.Set("lineJoin", name)
+	}
+}
+
+func (w *wasmContext) SetMiterLimit(limit float64) {
+	w.ctx.Set("miterLimit", limit)
+}
+
+func (w *wasmContext) SetShadow(offsetX, offsetY, blur float64, c Color) {
+	if !validShadow(offsetX, offsetY, blur) {
+		return
+	}
+
+	// Shadows are not affected by the transform, so they are scaled by the
+	// pixel ratio here.
+	b := w.base
+	ratio := math.Sqrt(math.Abs(b.Get("a").Float()*b.Get("d").Float() -
+		b.Get("b").Float()*b.Get("c").Float()))
+	w.ctx.Set("shadowOffsetX", offsetX*ratio)
+	w.ctx.Set("shadowOffsetY", offsetY*ratio)
+	w.ctx.Set("shadowBlur", blur*ratio)
+	w.ctx.Set("shadowColor", wasmColor(c))
+}
+
+func (w *wasmContext) SetStroke(c Color) {
+	w.stroke = nil
+	w.ctx.Set("strokeStyle", wasmColor(c))
+}
+
+func (w *wasmContext) SetStrokePaint(p Paint) {
+	if c, ok := paintColor(p); ok {
+		w.SetStroke(c)
+	} else if g, ok := paintGradient(p); ok {
+		w.stroke = g
+	}
+}
+
+func (w *wasmContext) SetTextAlign(a TextAlign) {
+	if name, ok := wasmTextAligns[a]; ok {
+		w.textAlign = a
+		w.ctx.Set("textAlign", name)
+	}
+}
+
+func (w *wasmContext) SetTextBaseline(b TextBaseline) {
+	w.textBaseline = b
+}
+
+func (w *wasmContext) SetThickness(thickness float64) {
+	w.ctx.Set("lineWidth", thickness)
+}
+
+func (w *wasmContext) SetTransform(m Matrix) {
+	w.ctx.Call("setTransform", w.base)
+	w.Transform(m)
+}
+
+func (w *wasmContext) StrokeEllipse(r Rect) {
+	w.useGradients()
+	w.ctx.Call("stroke", wasmEllipse(r))
+}
+
+func (w *wasmContext) StrokePath() {
+	w.useGradients()
+	w.ctx.Call("stroke")
+	w.ctx.Call("beginPath")
+}
+
+func (w *wasmContext) StrokePathObject(p *Path) {
+	w.BeginPath()
+	p.addTo(w)
+	w.StrokePath()
+}
+
+func (w *wasmContext) StrokeRect(r Rect) {
+	w.useGradients()
+	w.ctx.Call("strokeRect", r.X, r.Y, r.Width, r.Height)
+}
+
+func (w *wasmContext) StrokeText(text string, x, y float64) {
+	w.useGradients()
+	w.ctx.Call("strokeText", text, x, y+w.baselineOffset(text))
+}
+
+func (w *wasmContext) TextPositions(text string) *TextPositions {
+	return textPositions(layoutMeasurer(w), w.font, text)
+}
+
+func (w *wasmContext) TextSize(text string) (float64, float64) {
+	width, ascent, descent := w.measureText(text)
+	return width, ascent + descent
+}
+
+func (w *wasmContext) Transform(m Matrix) {
+	w.ctx.Call("transform", m.A, m.B, m.C, m.D, m.E, m.F)
+}
+
+func (w *wasmContext) Translate(x, y float64) {
+	w.ctx.Call("translate", x, y)
+}
+
+// baselineOffset returns how far below the point given to FillText or
+// StrokeText the alphabetic baseline of the text is.
+func (w *wasmContext) baselineOffset(text string) float64 {
+	width, ascent, descent := w.measureText(text)
+	_, top := textOrigin(0, 0, width, ascent+descent, ascent, TextAlignLeft,
+		w.textBaseline)
+	return top + ascent
+}
+
+// measureText returns the width of text and the ascent and descent of the
+// current font.
+func (w *wasmContext) measureText(text string) (width, ascent,
+	descent float64) {
+	m := w.ctx.Call("measureText", text)
+	ascent, descent = w.font.Size, w.font.Size*0.2
+	if a := m.Get("fontBoundingBoxAscent"); !a.IsUndefined() {
+		ascent = a.Float()
+		descent = m.Get("fontBoundingBoxDescent").Float()
+	}
+	return m.Get("width").Float(), ascent, descent
+}
+
+// useGradients sets the fill and stroke styles to the current gradients.
+func (w *wasmContext) useGradients() {
+	if w.fill == nil && w.stroke == nil {
+		return
+	}
+	inv := w.ctx.Call("getTransform").Call("inverse")
+	canvas := w.ctx.Get("canvas")
+	width, height := canvas.Get("width").Float(), canvas.Get("height").Float()
+	var area []point
+	for _, p := range []point{{0, 0}, {width, 0}, {0, height}, {width, height}} {
+		q := inv.Call("transformPoint", js.Global().Get("DOMPoint").New(p.X, p.Y))
+		area = append(area, point{q.Get("x").Float(), q.Get("y").Float()})
+	}
+	if w.fill != nil {
+		w.ctx.Set("fillStyle", wasmGradient(w.ctx, w.fill.padded(area)))
+	}
+	if w.stroke != nil {
+		w.ctx.Set("strokeStyle", wasmGradient(w.ctx, w.stroke.padded(area)))
+	}
+}
+
+// wasmGradient creates a CanvasGradient for a gradient which pads.
+func wasmGradient(ctx js.Value, g *gradient) js.Value {
+	var res js.Value
+	if g.radial {
+		res = ctx.Call("createRadialGradient", g.start.X, g.start.Y, g.r1,
+			g.end.X, g.end.Y, g.r2)
+	} else {
+		res = ctx.Call("createLinearGradient", g.start.X, g.start.Y, g.end.X,
+			g.end.Y)
+	}
+	for _, s := range g.stops {
+		res.Call("addColorStop", clampUnit(s.Offset), wasmColor(s.Color))
+	}
+	return res
+}
+
+// wasmImage creates a canvas with the pixels in part of an image.
+func wasmImage(img *image.RGBA, r image.Rectangle) js.Value {
+	data := imageNRGBA(img, r)
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	clamped := js.Global().Get("Uint8ClampedArray").New(array.Get("buffer"))
+	imageData := js.Global().Get("ImageData").New(clamped, r.Dx(), r.Dy())
+	canvas := js.Global().Get("document").Call("createElement", "canvas")
+	canvas.Set("width", r.Dx())
+	canvas.Set("height", r.Dy())
+	canvas.Call("getContext", "2d").Call("putImageData", imageData, 0, 0)
+	return canvas
+}
+
+// wasmEllipse creates a Path2D for the ellipse inscribed in a rectangle.
+// Unlike the current path, a Path2D is not affected by BeginPath.
+func wasmEllipse(r Rect) js.Value {
+	p := js.Global().Get("Path2D").New()
+	p.Call("ellipse", r.X+r.Width/2, r.Y+r.Height/2, math.Abs(r.Width/2),
+		math.Abs(r.Height/2), 0, 0, 2*math.Pi)
+	return p
+}
+
+func wasmColor(c Color) string {
+	channel := func(x float64) string {
+		return strconv.Itoa(int(math.Floor(clampUnit(x)*255 + 0.5)))
+	}
+	return "rgba(" + channel(c.R) + "," + channel(c.G) + "," + channel(c.B) +
+		"," + strconv.FormatFloat(clampUnit(c.A), 'g', -1, 64) + ")"
+}
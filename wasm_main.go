@@ -0,0 +1,381 @@
+// +build js,wasm
+
+package gogui
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"syscall/js"
+)
+
+// The wasm driver adds the app's windows to the web page which loaded the
+// program.
+//
+// Main never returns, but the main loop waits on a channel while it is idle,
+// which hands control back to the browser's event loop.
+func init() {
+	RegisterDriver("wasm", newSoftApp(newWasmHost()))
+}
+
+// wasmStyle is the style sheet which is added to the page by Main.
+const wasmStyle = `
+.gogui-window {
+	position: absolute;
+	background: #ececec;
+	box-shadow: 0 4px 16px rgba(0, 0, 0, 0.4);
+	border-radius: 4px 4px 0 0;
+	font-family: Helvetica, Arial, sans-serif;
+}
+.gogui-title {
+	height: 22px;
+	line-height: 22px;
+	font-size: 13px;
+	text-align: center;
+	background: #dcdcdc;
+	border-radius: 4px 4px 0 0;
+	cursor: default;
+	user-select: none;
+	position: relative;
+}
+.gogui-close {
+	position: absolute;
+	left: 6px;
+	top: 5px;
+	width: 12px;
+	height: 12px;
+	border-radius: 6px;
+	background: #fc605c;
+}
+.gogui-content {
+	position: relative;
+	overflow: hidden;
+	outline: none;
+}
+.gogui-content canvas {
+	position: absolute;
+}
+`
+
+// wasmPressCodes maps the names of special keys to the char codes which OS X
+// uses for them in key press events.
+var wasmPressCodes = map[string]int{"Backspace": 8, "Tab": 9, "Enter": 13,
+	"Escape": 27, "Delete": 46, "ArrowLeft": 37, "ArrowUp": 38,
+	"ArrowRight": 39, "ArrowDown": 40}
+
+// wasmModifiers contains the names of keys which do not cause key presses.
+var wasmModifiers = map[string]bool{"Shift": true, "Control": true,
+	"Alt": true, "Meta": true, "CapsLock": true}
+
+// A wasmWindow holds the DOM elements which show a window.
+type wasmWindow struct {
+	panel     js.Value
+	titleText js.Value
+	content   js.Value
+	canvases  map[*softCanvas]js.Value
+}
+
+// A wasmDrag is a window which the user is moving by its title bar.
+type wasmDrag struct {
+	window         *softWindow
+	startX, startY float64
+	frameX, frameY float64
+}
+
+// A wasmHost presents windows as elements of the web page which is running the
+// program. Draw handlers draw straight onto HTML5 canvases.
+//
+// Event listeners only copy what they need from DOM events; the events are
+// handled on the main loop.
+type wasmHost struct {
+	app      *softApp
+	document js.Value
+	windows  map[*softWindow]*wasmWindow
+
+	activeWindow *softWindow
+	mouseWindow  *softWindow
+	drag         *wasmDrag
+}
+
+func newWasmHost() *wasmHost {
+	return &wasmHost{windows: map[*softWindow]*wasmWindow{}}
+}
+
+func (h *wasmHost) available() bool {
+	return !js.Global().Get("document").IsUndefined()
+}
+
+func (h *wasmHost) start(a *softApp, info *AppInfo) error {
+	h.app = a
+	h.document = js.Global().Get("document")
+	if h.document.IsUndefined() {
+		return errors.New("no DOM document is available")
+	}
+	if info != nil && info.Name != "" {
+		h.document.Set("title", info.Name)
+	}
+	style := h.document.Call("createElement", "style")
+	style.Set("textContent", wasmStyle)
+	h.document.Get("head").Call("appendChild", style)
+
+	window := js.Global().Get("window")
+	a.screen = Rect{0, 0, window.Get("innerWidth").Float(),
+		window.Get("innerHeight").Float()}
+
+	wasmListen(h.document, "mousemove", false, func(e js.Value) {
+		x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
+		a.RunOnMain(func() {
+			h.documentMouse(mouseEventDrag, x, y)
+		})
+	})
+	wasmListen(h.document, "mouseup", false, func(e js.Value) {
+		x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
+		a.RunOnMain(func() {
+			h.documentMouse(mouseEventUp, x, y)
+		})
+	})
+	return nil
+}
+
+func (h *wasmHost) showWindow(w *softWindow) {
+	h.updateWindow(w)
+}
+
+func (h *wasmHost) hideWindow(w *softWindow) {
+	if win, ok := h.windows[w]; ok {
+		win.panel.Get("style").Set("display", "none")
+	}
+	if h.activeWindow == w {
+		h.activeWindow = nil
+	}
+	if h.mouseWindow == w {
+		h.mouseWindow = nil
+	}
+	if h.drag != nil && h.drag.window == w {
+		h.drag = nil
+	}
+}
+
+func (h *wasmHost) updateWindow(w *softWindow) {
+	for i, x := range h.app.windows {
+		win := h.window(x)
+		win.titleText.Set("textContent", x.title)
+		style := win.panel.Get("style")
+		style.Set("display", "block")
+		style.Set("zIndex", i+1)
+		h.layout(x)
+	}
+}
+
+func (h *wasmHost) drawWindow(w *softWindow) {
+}
+
+func (h *wasmHost) paintWindow(w *softWindow) {
+	win := h.window(w)
+	scale := 1.0
+	if ratio := js.Global().Get("devicePixelRatio"); ratio.Truthy() {
+		scale = ratio.Float()
+	}
+	seen := map[*softCanvas]bool{}
+	for _, widget := range w.widgets {
+		c := widget.(*softCanvas)
+		seen[c] = true
+		element, ok := win.canvases[c]
+		if !ok {
+			element = h.document.Call("createElement", "canvas")
+			win.content.Call("appendChild", element)
+			win.canvases[c] = element
+		}
+		style := element.Get("style")
+		style.Set("left", wasmPixels(c.frame.X))
+		style.Set("top", wasmPixels(c.frame.Y))
+		style.Set("width", wasmPixels(c.frame.Width))
+		style.Set("height", wasmPixels(c.frame.Height))
+		element.Set("width", math.Ceil(c.frame.Width*scale))
+		element.Set("height", math.Ceil(c.frame.Height*scale))
+		if c.handler != nil {
+			ctx := element.Call("getContext", "2d")
+			ctx.Call("scale", scale, scale)
+			c.handler(newWasmContext(ctx))
+		}
+	}
+	for c, element := range win.canvases {
+		if !seen[c] {
+			win.content.Call("removeChild", element)
+			delete(win.canvases, c)
+		}
+	}
+}
+
+// window returns the DOM elements for a window, creating them if necessary.
+func (h *wasmHost) window(w *softWindow) *wasmWindow {
+	if win, ok := h.windows[w]; ok {
+		return win
+	}
+	win := &wasmWindow{canvases: map[*softCanvas]js.Value{}}
+	h.windows[w] = win
+
+	win.panel = h.document.Call("createElement", "div")
+	win.panel.Set("className", "gogui-window")
+	titleBar := h.document.Call("createElement", "div")
+	titleBar.Set("className", "gogui-title")
+	closeButton := h.document.Call("createElement", "div")
+	closeButton.Set("className", "gogui-close")
+	win.titleText = h.document.Call("createElement", "span")
+	win.content = h.document.Call("createElement", "div")
+	win.content.Set("className", "gogui-content")
+	win.content.Set("tabIndex", 0)
+	titleBar.Call("appendChild", closeButton)
+	titleBar.Call("appendChild", win.titleText)
+	win.panel.Call("appendChild", titleBar)
+	win.panel.Call("appendChild", win.content)
+	h.document.Get("body").Call("appendChild", win.panel)
+
+	a := h.app
+	wasmListen(closeButton, "mousedown", true, func(e js.Value) {
+		e.Call("stopPropagation")
+		a.RunOnMain(w.userClosed)
+	})
+	wasmListen(titleBar, "mousedown", true, func(e js.Value) {
+		x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
+		a.RunOnMain(func() {
+			h.activate(w)
+			h.drag = &wasmDrag{window: w, startX: x, startY: y,
+				frameX: w.frame.X, frameY: w.frame.Y}
+		})
+	})
+	wasmListen(win.content, "mousedown", true, func(e js.Value) {
+		if e.Get("button").Int() != 0 {
+			return
+		}
+		x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
+		a.RunOnMain(func() {
+			h.activate(w)
+			h.mouseWindow = w
+			h.windowMouse(w, mouseEventDown, x, y)
+		})
+	})
+	wasmListen(win.content, "mousemove", false, func(e js.Value) {
+		x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
+		a.RunOnMain(func() {
+			if h.mouseWindow == nil && h.drag == nil {
+				h.windowMouse(w, mouseEventMove, x, y)
+			}
+		})
+	})
+	wasmListen(win.content, "keydown", true, func(e js.Value) {
+		key := e.Get("key").String()
+		keyCode := e.Get("keyCode").Int()
+		flags := wasmKeyFlags(e)
+		charCode := -1
+		if !wasmModifiers[key] {
+			if runes := []rune(key); len(runes) == 1 {
+				charCode = int(runes[0])
+			} else if code, ok := wasmPressCodes[key]; ok {
+				charCode = code
+			} else {
+				charCode = keyCode
+			}
+		}
+		a.RunOnMain(func() {
+			if w.showing {
+				w.keyEvent(keyEventDown, keyCode, keyCode, charCode, flags)
+			}
+		})
+	})
+	wasmListen(win.content, "keyup", true, func(e js.Value) {
+		keyCode := e.Get("keyCode").Int()
+		flags := wasmKeyFlags(e)
+		a.RunOnMain(func() {
+			if w.showing {
+				w.keyEvent(keyEventUp, keyCode, keyCode, -1, flags)
+			}
+		})
+	})
+	return win
+}
+
+func (h *wasmHost) layout(w *softWindow) {
+	win := h.window(w)
+	panelStyle := win.panel.Get("style")
+	panelStyle.Set("left", wasmPixels(w.frame.X))
+	panelStyle.Set("top", wasmPixels(w.frame.Y))
+	contentStyle := win.content.Get("style")
+	contentStyle.Set("width", wasmPixels(w.frame.Width))
+	contentStyle.Set("height", wasmPixels(w.frame.Height))
+}
+
+func (h *wasmHost) activate(w *softWindow) {
+	if !w.showing {
+		return
+	}
+	if h.activeWindow != w {
+		h.activeWindow = w
+		w.Focus()
+	}
+	h.window(w).content.Call("focus")
+}
+
+// documentMouse handles mouse events which happen anywhere on the page. These
+// continue drags which started in a window.
+func (h *wasmHost) documentMouse(eventType int, x, y float64) {
+	if d := h.drag; d != nil {
+		d.window.frame.X = d.frameX + x - d.startX
+		d.window.frame.Y = d.frameY + y - d.startY
+		h.layout(d.window)
+		if eventType == mouseEventUp {
+			h.drag = nil
+		}
+	}
+	if w := h.mouseWindow; w != nil {
+		h.windowMouse(w, eventType, x, y)
+		if eventType == mouseEventUp {
+			h.mouseWindow = nil
+		}
+	}
+}
+
+// windowMouse delivers a mouse event with page coordinates to a window.
+func (h *wasmHost) windowMouse(w *softWindow, eventType int, x, y float64) {
+	if !w.showing {
+		return
+	}
+	rect := h.window(w).content.Call("getBoundingClientRect")
+	w.mouseEvent(eventType, x-rect.Get("left").Float(),
+		y-rect.Get("top").Float())
+}
+
+func wasmKeyFlags(e js.Value) int {
+	flags := 0
+	if e.Get("altKey").Bool() {
+		flags |= keyFlagAlt
+	}
+	if e.Get("ctrlKey").Bool() {
+		flags |= keyFlagCtrl
+	}
+	if e.Get("metaKey").Bool() {
+		flags |= keyFlagMeta
+	}
+	if e.Get("shiftKey").Bool() {
+		flags |= keyFlagShift
+	}
+	return flags
+}
+
+// wasmListen adds an event listener to a DOM element. The listener runs on the
+// browser's event loop, so it must not block.
+func wasmListen(target js.Value, event string, preventDefault bool,
+	f func(e js.Value)) {
+	target.Call("addEventListener", event, js.FuncOf(func(this js.Value,
+		args []js.Value) interface{} {
+		f(args[0])
+		if preventDefault {
+			args[0].Call("preventDefault")
+		}
+		return nil
+	}))
+}
+
+func wasmPixels(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64) + "px"
+}
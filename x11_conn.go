@@ -0,0 +1,487 @@
+// +build x11 linux
+// +build !js
+
+package gogui
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	x11OpCreateWindow       = 1
+	x11OpDestroyWindow      = 4
+	x11OpMapWindow          = 8
+	x11OpConfigureWindow    = 12
+	x11OpInternAtom         = 16
+	x11OpChangeProperty     = 18
+	x11OpCreateGC           = 55
+	x11OpPutImage           = 72
+	x11OpGetKeyboardMapping = 101
+)
+
+const (
+	x11EventKeyPress        = 2
+	x11EventKeyRelease      = 3
+	x11EventButtonPress     = 4
+	x11EventButtonRelease   = 5
+	x11EventMotionNotify    = 6
+	x11EventExpose          = 12
+	x11EventReparentNotify  = 21
+	x11EventConfigureNotify = 22
+	x11EventClientMessage   = 33
+	x11EventMappingNotify   = 34
+)
+
+// Predefined atoms from the core protocol.
+const (
+	x11AtomAtom        = 4
+	x11AtomString      = 31
+	x11AtomWMName      = 39
+	x11AtomWMNormHints = 40
+	x11AtomWMSizeHints = 41
+)
+
+var x11ByteOrder = binary.LittleEndian
+
+// An x11Format describes how pixels of a given depth are stored in images.
+type x11Format struct {
+	depth        int
+	bitsPerPixel int
+	scanlinePad  int
+}
+
+// An x11Visual describes how the bits of a pixel map to colors.
+type x11Visual struct {
+	id        uint32
+	depth     int
+	redMask   uint32
+	greenMask uint32
+	blueMask  uint32
+}
+
+// An x11Setup stores the parts of the connection setup reply which the backend
+// needs.
+type x11Setup struct {
+	idBase        uint32
+	idMask        uint32
+	maxRequestLen int
+	imageMSBFirst bool
+	minKeycode    int
+	maxKeycode    int
+	formats       []x11Format
+	root          uint32
+	rootDepth     int
+	rootVisual    x11Visual
+	blackPixel    uint32
+	screenWidth   int
+	screenHeight  int
+	screenVisuals []x11Visual
+}
+
+// An x11Reply is either a reply to a request or an error caused by it.
+type x11Reply struct {
+	data []byte
+	err  error
+}
+
+// An x11Conn is a connection to an X server. Requests may be sent from any
+// goroutine, and replies are matched to their requests by sequence number.
+type x11Conn struct {
+	conn  net.Conn
+	setup x11Setup
+
+	writeLock sync.Mutex
+	writer    *bufio.Writer
+	sequence  uint16
+	nextID    uint32
+	pending   map[uint16]chan x11Reply
+}
+
+// dialX11 connects to the X server named by a display string such as ":0".
+func dialX11(display string) (*x11Conn, error) {
+	if display == "" {
+		return nil, errors.New("DISPLAY is not set")
+	}
+	colon := strings.LastIndex(display, ":")
+	if colon < 0 {
+		return nil, errors.New("invalid display: " + display)
+	}
+	host := display[:colon]
+	number := display[colon+1:]
+	if dot := strings.Index(number, "."); dot >= 0 {
+		number = number[:dot]
+	}
+	displayNum, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, errors.New("invalid display: " + display)
+	}
+
+	var conn net.Conn
+	if strings.HasPrefix(host, "/") {
+		conn, err = net.Dial("unix", display)
+	} else if host == "" || host == "unix" {
+		conn, err = net.Dial("unix", "/tmp/.X11-unix/X"+number)
+	} else {
+		conn, err = net.Dial("tcp", net.JoinHostPort(host,
+			strconv.Itoa(6000+displayNum)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	authName, authData := x11Auth(host, number)
+	res, err := newX11Conn(conn, authName, authData)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// newX11Conn performs the connection setup on an open connection.
+func newX11Conn(conn net.Conn, authName string, authData []byte) (*x11Conn,
+	error) {
+	res := &x11Conn{
+		conn:    conn,
+		writer:  bufio.NewWriter(conn),
+		pending: map[uint16]chan x11Reply{},
+	}
+
+	req := make([]byte, 12)
+	req[0] = 'l'
+	x11ByteOrder.PutUint16(req[2:], 11)
+	x11ByteOrder.PutUint16(req[6:], uint16(len(authName)))
+	x11ByteOrder.PutUint16(req[8:], uint16(len(authData)))
+	req = append(req, x11Pad([]byte(authName))...)
+	req = append(req, x11Pad(authData)...)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	body := make([]byte, 4*int(x11ByteOrder.Uint16(header[6:])))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, err
+	}
+	switch header[0] {
+	case 0:
+		reason := body
+		if int(header[1]) < len(reason) {
+			reason = reason[:header[1]]
+		}
+		return nil, errors.New("X11 connection refused: " + string(reason))
+	case 2:
+		return nil, errors.New("X11 connection requires further authentication")
+	}
+	if err := res.setup.parse(body); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *x11Setup) parse(b []byte) error {
+	if len(b) < 32 {
+		return errors.New("X11 setup reply is too short")
+	}
+	s.idBase = x11ByteOrder.Uint32(b[4:])
+	s.idMask = x11ByteOrder.Uint32(b[8:])
+	vendorLen := int(x11ByteOrder.Uint16(b[16:]))
+	s.maxRequestLen = int(x11ByteOrder.Uint16(b[18:])) * 4
+	numScreens := int(b[20])
+	numFormats := int(b[21])
+	s.imageMSBFirst = b[22] != 0
+	s.minKeycode = int(b[26])
+	s.maxKeycode = int(b[27])
+
+	offset := 32 + len(x11Pad(make([]byte, vendorLen)))
+	if len(b) < offset+8*numFormats+40 || numScreens == 0 {
+		return errors.New("X11 setup reply is too short")
+	}
+	for i := 0; i < numFormats; i++ {
+		f := b[offset:]
+		s.formats = append(s.formats, x11Format{int(f[0]), int(f[1]), int(f[2])})
+		offset += 8
+	}
+
+	// Only the first screen is used.
+	screen := b[offset:]
+	s.root = x11ByteOrder.Uint32(screen[0:])
+	s.blackPixel = x11ByteOrder.Uint32(screen[12:])
+	s.screenWidth = int(x11ByteOrder.Uint16(screen[20:]))
+	s.screenHeight = int(x11ByteOrder.Uint16(screen[22:]))
+	rootVisual := x11ByteOrder.Uint32(screen[32:])
+	s.rootDepth = int(screen[38])
+	numDepths := int(screen[39])
+	offset += 40
+	for i := 0; i < numDepths; i++ {
+		if len(b) < offset+8 {
+			return errors.New("X11 setup reply is too short")
+		}
+		depth := int(b[offset])
+		numVisuals := int(x11ByteOrder.Uint16(b[offset+2:]))
+		offset += 8
+		if len(b) < offset+24*numVisuals {
+			return errors.New("X11 setup reply is too short")
+		}
+		for j := 0; j < numVisuals; j++ {
+			v := b[offset:]
+			visual := x11Visual{
+				id:        x11ByteOrder.Uint32(v[0:]),
+				depth:     depth,
+				redMask:   x11ByteOrder.Uint32(v[8:]),
+				greenMask: x11ByteOrder.Uint32(v[12:]),
+				blueMask:  x11ByteOrder.Uint32(v[16:]),
+			}
+			s.screenVisuals = append(s.screenVisuals, visual)
+			if visual.id == rootVisual {
+				s.rootVisual = visual
+			}
+			offset += 24
+		}
+	}
+	if s.rootVisual.redMask == 0 {
+		return errors.New("X11 root visual is not a TrueColor visual")
+	}
+	return nil
+}
+
+// format returns the image format for a depth.
+func (s *x11Setup) format(depth int) (x11Format, bool) {
+	for _, f := range s.formats {
+		if f.depth == depth {
+			return f, true
+		}
+	}
+	return x11Format{}, false
+}
+
+// newID allocates a resource ID.
+func (c *x11Conn) newID() uint32 {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.nextID++
+	return c.setup.idBase | (c.nextID & c.setup.idMask)
+}
+
+// send sends a request. The length field of the request is filled in
+// automatically. If the request has a reply, send returns a channel which will
+// receive it.
+func (c *x11Conn) send(req []byte, hasReply bool) (<-chan x11Reply, error) {
+	req = x11Pad(req)
+	x11ByteOrder.PutUint16(req[2:], uint16(len(req)/4))
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.sequence++
+	var ch chan x11Reply
+	if hasReply {
+		ch = make(chan x11Reply, 1)
+		c.pending[c.sequence] = ch
+	}
+	if _, err := c.writer.Write(req); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// flush writes buffered requests to the server.
+func (c *x11Conn) flush() error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.writer.Flush()
+}
+
+// roundTrip sends a request and waits for its reply.
+func (c *x11Conn) roundTrip(req []byte) ([]byte, error) {
+	ch, err := c.send(req, true)
+	if err == nil {
+		err = c.flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	reply, ok := <-ch
+	if !ok {
+		return nil, errors.New("X11 connection closed")
+	}
+	return reply.data, reply.err
+}
+
+// readEvents reads from the server until the connection fails, passing every
+// event to a handler. Replies and errors are sent to the goroutines waiting on
+// them.
+func (c *x11Conn) readEvents(handler func([]byte)) error {
+	reader := bufio.NewReader(c.conn)
+	for {
+		packet := make([]byte, 32)
+		if _, err := io.ReadFull(reader, packet); err != nil {
+			c.closePending()
+			return err
+		}
+		switch packet[0] {
+		case 0:
+			seq := x11ByteOrder.Uint16(packet[2:])
+			err := fmt.Errorf("X11 error %d for request %d", packet[1],
+				packet[10])
+			c.deliver(seq, x11Reply{err: err})
+		case 1:
+			extra := 4 * int(x11ByteOrder.Uint32(packet[4:]))
+			if extra > 0 {
+				packet = append(packet, make([]byte, extra)...)
+				if _, err := io.ReadFull(reader, packet[32:]); err != nil {
+					c.closePending()
+					return err
+				}
+			}
+			seq := x11ByteOrder.Uint16(packet[2:])
+			c.deliver(seq, x11Reply{data: packet})
+		default:
+			handler(packet)
+		}
+	}
+}
+
+func (c *x11Conn) deliver(seq uint16, reply x11Reply) {
+	c.writeLock.Lock()
+	ch, ok := c.pending[seq]
+	delete(c.pending, seq)
+	c.writeLock.Unlock()
+	if ok {
+		ch <- reply
+	}
+}
+
+func (c *x11Conn) closePending() {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	for seq, ch := range c.pending {
+		close(ch)
+		delete(c.pending, seq)
+	}
+}
+
+// internAtom returns the atom for a name, creating it if necessary.
+func (c *x11Conn) internAtom(name string) (uint32, error) {
+	req := make([]byte, 8, 8+len(name))
+	req[0] = x11OpInternAtom
+	x11ByteOrder.PutUint16(req[4:], uint16(len(name)))
+	req = append(req, name...)
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	return x11ByteOrder.Uint32(reply[8:]), nil
+}
+
+// keyboardMapping returns the keysyms for every keycode, indexed by
+// keycode-minKeycode.
+func (c *x11Conn) keyboardMapping() ([][]uint32, error) {
+	count := c.setup.maxKeycode - c.setup.minKeycode + 1
+	req := make([]byte, 8)
+	req[0] = x11OpGetKeyboardMapping
+	req[4] = byte(c.setup.minKeycode)
+	req[5] = byte(count)
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	perKeycode := int(reply[1])
+	res := make([][]uint32, count)
+	for i := range res {
+		for j := 0; j < perKeycode; j++ {
+			offset := 32 + 4*(i*perKeycode+j)
+			if offset+4 > len(reply) {
+				break
+			}
+			res[i] = append(res[i], x11ByteOrder.Uint32(reply[offset:]))
+		}
+	}
+	return res, nil
+}
+
+// x11Request creates a request with a header and a list of 32-bit values.
+func x11Request(opcode, data byte, values ...uint32) []byte {
+	res := make([]byte, 4+4*len(values))
+	res[0] = opcode
+	res[1] = data
+	for i, v := range values {
+		x11ByteOrder.PutUint32(res[4+4*i:], v)
+	}
+	return res
+}
+
+// x11Pad pads data with zeroes to a multiple of four bytes.
+func x11Pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// x11Auth finds MIT-MAGIC-COOKIE-1 credentials for a display in the user's
+// Xauthority file. It returns empty credentials if none are found.
+func x11Auth(host, number string) (string, []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", nil
+		}
+		path = filepath.Join(home, ".Xauthority")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil
+	}
+	defer f.Close()
+
+	if host == "" || host == "unix" || strings.HasPrefix(host, "/") {
+		host, _ = os.Hostname()
+	}
+	r := bufio.NewReader(f)
+	readField := func() ([]byte, error) {
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		res := make([]byte, length)
+		_, err := io.ReadFull(r, res)
+		return res, err
+	}
+	for {
+		var family uint16
+		if err := binary.Read(r, binary.BigEndian, &family); err != nil {
+			return "", nil
+		}
+		var fields [4][]byte
+		for i := range fields {
+			if fields[i], err = readField(); err != nil {
+				return "", nil
+			}
+		}
+		address, num, name, data := fields[0], fields[1], fields[2], fields[3]
+		const familyLocal, familyWild = 256, 65535
+		if family != familyWild && (family != familyLocal ||
+			string(address) != host) {
+			continue
+		}
+		if len(num) > 0 && string(num) != number {
+			continue
+		}
+		if string(name) == "MIT-MAGIC-COOKIE-1" {
+			return string(name), data
+		}
+	}
+}
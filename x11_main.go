@@ -0,0 +1,172 @@
+// +build x11 linux
+// +build !js
+
+package gogui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The x11 driver shows windows on the X server named by the DISPLAY
+// environment variable. It is available whenever DISPLAY is set.
+func init() {
+	RegisterDriver("x11", newSoftApp(&x11Host{
+		windows: map[*softWindow]*x11Window{},
+	}))
+}
+
+// An x11Host presents software-rendered windows through an X server.
+type x11Host struct {
+	app    *softApp
+	conn   *x11Conn
+	gc     uint32
+	format x11Format
+	keymap [][]uint32
+
+	atomDeleteWindow uint32
+	atomNetWMName    uint32
+	atomProtocols    uint32
+	atomUTF8String   uint32
+
+	windows map[*softWindow]*x11Window
+}
+
+func (h *x11Host) available() bool {
+	return os.Getenv("DISPLAY") != ""
+}
+
+func (h *x11Host) start(a *softApp, info *AppInfo) error {
+	conn, err := dialX11(os.Getenv("DISPLAY"))
+	if err != nil {
+		return err
+	}
+	h.app = a
+	h.conn = conn
+	setup := &conn.setup
+	var ok bool
+	if h.format, ok = setup.format(setup.rootDepth); !ok {
+		return errors.New("X11 server has no format for the root depth")
+	}
+	switch h.format.bitsPerPixel {
+	case 16, 24, 32:
+	default:
+		return errors.New("X11 server uses an unsupported pixel size")
+	}
+	a.screen = Rect{0, 0, float64(setup.screenWidth),
+		float64(setup.screenHeight)}
+
+	go func() {
+		err := conn.readEvents(func(packet []byte) {
+			a.RunOnMain(func() {
+				h.handleEvent(packet)
+			})
+		})
+		a.RunOnMain(func() {
+			fmt.Fprintln(os.Stderr, "gogui: lost connection to X server:",
+				err)
+			os.Exit(1)
+		})
+	}()
+
+	names := []string{"WM_DELETE_WINDOW", "_NET_WM_NAME", "WM_PROTOCOLS",
+		"UTF8_STRING"}
+	atoms := []*uint32{&h.atomDeleteWindow, &h.atomNetWMName,
+		&h.atomProtocols, &h.atomUTF8String}
+	for i, name := range names {
+		if *atoms[i], err = conn.internAtom(name); err != nil {
+			return err
+		}
+	}
+	if h.keymap, err = conn.keyboardMapping(); err != nil {
+		return err
+	}
+
+	h.gc = conn.newID()
+	conn.send(x11Request(x11OpCreateGC, 0, h.gc, setup.root, 0), false)
+	return conn.flush()
+}
+
+func (h *x11Host) showWindow(w *softWindow) {
+	xw, ok := h.windows[w]
+	if !ok {
+		xw = newX11Window(h, w)
+		h.windows[w] = xw
+	}
+	xw.update()
+	h.conn.send(x11Request(x11OpMapWindow, 0, xw.id), false)
+	h.conn.flush()
+}
+
+func (h *x11Host) hideWindow(w *softWindow) {
+	// Most hidden windows are never shown again, so the X window is
+	// destroyed rather than unmapped. Showing the window creates a new one.
+	if xw, ok := h.windows[w]; ok {
+		delete(h.windows, w)
+		h.conn.send(x11Request(x11OpDestroyWindow, 0, xw.id), false)
+		h.conn.flush()
+	}
+}
+
+func (h *x11Host) updateWindow(w *softWindow) {
+	if xw, ok := h.windows[w]; ok {
+		xw.update()
+		h.conn.flush()
+	}
+}
+
+func (h *x11Host) drawWindow(w *softWindow) {
+	if xw, ok := h.windows[w]; ok {
+		xw.present()
+		h.conn.flush()
+	}
+}
+
+func (h *x11Host) handleEvent(packet []byte) {
+	code := packet[0] & 0x7f
+	if code == x11EventMappingNotify {
+		// The request is only meaningful for keyboard mappings, but
+		// refreshing it for pointer mappings is harmless.
+		go func() {
+			keymap, err := h.conn.keyboardMapping()
+			if err == nil {
+				h.app.RunOnMain(func() {
+					h.keymap = keymap
+				})
+			}
+		}()
+		return
+	}
+
+	// Input events store the window after the root window. Every other event
+	// we select stores it right after the sequence number.
+	var id uint32
+	switch code {
+	case x11EventKeyPress, x11EventKeyRelease, x11EventButtonPress,
+		x11EventButtonRelease, x11EventMotionNotify:
+		id = x11ByteOrder.Uint32(packet[12:])
+	default:
+		id = x11ByteOrder.Uint32(packet[4:])
+	}
+	for _, xw := range h.windows {
+		if xw.id == id {
+			xw.handleEvent(code, packet)
+			h.conn.flush()
+			return
+		}
+	}
+}
+
+// keysym returns the keysym for a keycode, taking the shift key into account.
+func (h *x11Host) keysym(keycode int, shift bool) uint32 {
+	idx := keycode - h.conn.setup.minKeycode
+	if idx < 0 || idx >= len(h.keymap) || len(h.keymap[idx]) == 0 {
+		return 0
+	}
+	syms := h.keymap[idx]
+	if shift && len(syms) > 1 && syms[1] != 0 {
+		return syms[1]
+	}
+	return syms[0]
+}
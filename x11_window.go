@@ -0,0 +1,313 @@
+// +build x11 linux
+// +build !js
+
+package gogui
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"unicode"
+	"unicode/utf8"
+)
+
+// X11 event masks and state bits.
+const (
+	x11MaskKeyPress        = 1 << 0
+	x11MaskKeyRelease      = 1 << 1
+	x11MaskButtonPress     = 1 << 2
+	x11MaskButtonRelease   = 1 << 3
+	x11MaskPointerMotion   = 1 << 6
+	x11MaskExposure        = 1 << 15
+	x11MaskStructureNotify = 1 << 17
+
+	x11StateShift   = 1 << 0
+	x11StateLock    = 1 << 1
+	x11StateControl = 1 << 2
+	x11StateMod1    = 1 << 3
+	x11StateMod4    = 1 << 6
+	x11StateButton1 = 1 << 8
+)
+
+// An x11Window is the X window which shows a softWindow.
+type x11Window struct {
+	host   *x11Host
+	window *softWindow
+	id     uint32
+	parent uint32
+
+	sentFrame Rect
+	sentTitle string
+}
+
+func newX11Window(h *x11Host, w *softWindow) *x11Window {
+	res := &x11Window{
+		host:   h,
+		window: w,
+		id:     h.conn.newID(),
+		parent: h.conn.setup.root,
+	}
+	f := res.roundedFrame()
+	eventMask := uint32(x11MaskKeyPress | x11MaskKeyRelease |
+		x11MaskButtonPress | x11MaskButtonRelease | x11MaskPointerMotion |
+		x11MaskExposure | x11MaskStructureNotify)
+	const classInputOutput, maskBackPixel, maskEventMask = 1, 2, 0x800
+	h.conn.send(x11Request(x11OpCreateWindow, byte(h.conn.setup.rootDepth),
+		res.id, h.conn.setup.root, x11Pair(f[0], f[1]), x11Pair(f[2], f[3]),
+		classInputOutput<<16, 0, maskBackPixel|maskEventMask,
+		h.conn.setup.blackPixel, eventMask), false)
+	protocols := make([]byte, 4)
+	x11ByteOrder.PutUint32(protocols, h.atomDeleteWindow)
+	res.changeProperty(h.atomProtocols, x11AtomAtom, 32, protocols)
+	res.sentFrame = w.frame
+	return res
+}
+
+// update sends the window's frame, title, and stacking order to the server.
+func (x *x11Window) update() {
+	w := x.window
+	f := x.roundedFrame()
+	if w.frame != x.sentFrame {
+		x.sentFrame = w.frame
+		const maskX, maskY, maskWidth, maskHeight = 1, 2, 4, 8
+		x.host.conn.send(x11Request(x11OpConfigureWindow, 0, x.id,
+			maskX|maskY|maskWidth|maskHeight, uint32(int32(f[0])),
+			uint32(int32(f[1])), uint32(f[2]), uint32(f[3])), false)
+	}
+
+	// Tell the window manager where we want the window and that it should
+	// not be resizable, like a Cocoa window without a resize control.
+	const usPosition, usSize, pMinSize, pMaxSize = 1, 2, 16, 32
+	hints := make([]byte, 18*4)
+	values := []int{usPosition | usSize | pMinSize | pMaxSize, f[0], f[1],
+		f[2], f[3], f[2], f[3], f[2], f[3]}
+	for i, v := range values {
+		x11ByteOrder.PutUint32(hints[4*i:], uint32(int32(v)))
+	}
+	x.changeProperty(x11AtomWMNormHints, x11AtomWMSizeHints, 32, hints)
+
+	if w.title != x.sentTitle {
+		x.sentTitle = w.title
+		x.changeProperty(x11AtomWMName, x11AtomString, 8, latin1(w.title))
+		x.changeProperty(x.host.atomNetWMName, x.host.atomUTF8String, 8,
+			[]byte(w.title))
+	}
+
+	windows := x.host.app.windows
+	if len(windows) > 0 && windows[len(windows)-1] == w {
+		const maskStackMode, stackAbove = 0x40, 0
+		x.host.conn.send(x11Request(x11OpConfigureWindow, 0, x.id,
+			maskStackMode, stackAbove), false)
+	}
+}
+
+// present copies the window's image to the X window.
+func (x *x11Window) present() {
+	img := x.window.image
+	if img == nil {
+		return
+	}
+	setup := &x.host.conn.setup
+	format := x.host.format
+	bytesPerPixel := format.bitsPerPixel / 8
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	stride := width * bytesPerPixel
+	if pad := format.scanlinePad / 8; pad > 1 {
+		stride = (stride + pad - 1) / pad * pad
+	}
+	if stride == 0 {
+		return
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if setup.imageMSBFirst {
+		order = binary.BigEndian
+	}
+	visual := setup.rootVisual
+	const headerSize = 24
+	rowsPerStrip := (setup.maxRequestLen - headerSize) / stride
+	if rowsPerStrip < 1 {
+		return
+	}
+	for top := 0; top < height; top += rowsPerStrip {
+		rows := height - top
+		if rows > rowsPerStrip {
+			rows = rowsPerStrip
+		}
+		data := make([]byte, stride*rows)
+		for y := 0; y < rows; y++ {
+			row := data[y*stride:]
+			src := img.Pix[img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+top+y):]
+			for i := 0; i < width; i++ {
+				p := src[i*4 : i*4+3]
+				value := x11Channel(p[0], visual.redMask) |
+					x11Channel(p[1], visual.greenMask) |
+					x11Channel(p[2], visual.blueMask)
+				x11PutPixel(row[i*bytesPerPixel:], bytesPerPixel, value, order)
+			}
+		}
+		const formatZPixmap = 2
+		req := x11Request(x11OpPutImage, formatZPixmap, x.id, x.host.gc,
+			x11Pair(width, rows), x11Pair(0, top),
+			uint32(setup.rootDepth)<<8)
+		x.host.conn.send(append(req, data...), false)
+	}
+}
+
+func (x *x11Window) handleEvent(code byte, packet []byte) {
+	w := x.window
+	switch code {
+	case x11EventKeyPress, x11EventKeyRelease:
+		x.handleKey(code == x11EventKeyPress, int(packet[1]),
+			int(x11ByteOrder.Uint16(packet[28:])))
+	case x11EventButtonPress, x11EventButtonRelease, x11EventMotionNotify:
+		px := float64(int16(x11ByteOrder.Uint16(packet[24:])))
+		py := float64(int16(x11ByteOrder.Uint16(packet[26:])))
+		state := x11ByteOrder.Uint16(packet[28:])
+		if code == x11EventMotionNotify {
+			if (state & x11StateButton1) != 0 {
+				w.mouseEvent(mouseEventDrag, px, py)
+			} else {
+				w.mouseEvent(mouseEventMove, px, py)
+			}
+		} else if packet[1] == 1 {
+			if code == x11EventButtonPress {
+				w.mouseEvent(mouseEventDown, px, py)
+			} else {
+				w.mouseEvent(mouseEventUp, px, py)
+			}
+		}
+	case x11EventExpose:
+		if x11ByteOrder.Uint16(packet[16:]) == 0 {
+			x.present()
+		}
+	case x11EventReparentNotify:
+		x.parent = x11ByteOrder.Uint32(packet[12:])
+	case x11EventConfigureNotify:
+		frame := w.frame
+		frame.Width = float64(x11ByteOrder.Uint16(packet[20:]))
+		frame.Height = float64(x11ByteOrder.Uint16(packet[22:]))
+
+		// Positions are only relative to the root window if the event came
+		// from the window manager or if the window was not reparented.
+		if (packet[0]&0x80) != 0 || x.parent == x.host.conn.setup.root {
+			frame.X = float64(int16(x11ByteOrder.Uint16(packet[16:])))
+			frame.Y = float64(int16(x11ByteOrder.Uint16(packet[18:])))
+		}
+		resized := frame.Width != w.frame.Width ||
+			frame.Height != w.frame.Height
+		w.frame = frame
+		x.sentFrame = frame
+		if resized {
+			w.invalidate()
+		}
+	case x11EventClientMessage:
+		msgType := x11ByteOrder.Uint32(packet[8:])
+		if msgType == x.host.atomProtocols &&
+			x11ByteOrder.Uint32(packet[12:]) == x.host.atomDeleteWindow {
+			w.userClosed()
+		}
+	}
+}
+
+func (x *x11Window) handleKey(down bool, keycode, state int) {
+	flags := 0
+	if (state & x11StateMod1) != 0 {
+		flags |= keyFlagAlt
+	}
+	if (state & x11StateControl) != 0 {
+		flags |= keyFlagCtrl
+	}
+	if (state & x11StateMod4) != 0 {
+		flags |= keyFlagMeta
+	}
+	if (state & x11StateShift) != 0 {
+		flags |= keyFlagShift
+	}
+
+	sym := x.host.keysym(keycode, false)
+	rawCode := keysymCharCode(sym)
+	if !down {
+		x.window.keyEvent(keyEventUp, keycode, rawCode, 0, flags)
+		return
+	}
+
+	modCode := -1
+	if !keysymIsModifier(sym) {
+		modSym := x.host.keysym(keycode, (state&x11StateShift) != 0)
+		if r := keysymRune(modSym); r >= 0 {
+			if (state & x11StateLock) != 0 {
+				r = unicode.ToUpper(r)
+			}
+			modCode = int(r)
+		} else {
+			modCode = keysymCharCode(modSym)
+		}
+	}
+	x.window.keyEvent(keyEventDown, keycode, rawCode, modCode, flags)
+}
+
+func (x *x11Window) changeProperty(property, typ uint32, format int,
+	data []byte) {
+	req := x11Request(x11OpChangeProperty, 0, x.id, property, typ,
+		uint32(format), uint32(len(data)*8/format))
+	x.host.conn.send(append(req, data...), false)
+}
+
+// roundedFrame returns the x, y, width, and height of the window in pixels.
+func (x *x11Window) roundedFrame() [4]int {
+	f := x.window.frame
+	res := [4]int{int(math.Floor(f.X)), int(math.Floor(f.Y)),
+		int(math.Ceil(f.Width)), int(math.Ceil(f.Height))}
+	if res[2] < 1 {
+		res[2] = 1
+	}
+	if res[3] < 1 {
+		res[3] = 1
+	}
+	return res
+}
+
+// x11Pair packs two 16-bit values into one 32-bit value.
+func x11Pair(a, b int) uint32 {
+	return uint32(uint16(a)) | uint32(uint16(b))<<16
+}
+
+// x11Channel scales an 8-bit color channel to fit in a visual's mask.
+func x11Channel(value uint8, mask uint32) uint32 {
+	shift := uint(bits.TrailingZeros32(mask))
+	size := uint(bits.OnesCount32(mask))
+	if size >= 8 {
+		return (uint32(value) << (size - 8)) << shift
+	}
+	return (uint32(value) >> (8 - size)) << shift
+}
+
+func x11PutPixel(b []byte, size int, value uint32, order binary.ByteOrder) {
+	switch size {
+	case 4:
+		order.PutUint32(b, value)
+	case 2:
+		order.PutUint16(b, uint16(value))
+	case 3:
+		if order == binary.ByteOrder(binary.BigEndian) {
+			b[0], b[1], b[2] = byte(value>>16), byte(value>>8), byte(value)
+		} else {
+			b[0], b[1], b[2] = byte(value), byte(value>>8), byte(value>>16)
+		}
+	}
+}
+
+// latin1 converts a string to ISO Latin-1, replacing characters which cannot
+// be represented with question marks.
+func latin1(s string) []byte {
+	res := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r == utf8.RuneError || r > 0xff {
+			res = append(res, '?')
+		} else {
+			res = append(res, byte(r))
+		}
+	}
+	return res
+}